@@ -2,19 +2,39 @@ package main
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/vorpalengineering/gundler/internal/config"
+	"github.com/vorpalengineering/gundler/internal/history"
+	"github.com/vorpalengineering/gundler/internal/journal"
 	"github.com/vorpalengineering/gundler/internal/keypool"
+	"github.com/vorpalengineering/gundler/internal/keys"
+	"github.com/vorpalengineering/gundler/internal/logging"
+	"github.com/vorpalengineering/gundler/internal/mempool"
+	"github.com/vorpalengineering/gundler/internal/processor"
+	"github.com/vorpalengineering/gundler/internal/ratelimit"
 	"github.com/vorpalengineering/gundler/internal/rpc"
 )
 
+// Time limit for connecting to the remote signer
+const remoteSignerTimeout = 10 * time.Second
+
+// How often the config file is checked for changes
+const configPollInterval = 2 * time.Second
+
 func main() {
 	fmt.Println("Starting Gundler...")
 
@@ -26,13 +46,16 @@ func main() {
 
 	// Print config
 	cfg.Print()
+	logging.SetLevel(cfg.LogLevel)
 
-	// Load private keys from environment
-	privateKeys, err := config.LoadPrivateKeys()
+	// Load bundler key signers
+	loader := &keyLoader{}
+	signers, err := loader.load(cfg)
 	if err != nil {
-		log.Fatalf("Failed to load private keys: %v", err)
+		log.Fatalf("Failed to load bundler keys: %v", err)
 	}
-	log.Printf("Loaded %d private keys from environment", len(privateKeys))
+	loader.use(signers)
+	log.Printf("Loaded %d bundler keys", len(signers))
 
 	// Connect to Ethereum client
 	ethClient, err := ethclient.Dial(cfg.EthereumRPC)
@@ -50,21 +73,77 @@ func main() {
 	log.Printf("Connected to chain ID: %v", chainID)
 
 	// Create KeyPool
-	keyPool, err := keypool.NewKeyPool(privateKeys, ethClient, chainID)
+	keyPool, err := keypool.NewKeyPool(signers, ethClient, chainID, cfg.KeySelection, cfg.MaxPendingPerKey)
 	if err != nil {
 		log.Fatalf("Failed to create KeyPool: %v", err)
 	}
+	keyPool.SetFeeMargins(cfg.GasLimitBufferPercent, cfg.GasPriceMarginPercent)
+
+	// Monitor key balances, optionally refilling keys from a funding key or the
+	// beneficiary of the entry point they serve
+	monitorConfig := keypool.BalanceMonitorConfig{
+		MinBalance:    cfg.MinKeyBalanceWei(),
+		TargetBalance: cfg.RefillTargetBalanceWei(),
+	}
+	if cfg.RefillSource == config.RefillSourceFundingKey {
+		monitorConfig.Funder, err = loadFundingSigner(cfg)
+		if err != nil {
+			log.Fatalf("Failed to load funding key: %v", err)
+		}
+	}
+	funders, err := refillFunders(cfg, signers)
+	if err != nil {
+		log.Fatalf("Failed to set up key refill: %v", err)
+	}
+	keyPool.SetKeyFunders(funders)
+	keyPool.StartBalanceMonitor(monitorConfig)
+
+	// Open mempool journal if persistence is enabled
+	var mempoolJournal *journal.Journal
+	if cfg.DataDir != "" {
+		mempoolJournal, err = journal.Open(
+			filepath.Join(cfg.DataDir, "mempool"),
+			time.Duration(cfg.SnapshotInterval)*time.Second,
+		)
+		if err != nil {
+			log.Fatalf("Failed to open mempool journal: %v", err)
+		}
+	}
+
+	// Open userOp history index (in memory if persistence is disabled)
+	historyDir := ""
+	if cfg.DataDir != "" {
+		historyDir = filepath.Join(cfg.DataDir, "history")
+	}
+	historyStore, err := history.Open(historyDir, history.Retention{
+		MaxAge:     time.Duration(cfg.HistoryRetention) * time.Second,
+		MaxEntries: int(cfg.HistoryMaxEntries),
+	})
+	if err != nil {
+		log.Fatalf("Failed to open history index: %v", err)
+	}
+
+	// Read admin API token
+	adminToken, err := readAdminToken(cfg)
+	if err != nil {
+		log.Fatalf("Failed to read admin token: %v", err)
+	}
 
 	// Start RPC Server
 	rpc, err := rpc.NewRPCServer(
-		cfg.Port,
+		serverConfig(cfg, adminToken),
 		cfg.EthereumRPC,
-		cfg.SupportedEntryPoints,
+		entryPointConfigs(cfg),
 		string(cfg.Mode),
-		cfg.MaxBundleSize,
+		mempoolLimits(cfg),
 		ethClient,
 		chainID,
 		keyPool,
+		mempoolJournal,
+		historyStore,
+		cfg.MaxBatchSize,
+		readinessConfig(cfg),
+		accessConfig(cfg),
 	)
 	if err != nil {
 		log.Fatalf("Failed to create RPC Server: %v", err)
@@ -75,20 +154,429 @@ func main() {
 
 	fmt.Println("Gundler Startup Complete")
 
+	// Reload config on SIGHUP or when the config file changes
+	reloadChannel := make(chan os.Signal, 1)
+	signal.Notify(reloadChannel, syscall.SIGHUP)
+	fileChanged := make(chan struct{}, 1)
+	go watchConfigFile(cfg.Path(), fileChanged)
+
 	// Wait for interrupt signal
 	quitChannel := make(chan os.Signal, 1)
 	signal.Notify(quitChannel, syscall.SIGINT, syscall.SIGTERM)
-	<-quitChannel
+	for running := true; running; {
+		select {
+		case <-quitChannel:
+			running = false
+		case <-reloadChannel:
+			log.Println("SIGHUP received, reloading config")
+			cfg = reloadConfig(cfg, loader, keyPool, rpc)
+		case <-fileChanged:
+			log.Printf("Config file %s changed, reloading config", cfg.Path())
+			cfg = reloadConfig(cfg, loader, keyPool, rpc)
+		}
+	}
 
 	fmt.Println("\nShutting down Gundler...")
 
-	// Graceful shutdown with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	// A second signal exits without waiting, the journal is replayed on restart
+	go func() {
+		<-quitChannel
+		log.Println("Second signal received, exiting immediately")
+		os.Exit(1)
+	}()
+
+	// Stop accepting userOps and wait for submitted bundles, up to the shutdown timeout
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout)*time.Second)
 	defer cancel()
 
-	if err := rpc.Shutdown(ctx); err != nil {
-		log.Fatalf("RPC Server forced to shutdown: %v", err)
+	if err := rpc.Shutdown(shutdownCtx); err != nil {
+		log.Printf("RPC Server forced to shutdown: %v", err)
 	}
 
+	// Stop key balance monitor
+	keyPool.Stop()
+
+	// Persist final mempool state and pending bundles
+	if err := mempoolJournal.Close(); err != nil {
+		log.Printf("Failed to close mempool journal: %v", err)
+	}
+	if err := historyStore.Close(); err != nil {
+		log.Printf("Failed to close history index: %v", err)
+	}
+
+	// Close the node connection last
+	ethClient.Close()
+
 	fmt.Println("Gundler stopped")
 }
+
+// serverConfig converts the listener config into RPC server settings
+func serverConfig(cfg *config.GundlerConfig, adminToken string) rpc.ServerConfig {
+	return rpc.ServerConfig{
+		Host:              cfg.Host,
+		Port:              cfg.Port,
+		TLSCertFile:       cfg.TLSCertFile,
+		TLSKeyFile:        cfg.TLSKeyFile,
+		ReadHeaderTimeout: time.Duration(cfg.ReadHeaderTimeout) * time.Second,
+		ReadTimeout:       time.Duration(cfg.ReadTimeout) * time.Second,
+		WriteTimeout:      time.Duration(cfg.WriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(cfg.IdleTimeout) * time.Second,
+		MaxBodySize:       int64(cfg.MaxBodySize),
+		AdminListen:       cfg.AdminListen,
+		AdminToken:        adminToken,
+		WSAllowedOrigins:  cfg.WSAllowedOrigins,
+	}
+}
+
+// readAdminToken reads the admin API bearer token, or returns "" if the admin API is disabled
+func readAdminToken(cfg *config.GundlerConfig) (string, error) {
+	if cfg.AdminListen == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(cfg.AdminTokenFile)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("admin token file %s is empty", cfg.AdminTokenFile)
+	}
+	return token, nil
+}
+
+// mempoolLimits converts the mempool config into mempool limits
+func mempoolLimits(cfg *config.GundlerConfig) mempool.Limits {
+	return mempool.Limits{
+		MaxSize:         int(cfg.MaxMempoolSize),
+		MaxOpsPerSender: int(cfg.MaxOpsPerSender),
+		MaxOpsPerEntity: int(cfg.MaxOpsPerEntity),
+		MaxAge:          time.Duration(cfg.MaxUserOpAge) * time.Second,
+	}
+}
+
+// readinessConfig converts the readiness thresholds into RPC server settings
+func readinessConfig(cfg *config.GundlerConfig) rpc.ReadinessConfig {
+	return rpc.ReadinessConfig{
+		MinKeyBalance:   cfg.MinKeyBalanceWei(),
+		MaxBlockAge:     time.Duration(cfg.MaxBlockAge) * time.Second,
+		MaxMempoolUsage: cfg.MaxMempoolUsage,
+	}
+}
+
+// accessConfig converts the API keys and rate limits into RPC server settings
+func accessConfig(cfg *config.GundlerConfig) rpc.AccessConfig {
+	apiKeys := make([]rpc.APIKey, 0, len(cfg.APIKeys))
+	for _, apiKey := range cfg.APIKeys {
+		apiKeys = append(apiKeys, rpc.APIKey{
+			Name:         apiKey.Name,
+			Key:          apiKey.Key,
+			RateLimits:   rateLimits(apiKey.RateLimits),
+			IPRateLimits: rateLimits(apiKey.IPRateLimits),
+		})
+	}
+	return rpc.AccessConfig{
+		RequireAPIKey:     cfg.RequireAPIKey,
+		APIKeys:           apiKeys,
+		RateLimits:        rateLimits(cfg.RateLimits),
+		TrustForwardedFor: cfg.TrustForwardedFor,
+	}
+}
+
+// rateLimits converts rate limits by method into token bucket limits
+func rateLimits(limits map[string]config.RateLimitConfig) map[string]ratelimit.Limit {
+	converted := make(map[string]ratelimit.Limit, len(limits))
+	for method, limit := range limits {
+		converted[method] = ratelimit.Limit{Rate: limit.Rate, Burst: limit.Burst}
+	}
+	return converted
+}
+
+// entryPointConfigs converts the per-EntryPoint config into RPC server settings
+func entryPointConfigs(cfg *config.GundlerConfig) []rpc.EntryPointConfig {
+	entryPoints := make([]rpc.EntryPointConfig, 0, len(cfg.SupportedEntryPoints))
+	for _, ep := range cfg.SupportedEntryPoints {
+		keys := make([]common.Address, 0, len(ep.Keys))
+		for _, key := range ep.Keys {
+			keys = append(keys, common.HexToAddress(key))
+		}
+		entryPoints = append(entryPoints, rpc.EntryPointConfig{
+			Address: common.HexToAddress(ep.Address),
+			Bundling: processor.BundlingConfig{
+				Mode:          cfg.BundlingMode,
+				Interval:      time.Duration(ep.BundlingIntervalMs) * time.Millisecond,
+				MaxBundleSize: ep.MaxBundleSize,
+				MaxBundleGas:  ep.MaxBundleGas,
+				SizeThreshold: ep.BundleSizeThreshold,
+				GasThreshold:  ep.BundleGasThreshold,
+			},
+			Beneficiary:     common.HexToAddress(ep.Beneficiary),
+			Keys:            keys,
+			CheckReputation: ep.HasValidationStage(config.ValidationStageReputation),
+			Simulate:        ep.HasValidationStage(config.ValidationStageSimulation),
+		})
+	}
+	return entryPoints
+}
+
+// refillFunders maps each bundler key to the beneficiary of the first entry point
+// it serves, which refills it when refill_source is beneficiary. Those
+// beneficiaries must be bundler keys.
+func refillFunders(cfg *config.GundlerConfig, signers []keypool.Signer) (map[common.Address]common.Address, error) {
+	if cfg.RefillSource != config.RefillSourceBeneficiary {
+		return nil, nil
+	}
+
+	isKey := make(map[common.Address]bool, len(signers))
+	for _, signer := range signers {
+		isKey[signer.Address()] = true
+	}
+
+	entryPoints := entryPointConfigs(cfg)
+	funders := make(map[common.Address]common.Address, len(signers))
+	for _, signer := range signers {
+		key := signer.Address()
+		for _, epConfig := range entryPoints {
+			if len(epConfig.Keys) > 0 && !slices.Contains(epConfig.Keys, key) {
+				continue
+			}
+			if !isKey[epConfig.Beneficiary] {
+				return nil, fmt.Errorf("refill_source beneficiary requires beneficiary %s of entry point %s to be a bundler key", epConfig.Beneficiary.Hex(), epConfig.Address.Hex())
+			}
+			funders[key] = epConfig.Beneficiary
+			break
+		}
+	}
+	return funders, nil
+}
+
+// reloadConfig re-reads the config and applies the changes that don't need a
+// restart. The whole reload is rejected if any other field changed. Returns the
+// config in effect.
+func reloadConfig(cfg *config.GundlerConfig, loader *keyLoader, keyPool *keypool.KeyPool, server *rpc.RPCServer) *config.GundlerConfig {
+	// Read and validate the new config
+	next, err := cfg.Reload()
+	if err != nil {
+		log.Printf("Config reload failed: %v", err)
+		return cfg
+	}
+	if fields := cfg.RestartRequired(next); len(fields) > 0 {
+		log.Printf("Config reload rejected: %s cannot change without a restart, restart gundler to apply", strings.Join(fields, ", "))
+		return cfg
+	}
+
+	// Reload bundler keys (e.g. new files in keystore_dir)
+	signers, err := loader.load(next)
+	if err != nil {
+		log.Printf("Config reload failed: failed to load bundler keys: %v", err)
+		return cfg
+	}
+	addresses := make(map[common.Address]bool, len(signers))
+	for _, signer := range signers {
+		addresses[signer.Address()] = true
+	}
+	entryPoints := entryPointConfigs(next)
+	for i, epConfig := range entryPoints {
+		for _, key := range epConfig.Keys {
+			if !addresses[key] {
+				log.Printf("Config reload failed: key %s of entry point %s is not a bundler key", key.Hex(), epConfig.Address.Hex())
+				closeSigners(signers)
+				return cfg
+			}
+		}
+
+		// Keep a bundling mode switched at runtime unless the config changes it
+		if next.BundlingMode == cfg.BundlingMode {
+			entryPoints[i].Bundling.Mode = ""
+		}
+	}
+
+	// Check every change before applying any, so a rejected reload changes nothing
+	funders, fundersErr := refillFunders(next, signers)
+	err = errors.Join(
+		keypool.ValidateStrategy(next.KeySelection),
+		keypool.ValidateSigners(signers),
+		server.CheckReconfigure(entryPoints),
+		logging.ValidateLevel(next.LogLevel),
+		fundersErr,
+	)
+	if err != nil {
+		log.Printf("Config reload failed: %v", err)
+		closeSigners(signers)
+		return cfg
+	}
+
+	// Apply changes, none of which can fail
+	keyPool.SetSelection(next.KeySelection, next.MaxPendingPerKey)
+	keyPool.SetSigners(signers)
+	keyPool.SetKeyFunders(funders)
+	loader.use(signers)
+	keyPool.SetFeeMargins(next.GasLimitBufferPercent, next.GasPriceMarginPercent)
+	server.Reconfigure(
+		entryPoints,
+		mempoolLimits(next),
+		next.MaxBatchSize,
+		readinessConfig(next),
+		accessConfig(next),
+	)
+	logging.SetLevel(next.LogLevel)
+
+	if changed := cfg.Changed(next); len(changed) > 0 {
+		log.Printf("Config reloaded, changed: %s", strings.Join(changed, ", "))
+	} else {
+		log.Println("Config reloaded, no changes")
+	}
+	return next
+}
+
+// watchConfigFile signals changed whenever the config file's modification time or size changes
+func watchConfigFile(path string, changed chan<- struct{}) {
+	lastInfo, _ := os.Stat(path)
+
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		info, err := os.Stat(path)
+		if err != nil {
+			lastInfo = nil
+			continue
+		}
+		if lastInfo != nil && info.ModTime().Equal(lastInfo.ModTime()) && info.Size() == lastInfo.Size() {
+			continue
+		}
+		lastInfo = info
+
+		// Notify without blocking, one pending notification is enough
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// keyLoader loads bundler keys, remembering a keystore password entered on the
+// terminal so keys can be reloaded without prompting again
+type keyLoader struct {
+	password *string
+	signers  []keypool.Signer // signers in the key pool, closed once replaced
+}
+
+// use records the signers now in the key pool and closes the ones they replaced
+func (loader *keyLoader) use(signers []keypool.Signer) {
+	closeSigners(loader.signers)
+	loader.signers = signers
+}
+
+// closeSigners closes the connections held by remote signers
+func closeSigners(signers []keypool.Signer) {
+	for _, signer := range signers {
+		if closer, ok := signer.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+}
+
+// keystorePassword reads the password file if configured, otherwise prompts once
+func (loader *keyLoader) keystorePassword(cfg *config.GundlerConfig) (string, error) {
+	if cfg.KeystorePasswordFile != "" {
+		return keys.ReadPassword(cfg.KeystorePasswordFile, "")
+	}
+	if loader.password == nil {
+		password, err := keys.ReadPassword("", "Keystore password: ")
+		if err != nil {
+			return "", err
+		}
+		loader.password = &password
+	}
+	return *loader.password, nil
+}
+
+// load loads bundler keys from a keystore directory, a mnemonic, a remote
+// signer, or (DEBUG only) the GUNDLER_PRIV_KEYS environment variable
+func (loader *keyLoader) load(cfg *config.GundlerConfig) ([]keypool.Signer, error) {
+	var privateKeys []*ecdsa.PrivateKey
+	switch {
+	case cfg.RemoteSignerURL != "":
+		ctx, cancel := context.WithTimeout(context.Background(), remoteSignerTimeout)
+		defer cancel()
+		addresses := make([]common.Address, 0, len(cfg.RemoteSignerAddresses))
+		for _, address := range cfg.RemoteSignerAddresses {
+			addresses = append(addresses, common.HexToAddress(address))
+		}
+		return keypool.DialRemoteSigners(ctx, cfg.RemoteSignerURL, addresses)
+	case cfg.KeystoreDir != "":
+		password, err := loader.keystorePassword(cfg)
+		if err != nil {
+			return nil, err
+		}
+		privateKeys, err = keys.LoadKeystoreDir(cfg.KeystoreDir, password)
+		if err != nil {
+			return nil, err
+		}
+	case cfg.MnemonicFile != "":
+		mnemonic, err := keys.ReadMnemonic(cfg.MnemonicFile)
+		if err != nil {
+			return nil, err
+		}
+		privateKeys, err = keys.DeriveMnemonicKeys(mnemonic, cfg.DerivationPath, cfg.KeyCount)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		log.Println("WARNING: loading raw private keys from GUNDLER_PRIV_KEYS (allow_env_keys)")
+		hexKeys, err := config.LoadPrivateKeys()
+		if err != nil {
+			return nil, err
+		}
+		for i, hexKey := range hexKeys {
+			privateKey, _, err := keypool.ParsePrivateKey(hexKey)
+			if err != nil {
+				return nil, fmt.Errorf("invalid private key at index %d: %w", i, err)
+			}
+			privateKeys = append(privateKeys, privateKey)
+		}
+	}
+
+	signers := make([]keypool.Signer, 0, len(privateKeys))
+	for _, privateKey := range privateKeys {
+		signers = append(signers, keypool.NewLocalSigner(privateKey))
+	}
+	return signers, nil
+}
+
+// loadFundingSigner loads the refill funding key from funding_keystore, the
+// remote signer (funding_address), or (DEBUG only) the GUNDLER_FUNDING_KEY
+// environment variable
+func loadFundingSigner(cfg *config.GundlerConfig) (keypool.Signer, error) {
+	if cfg.FundingAddress != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), remoteSignerTimeout)
+		defer cancel()
+		signers, err := keypool.DialRemoteSigners(ctx, cfg.RemoteSignerURL, []common.Address{common.HexToAddress(cfg.FundingAddress)})
+		if err != nil {
+			return nil, err
+		}
+		return signers[0], nil
+	}
+
+	if cfg.FundingKeystore != "" {
+		password, err := keys.ReadPassword(cfg.KeystorePasswordFile, "Funding keystore password: ")
+		if err != nil {
+			return nil, err
+		}
+		privateKey, err := keys.LoadKeystoreFile(cfg.FundingKeystore, password)
+		if err != nil {
+			return nil, err
+		}
+		return keypool.NewLocalSigner(privateKey), nil
+	}
+
+	hexKey, err := config.LoadFundingKey()
+	if err != nil {
+		return nil, err
+	}
+	privateKey, _, err := keypool.ParsePrivateKey(hexKey)
+	if err != nil {
+		return nil, err
+	}
+	return keypool.NewLocalSigner(privateKey), nil
+}
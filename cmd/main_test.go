@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/vorpalengineering/gundler/internal/config"
+	"github.com/vorpalengineering/gundler/internal/keypool"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+func newSigner(t *testing.T) keypool.Signer {
+	t.Helper()
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return keypool.NewLocalSigner(privateKey)
+}
+
+func TestRefillFunders(t *testing.T) {
+	v07Beneficiary, v08Beneficiary, v07Key, sharedKey := newSigner(t), newSigner(t), newSigner(t), newSigner(t)
+	signers := []keypool.Signer{v07Beneficiary, v08Beneficiary, v07Key, sharedKey}
+
+	// The v0.7 entry point has its own keys, the v0.8 one uses every key
+	cfg := &config.GundlerConfig{
+		RefillSource: config.RefillSourceBeneficiary,
+		SupportedEntryPoints: []config.EntryPointConfig{
+			{
+				Address:     types.EntryPointV07Address.Hex(),
+				Beneficiary: v07Beneficiary.Address().Hex(),
+				Keys:        []string{v07Key.Address().Hex()},
+			},
+			{
+				Address:     types.EntryPointV08Address.Hex(),
+				Beneficiary: v08Beneficiary.Address().Hex(),
+			},
+		},
+	}
+
+	funders, err := refillFunders(cfg, signers)
+	if err != nil {
+		t.Fatalf("refillFunders: %v", err)
+	}
+	want := map[common.Address]common.Address{
+		v07Key.Address():         v07Beneficiary.Address(),
+		sharedKey.Address():      v08Beneficiary.Address(),
+		v07Beneficiary.Address(): v08Beneficiary.Address(),
+		v08Beneficiary.Address(): v08Beneficiary.Address(),
+	}
+	if len(funders) != len(want) {
+		t.Fatalf("funders = %v, want %v", funders, want)
+	}
+	for key, funder := range want {
+		if funders[key] != funder {
+			t.Fatalf("funder of %s = %s, want %s", key.Hex(), funders[key].Hex(), funder.Hex())
+		}
+	}
+
+	// A beneficiary that is not a bundler key can't refill
+	if _, err := refillFunders(cfg, []keypool.Signer{v07Key, sharedKey, v08Beneficiary}); err == nil {
+		t.Fatalf("refillFunders accepted a beneficiary that is not a bundler key")
+	}
+
+	// Other refill sources don't use pool keys
+	cfg.RefillSource = config.RefillSourceFundingKey
+	if funders, err := refillFunders(cfg, signers); err != nil || funders != nil {
+		t.Fatalf("refillFunders with a funding key = %v (%v), want none", funders, err)
+	}
+}
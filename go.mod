@@ -2,7 +2,11 @@ module github.com/vorpalengineering/gundler
 
 go 1.24.5
 
-require github.com/ethereum/go-ethereum v1.16.4
+require (
+	github.com/ethereum/go-ethereum v1.16.4
+	github.com/gorilla/websocket v1.4.2
+	golang.org/x/term v0.30.0
+)
 
 require (
 	github.com/Microsoft/go-winio v0.6.2 // indirect
@@ -15,8 +19,9 @@ require (
 	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.0.1 // indirect
 	github.com/ethereum/c-kzg-4844/v2 v2.1.3 // indirect
 	github.com/ethereum/go-verkle v0.2.2 // indirect
+	github.com/fsnotify/fsnotify v1.6.0 // indirect
 	github.com/go-ole/go-ole v1.3.0 // indirect
-	github.com/gorilla/websocket v1.4.2 // indirect
+	github.com/google/uuid v1.3.0 // indirect
 	github.com/holiman/uint256 v1.3.2 // indirect
 	github.com/shirou/gopsutil v3.21.4-0.20210419000835-c7a38de76ee5+incompatible // indirect
 	github.com/supranational/blst v0.3.16-0.20250831170142-f48500c1fdbe // indirect
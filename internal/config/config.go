@@ -2,12 +2,17 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"log"
+	"math"
+	"math/big"
 	"os"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/vorpalengineering/gundler/internal/logging"
 	"github.com/vorpalengineering/gundler/pkg/types"
 )
 
@@ -30,32 +35,213 @@ func (m Mode) IsValid() bool {
 	}
 }
 
+// Config file read when neither --config nor GUNDLER_CONFIG is set
+const defaultConfigPath = "./config.json"
+
+// Key refill sources
+const (
+	RefillSourceFundingKey  = "funding_key"
+	RefillSourceBeneficiary = "beneficiary"
+)
+
+// Optional validation stages
+const (
+	ValidationStageReputation = "reputation" // reject userOps referencing banned entities
+	ValidationStageSimulation = "simulation" // eth_call handleOps before submitting a bundle
+)
+
+// EntryPointConfig overrides global settings for one EntryPoint. Unset (zero)
+// fields inherit the global value. In supported_entry_points an EntryPoint can
+// also be given as a plain address string.
+type EntryPointConfig struct {
+	Address             string   `json:"address"`
+	MaxBundleSize       uint     `json:"max_bundle_size"`
+	MaxBundleGas        uint64   `json:"max_bundle_gas"`
+	BundlingIntervalMs  uint     `json:"bundling_interval_ms"`
+	BundleSizeThreshold uint     `json:"bundle_size_threshold"`
+	BundleGasThreshold  uint64   `json:"bundle_gas_threshold"`
+	Beneficiary         string   `json:"beneficiary"`
+	Keys                []string `json:"keys"`
+	ValidationStages    []string `json:"validation_stages"`
+}
+
+func (ep *EntryPointConfig) UnmarshalJSON(data []byte) error {
+	// Plain address
+	var address string
+	if err := json.Unmarshal(data, &address); err == nil {
+		*ep = EntryPointConfig{Address: address}
+		return nil
+	}
+
+	// Object, decoded without this method
+	type entryPointConfig EntryPointConfig
+	return json.Unmarshal(data, (*entryPointConfig)(ep))
+}
+
+// HasValidationStage reports whether a validation stage is enabled for the EntryPoint
+func (ep *EntryPointConfig) HasValidationStage(stage string) bool {
+	for _, enabled := range ep.ValidationStages {
+		if enabled == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// RateLimitConfig is a token bucket limit: Rate requests per second with bursts
+// of up to Burst requests
+type RateLimitConfig struct {
+	Rate  float64 `json:"rate"`
+	Burst uint    `json:"burst"`
+}
+
+func (limit RateLimitConfig) String() string {
+	return fmt.Sprintf("%v/s burst %v", limit.Rate, limit.Burst)
+}
+
+// APIKeyConfig is a client API key with optional rate limits by method ("*"
+// for every method without its own limit)
+type APIKeyConfig struct {
+	Name         string                     `json:"name"`
+	Key          string                     `json:"key"`
+	RateLimits   map[string]RateLimitConfig `json:"rate_limits"`    // per key
+	IPRateLimits map[string]RateLimitConfig `json:"ip_rate_limits"` // per client IP using the key
+}
+
+// String shows only the name so printing the config doesn't leak keys
+func (apiKey APIKeyConfig) String() string {
+	return apiKey.Name
+}
+
 type GundlerConfig struct {
-	EthereumRPC          string   `json:"ethereum_rpc"`
-	Port                 uint     `json:"port"`
-	Beneficiary          string   `json:"beneficiary"`
-	SupportedEntryPoints []string `json:"supported_entry_points"`
-	Mode                 Mode     `json:"mode"`
-	MaxBundleSize        uint     `json:"max_bundle_size"`
+	EthereumRPC           string                     `json:"ethereum_rpc" secret:"url"`
+	Port                  uint                       `json:"port"`
+	Host                  string                     `json:"host"`
+	TLSCertFile           string                     `json:"tls_cert_file"`
+	TLSKeyFile            string                     `json:"tls_key_file"`
+	ReadHeaderTimeout     uint                       `json:"read_header_timeout"`
+	ReadTimeout           uint                       `json:"read_timeout"`
+	WriteTimeout          uint                       `json:"write_timeout"`
+	IdleTimeout           uint                       `json:"idle_timeout"`
+	MaxBodySize           uint                       `json:"max_body_size"`
+	AdminListen           string                     `json:"admin_listen"`
+	AdminTokenFile        string                     `json:"admin_token_file"`
+	WSAllowedOrigins      []string                   `json:"ws_allowed_origins"`
+	ShutdownTimeout       uint                       `json:"shutdown_timeout"`
+	Beneficiary           string                     `json:"beneficiary"`
+	SupportedEntryPoints  []EntryPointConfig         `json:"supported_entry_points"`
+	Mode                  Mode                       `json:"mode"`
+	MaxBundleSize         uint                       `json:"max_bundle_size"`
+	MaxBundleGas          uint64                     `json:"max_bundle_gas"`
+	BundlingMode          string                     `json:"bundling_mode"`
+	BundlingIntervalMs    uint                       `json:"bundling_interval_ms"`
+	BundleSizeThreshold   uint                       `json:"bundle_size_threshold"`
+	BundleGasThreshold    uint64                     `json:"bundle_gas_threshold"`
+	MaxMempoolSize        uint                       `json:"max_mempool_size"`
+	MaxOpsPerSender       uint                       `json:"max_ops_per_sender"`
+	MaxOpsPerEntity       uint                       `json:"max_ops_per_entity"`
+	MaxUserOpAge          uint                       `json:"max_userop_age"`
+	DataDir               string                     `json:"data_dir"`
+	SnapshotInterval      uint                       `json:"snapshot_interval"`
+	HistoryRetention      uint                       `json:"history_retention"`
+	HistoryMaxEntries     uint                       `json:"history_max_entries"`
+	MaxBatchSize          uint                       `json:"max_batch_size"`
+	MinKeyBalance         string                     `json:"min_key_balance"`
+	MaxBlockAge           uint                       `json:"max_block_age"`
+	MaxMempoolUsage       uint                       `json:"max_mempool_usage"`
+	RefillSource          string                     `json:"refill_source"`
+	RefillTargetBalance   string                     `json:"refill_target_balance"`
+	FundingKeystore       string                     `json:"funding_keystore"`
+	KeystoreDir           string                     `json:"keystore_dir"`
+	KeystorePasswordFile  string                     `json:"keystore_password_file"`
+	MnemonicFile          string                     `json:"mnemonic_file"`
+	DerivationPath        string                     `json:"derivation_path"`
+	KeyCount              uint                       `json:"key_count"`
+	AllowEnvKeys          bool                       `json:"allow_env_keys"`
+	RemoteSignerURL       string                     `json:"remote_signer_url" secret:"url"`
+	RemoteSignerAddresses []string                   `json:"remote_signer_addresses"`
+	FundingAddress        string                     `json:"funding_address"`
+	KeySelection          string                     `json:"key_selection"`
+	MaxPendingPerKey      uint                       `json:"max_pending_per_key"`
+	GasLimitBufferPercent uint                       `json:"gas_limit_buffer_percent"`
+	GasPriceMarginPercent uint                       `json:"gas_price_margin_percent"`
+	LogLevel              string                     `json:"log_level"`
+	ValidationStages      []string                   `json:"validation_stages"`
+	RequireAPIKey         bool                       `json:"require_api_key"`
+	APIKeys               []APIKeyConfig             `json:"api_keys"`
+	RateLimits            map[string]RateLimitConfig `json:"rate_limits"`
+	TrustForwardedFor     bool                       `json:"trust_forwarded_for"`
+
+	sources      map[string]string     // field name => where its value came from
+	path         string                // config file, re-read by Reload
+	pathRequired bool                  // the config file was set explicitly and must exist
+	fieldFlags   map[string]*fieldFlag // flag overrides, re-applied by Reload
 }
 
+// Load reads the config file, then applies GUNDLER_* environment variable and
+// CLI flag overrides (precedence: flag > env > file > defaults)
 func Load() (*GundlerConfig, error) {
-	// Define config file flag
-	configPath := flag.String("config", "./config.json", "Path to config file")
+	// Define config file flag and a flag for every config field
+	configPath := flag.String("config", "", "Path to config file (env: GUNDLER_CONFIG, default: ./config.json)")
+	fieldFlags := (&GundlerConfig{}).defineFlags(flag.CommandLine)
 
 	// Parse flags
 	flag.Parse()
 
+	// Find config file, the default file is optional
+	path := *configPath
+	if path == "" {
+		path = os.Getenv("GUNDLER_CONFIG")
+	}
+	required := path != ""
+	if path == "" {
+		path = defaultConfigPath
+	}
+
+	return load(path, required, fieldFlags)
+}
+
+// Reload reads the config again from the same file, environment and flags
+func (cfg *GundlerConfig) Reload() (*GundlerConfig, error) {
+	return load(cfg.path, cfg.pathRequired, cfg.fieldFlags)
+}
+
+// Path returns the config file path
+func (cfg *GundlerConfig) Path() string {
+	return cfg.path
+}
+
+func load(path string, required bool, fieldFlags map[string]*fieldFlag) (*GundlerConfig, error) {
+	config := &GundlerConfig{
+		sources:      make(map[string]string),
+		path:         path,
+		pathRequired: required,
+		fieldFlags:   fieldFlags,
+	}
+
 	// Read config file
-	data, err := os.ReadFile(*configPath)
-	if err != nil {
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		// Parse JSON
+		if err := json.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("error parsing config file: %w", err)
+		}
+		if err := config.recordFileSources(data); err != nil {
+			return nil, fmt.Errorf("error parsing config file: %w", err)
+		}
+	case required || !errors.Is(err, os.ErrNotExist):
 		return nil, fmt.Errorf("error reading config file: %w", err)
+	default:
+		log.Printf("No config file at %s, using environment variables and flags", path)
 	}
 
-	// Parse JSON
-	config := &GundlerConfig{}
-	if err := json.Unmarshal(data, config); err != nil {
-		return nil, fmt.Errorf("error parsing config file: %w", err)
+	// Apply overrides
+	if err := config.applyEnv(); err != nil {
+		return nil, fmt.Errorf("error applying environment overrides: %w", err)
+	}
+	if err := config.applyFlags(fieldFlags); err != nil {
+		return nil, fmt.Errorf("error applying flag overrides: %w", err)
 	}
 
 	// Validate config
@@ -78,11 +264,11 @@ func (cfg *GundlerConfig) Validate() error {
 	if len(cfg.SupportedEntryPoints) == 0 {
 		return fmt.Errorf("supported_entry_points must contain at least one entry point address")
 	}
-	for _, epStr := range cfg.SupportedEntryPoints {
-		entryPoint := common.HexToAddress(epStr)
+	for _, ep := range cfg.SupportedEntryPoints {
+		entryPoint := common.HexToAddress(ep.Address)
 		err := types.ValidateEntryPointAddress(entryPoint)
 		if err != nil {
-			return fmt.Errorf("entrypoint address %s is invalid", epStr)
+			return fmt.Errorf("entrypoint address %s is invalid", ep.Address)
 		}
 	}
 	if cfg.Mode == "" {
@@ -92,26 +278,366 @@ func (cfg *GundlerConfig) Validate() error {
 		return fmt.Errorf("mode must be one of: DEBUG, DEV, PROD (got: %s)", cfg.Mode)
 	}
 
+	// Check key source, raw keys from the environment are a DEBUG-only opt-in
+	if cfg.AllowEnvKeys && cfg.Mode != ModeDebug {
+		return fmt.Errorf("allow_env_keys is only permitted in DEBUG mode")
+	}
+	keySources := 0
+	for _, configured := range []bool{cfg.KeystoreDir != "", cfg.MnemonicFile != "", cfg.RemoteSignerURL != "", cfg.AllowEnvKeys} {
+		if configured {
+			keySources++
+		}
+	}
+	if keySources != 1 {
+		return fmt.Errorf("exactly one of keystore_dir, mnemonic_file, remote_signer_url or allow_env_keys is required")
+	}
+	if cfg.MnemonicFile != "" {
+		if cfg.DerivationPath == "" {
+			cfg.DerivationPath = "m/44'/60'/0'/0/0"
+		}
+		if cfg.KeyCount == 0 {
+			cfg.KeyCount = 1
+		}
+	}
+
+	// Set default listener options if not provided
+	if cfg.Host == "" {
+		cfg.Host = "localhost"
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return fmt.Errorf("tls_cert_file and tls_key_file must be set together")
+	}
+	if cfg.ReadHeaderTimeout == 0 {
+		cfg.ReadHeaderTimeout = 10
+	}
+	if cfg.ReadTimeout == 0 {
+		cfg.ReadTimeout = 30
+	}
+	if cfg.WriteTimeout == 0 {
+		cfg.WriteTimeout = 60
+	}
+	if cfg.IdleTimeout == 0 {
+		cfg.IdleTimeout = 120
+	}
+	if cfg.MaxBodySize == 0 {
+		cfg.MaxBodySize = 5 << 20 // 5 MiB
+	}
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = 30
+	}
+	if cfg.AdminListen != "" && cfg.AdminTokenFile == "" {
+		return fmt.Errorf("admin_token_file is required when admin_listen is set")
+	}
+
 	// Set default MaxBundleSize if not provided
 	if cfg.MaxBundleSize == 0 {
 		cfg.MaxBundleSize = 5
 	}
 
+	// Set default bundling options if not provided
+	if cfg.BundlingMode == "" {
+		cfg.BundlingMode = types.BundlingModeAuto
+	}
+	cfg.BundlingMode = strings.ToLower(cfg.BundlingMode)
+	if cfg.BundlingMode != types.BundlingModeAuto && cfg.BundlingMode != types.BundlingModeManual {
+		return fmt.Errorf("bundling_mode must be one of: auto, manual (got: %s)", cfg.BundlingMode)
+	}
+	if cfg.BundlingIntervalMs == 0 {
+		cfg.BundlingIntervalMs = 1000
+	}
+	if cfg.BundleSizeThreshold == 0 {
+		cfg.BundleSizeThreshold = cfg.MaxBundleSize
+	}
+
+	// Set default mempool limits if not provided
+	if cfg.MaxMempoolSize == 0 {
+		cfg.MaxMempoolSize = 4096
+	}
+	if cfg.MaxOpsPerSender == 0 {
+		cfg.MaxOpsPerSender = 4
+	}
+	if cfg.MaxOpsPerEntity == 0 {
+		cfg.MaxOpsPerEntity = 64
+	}
+	if cfg.MaxUserOpAge == 0 {
+		cfg.MaxUserOpAge = 1800
+	}
+
+	// Set default SnapshotInterval if not provided
+	if cfg.SnapshotInterval == 0 {
+		cfg.SnapshotInterval = 60
+	}
+
+	// Set default history retention if not provided
+	if cfg.HistoryRetention == 0 {
+		cfg.HistoryRetention = 7 * 24 * 60 * 60
+	}
+	if cfg.HistoryMaxEntries == 0 {
+		cfg.HistoryMaxEntries = 100000
+	}
+
+	// Set default MaxBatchSize if not provided
+	if cfg.MaxBatchSize == 0 {
+		cfg.MaxBatchSize = 100
+	}
+
+	// Set default readiness thresholds if not provided
+	if cfg.MinKeyBalance == "" {
+		cfg.MinKeyBalance = "10000000000000000" // 0.01 ETH
+	}
+	if balance, ok := new(big.Int).SetString(cfg.MinKeyBalance, 10); !ok || balance.Sign() < 0 {
+		return fmt.Errorf("min_key_balance must be a non-negative integer amount of wei (got: %s)", cfg.MinKeyBalance)
+	}
+	if cfg.MaxBlockAge == 0 {
+		cfg.MaxBlockAge = 60
+	}
+	if cfg.MaxMempoolUsage == 0 {
+		cfg.MaxMempoolUsage = 90
+	}
+	if cfg.MaxMempoolUsage > 100 {
+		return fmt.Errorf("max_mempool_usage must be a percentage between 1 and 100 (got: %d)", cfg.MaxMempoolUsage)
+	}
+
+	// Set default key selection options if not provided
+	if cfg.KeySelection == "" {
+		cfg.KeySelection = "round_robin"
+	}
+	cfg.KeySelection = strings.ToLower(cfg.KeySelection)
+	switch cfg.KeySelection {
+	case "round_robin", "least_recently_used", "highest_balance":
+	default:
+		return fmt.Errorf("key_selection must be one of: round_robin, least_recently_used, highest_balance (got: %s)", cfg.KeySelection)
+	}
+	if cfg.MaxPendingPerKey == 0 {
+		cfg.MaxPendingPerKey = 1
+	}
+
+	// Set default fee margins if not provided
+	if cfg.GasLimitBufferPercent == 0 {
+		cfg.GasLimitBufferPercent = 20
+	}
+
+	// Set default log level if not provided
+	if cfg.LogLevel == "" {
+		cfg.LogLevel = logging.LevelInfo
+	}
+	cfg.LogLevel = strings.ToLower(cfg.LogLevel)
+	if cfg.LogLevel != logging.LevelDebug && cfg.LogLevel != logging.LevelInfo {
+		return fmt.Errorf("log_level must be one of: debug, info (got: %s)", cfg.LogLevel)
+	}
+
+	// No optional validation stages unless configured
+	if cfg.ValidationStages == nil {
+		cfg.ValidationStages = []string{}
+	}
+	if err := validateStages(cfg.ValidationStages); err != nil {
+		return fmt.Errorf("validation_stages: %w", err)
+	}
+
+	// Validate API keys and rate limits
+	if err := cfg.validateAccess(); err != nil {
+		return err
+	}
+
+	// Fill per-EntryPoint settings from the global ones
+	if err := cfg.validateEntryPoints(); err != nil {
+		return err
+	}
+
+	// Validate key refill options
+	cfg.RefillSource = strings.ToLower(cfg.RefillSource)
+	switch cfg.RefillSource {
+	case "":
+	case RefillSourceFundingKey, RefillSourceBeneficiary:
+		if cfg.RefillSource == RefillSourceFundingKey && cfg.FundingKeystore == "" && cfg.FundingAddress == "" && !cfg.AllowEnvKeys {
+			return fmt.Errorf("funding_keystore or funding_address is required when refill_source is funding_key")
+		}
+		if cfg.FundingAddress != "" && cfg.RemoteSignerURL == "" {
+			return fmt.Errorf("funding_address requires remote_signer_url")
+		}
+		if cfg.RefillTargetBalance == "" {
+			cfg.RefillTargetBalance = "100000000000000000" // 0.1 ETH
+		}
+		target, ok := new(big.Int).SetString(cfg.RefillTargetBalance, 10)
+		if !ok || target.Cmp(cfg.MinKeyBalanceWei()) <= 0 {
+			return fmt.Errorf("refill_target_balance must be an amount of wei above min_key_balance (got: %s)", cfg.RefillTargetBalance)
+		}
+	default:
+		return fmt.Errorf("refill_source must be one of: funding_key, beneficiary (got: %s)", cfg.RefillSource)
+	}
+
 	return nil
 }
 
+// Print prints every config field with the source of its value, redacting secrets
 func (cfg *GundlerConfig) Print() {
-	// Print config summary
 	fmt.Println("======= Gundler Config ========")
-	fmt.Printf("Mode: %s\n", cfg.Mode)
-	fmt.Printf("Ethereum RPC: %s\n", cfg.EthereumRPC)
-	fmt.Printf("Port: %v\n", cfg.Port)
-	fmt.Printf("Beneficiary: %v\n", cfg.Beneficiary)
-	fmt.Printf("Supported Entry Points: %v\n", cfg.SupportedEntryPoints)
-	fmt.Printf("Max Bundle Size: %v\n", cfg.MaxBundleSize)
+	for _, field := range cfg.fields() {
+		if field.name != "supported_entry_points" {
+			fmt.Printf("%s: %s (%s)\n", field.name, displayValue(field), cfg.Source(field.name))
+			continue
+		}
+
+		// One line per EntryPoint with its effective settings
+		fmt.Printf("%s: (%s)\n", field.name, cfg.Source(field.name))
+		for _, ep := range cfg.SupportedEntryPoints {
+			keys := "all"
+			if len(ep.Keys) > 0 {
+				keys = strings.Join(ep.Keys, ", ")
+			}
+			fmt.Printf("  %s: max bundle size %v, max bundle gas %v, interval %vms, size threshold %v, gas threshold %v, beneficiary %s, keys %s, validation stages %v\n",
+				ep.Address, ep.MaxBundleSize, ep.MaxBundleGas, ep.BundlingIntervalMs, ep.BundleSizeThreshold, ep.BundleGasThreshold, ep.Beneficiary, keys, ep.ValidationStages)
+		}
+	}
 	fmt.Println("===============================")
 }
 
+// validateEntryPoints checks the per-EntryPoint overrides and fills unset fields with the global values
+func (cfg *GundlerConfig) validateEntryPoints() error {
+	seen := make(map[common.Address]bool, len(cfg.SupportedEntryPoints))
+	for i := range cfg.SupportedEntryPoints {
+		ep := &cfg.SupportedEntryPoints[i]
+
+		// Reject duplicates
+		address := common.HexToAddress(ep.Address)
+		if seen[address] {
+			return fmt.Errorf("entrypoint %s is listed more than once", ep.Address)
+		}
+		seen[address] = true
+
+		// Bundle limits and bundling interval
+		if ep.MaxBundleSize == 0 {
+			ep.MaxBundleSize = cfg.MaxBundleSize
+		}
+		if ep.MaxBundleGas == 0 {
+			ep.MaxBundleGas = cfg.MaxBundleGas
+		}
+		if ep.BundlingIntervalMs == 0 {
+			ep.BundlingIntervalMs = cfg.BundlingIntervalMs
+		}
+		if ep.BundleSizeThreshold == 0 {
+			ep.BundleSizeThreshold = min(cfg.BundleSizeThreshold, ep.MaxBundleSize)
+		}
+		if ep.BundleGasThreshold == 0 {
+			ep.BundleGasThreshold = cfg.BundleGasThreshold
+		}
+
+		// Beneficiary
+		if ep.Beneficiary == "" {
+			ep.Beneficiary = cfg.Beneficiary
+		}
+		if !common.IsHexAddress(ep.Beneficiary) {
+			return fmt.Errorf("entrypoint %s beneficiary %s is not an address", ep.Address, ep.Beneficiary)
+		}
+
+		// Key subset, all keys if empty
+		for _, key := range ep.Keys {
+			if !common.IsHexAddress(key) {
+				return fmt.Errorf("entrypoint %s key %s is not an address", ep.Address, key)
+			}
+		}
+
+		// Validation stages
+		if ep.ValidationStages == nil {
+			ep.ValidationStages = cfg.ValidationStages
+		}
+		if err := validateStages(ep.ValidationStages); err != nil {
+			return fmt.Errorf("entrypoint %s validation_stages: %w", ep.Address, err)
+		}
+	}
+	return nil
+}
+
+// validateAccess checks API keys and fills unset rate limit bursts
+func (cfg *GundlerConfig) validateAccess() error {
+	if cfg.RequireAPIKey && len(cfg.APIKeys) == 0 {
+		return fmt.Errorf("require_api_key needs at least one api_keys entry")
+	}
+	if err := validateRateLimits(cfg.RateLimits); err != nil {
+		return fmt.Errorf("rate_limits: %w", err)
+	}
+
+	names := make(map[string]bool, len(cfg.APIKeys))
+	keys := make(map[string]bool, len(cfg.APIKeys))
+	for i := range cfg.APIKeys {
+		apiKey := &cfg.APIKeys[i]
+		if apiKey.Name == "" || apiKey.Key == "" {
+			return fmt.Errorf("api_keys entry %d needs a name and a key", i)
+		}
+		if strings.ContainsAny(apiKey.Key, "/ ") {
+			return fmt.Errorf("api key %s must not contain slashes or spaces", apiKey.Name)
+		}
+		if names[apiKey.Name] {
+			return fmt.Errorf("api key name %s is listed more than once", apiKey.Name)
+		}
+		if keys[apiKey.Key] {
+			return fmt.Errorf("api key %s reuses the key of another entry", apiKey.Name)
+		}
+		names[apiKey.Name] = true
+		keys[apiKey.Key] = true
+
+		if err := validateRateLimits(apiKey.RateLimits); err != nil {
+			return fmt.Errorf("api key %s rate_limits: %w", apiKey.Name, err)
+		}
+		if err := validateRateLimits(apiKey.IPRateLimits); err != nil {
+			return fmt.Errorf("api key %s ip_rate_limits: %w", apiKey.Name, err)
+		}
+	}
+	return nil
+}
+
+// validateRateLimits checks rates and defaults each burst to one second of requests
+func validateRateLimits(limits map[string]RateLimitConfig) error {
+	for method, limit := range limits {
+		if limit.Rate <= 0 {
+			return fmt.Errorf("%s rate must be above 0 (got: %v)", method, limit.Rate)
+		}
+		if limit.Burst == 0 {
+			limit.Burst = uint(math.Ceil(limit.Rate))
+			limits[method] = limit
+		}
+	}
+	return nil
+}
+
+func validateStages(stages []string) error {
+	for i, stage := range stages {
+		stages[i] = strings.ToLower(stage)
+		switch stages[i] {
+		case ValidationStageReputation, ValidationStageSimulation:
+		default:
+			return fmt.Errorf("unknown stage %s (must be one of: reputation, simulation)", stage)
+		}
+	}
+	return nil
+}
+
+// MinKeyBalanceWei returns min_key_balance as a big integer
+func (cfg *GundlerConfig) MinKeyBalanceWei() *big.Int {
+	balance, _ := new(big.Int).SetString(cfg.MinKeyBalance, 10)
+	return balance
+}
+
+// RefillTargetBalanceWei returns refill_target_balance as a big integer, or nil if refill is disabled
+func (cfg *GundlerConfig) RefillTargetBalanceWei() *big.Int {
+	balance, ok := new(big.Int).SetString(cfg.RefillTargetBalance, 10)
+	if !ok {
+		return nil
+	}
+	return balance
+}
+
+// LoadFundingKey reads the key used to refill bundler keys from the environment
+// (refill_source "funding_key" without funding_keystore, DEBUG only)
+func LoadFundingKey() (string, error) {
+	fundingKey := strings.TrimSpace(os.Getenv("GUNDLER_FUNDING_KEY"))
+	if fundingKey == "" {
+		return "", fmt.Errorf("GUNDLER_FUNDING_KEY environment variable is required when refill_source is funding_key")
+	}
+	return fundingKey, nil
+}
+
+// LoadPrivateKeys reads raw hex keys from the environment (allow_env_keys, DEBUG only)
 func LoadPrivateKeys() ([]string, error) {
 	privKeysEnv := os.Getenv("GUNDLER_PRIV_KEYS")
 	if privKeysEnv == "" {
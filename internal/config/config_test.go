@@ -0,0 +1,180 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+const testConfig = `{
+	"ethereum_rpc": "http://localhost:8545",
+	"beneficiary": "0x00000000000000000000000000000000000000bb",
+	"supported_entry_points": ["0x0000000071727De22E5E9d8BAf0edAc6f37da032"],
+	"mode": "DEBUG",
+	"allow_env_keys": true,
+	"port": 3000,
+	"max_bundle_size": 3,
+	"log_level": "info"
+}`
+
+// loadTest loads testConfig with the given flags set, as if passed on the command line
+func loadTest(t *testing.T, args ...string) (*GundlerConfig, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(testConfig), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	flags := flag.NewFlagSet("gundler", flag.ContinueOnError)
+	fieldFlags := (&GundlerConfig{}).defineFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		t.Fatalf("parse flags: %v", err)
+	}
+	return load(path, true, fieldFlags)
+}
+
+func TestOverridePrecedence(t *testing.T) {
+	t.Setenv("GUNDLER_PORT", "4000")
+	t.Setenv("GUNDLER_MAX_BUNDLE_SIZE", "7")
+	t.Setenv("GUNDLER_SUPPORTED_ENTRY_POINTS", types.EntryPointV07Address.Hex()+", "+types.EntryPointV08Address.Hex())
+
+	cfg, err := loadTest(t, "--port", "5000")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		got    any
+		want   any
+		source string
+	}{
+		{"port", cfg.Port, uint(5000), SourceFlag},
+		{"max_bundle_size", cfg.MaxBundleSize, uint(7), SourceEnv},
+		{"log_level", cfg.LogLevel, "info", SourceFile},
+		{"max_mempool_size", cfg.MaxMempoolSize, uint(4096), SourceDefault},
+	}
+	for _, test := range tests {
+		if test.got != test.want || cfg.Source(test.name) != test.source {
+			t.Fatalf("%s = %v from %s, want %v from %s", test.name, test.got, cfg.Source(test.name), test.want, test.source)
+		}
+	}
+
+	// A list from the environment replaces the file's list
+	if len(cfg.SupportedEntryPoints) != 2 || cfg.SupportedEntryPoints[1].Address != types.EntryPointV08Address.Hex() {
+		t.Fatalf("supported_entry_points = %+v, want both entry points from the environment", cfg.SupportedEntryPoints)
+	}
+
+	// Reload applies the same overrides again
+	reloaded, err := cfg.Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if reloaded.Port != 5000 || reloaded.MaxBundleSize != 7 {
+		t.Fatalf("reloaded port %d, max_bundle_size %d, want the overrides kept", reloaded.Port, reloaded.MaxBundleSize)
+	}
+}
+
+func TestInvalidOverride(t *testing.T) {
+	t.Setenv("GUNDLER_PORT", "not a port")
+	if _, err := loadTest(t); err == nil || !strings.Contains(err.Error(), "GUNDLER_PORT") {
+		t.Fatalf("load err = %v, want invalid GUNDLER_PORT", err)
+	}
+}
+
+func TestAllowEnvKeysRequiresDebug(t *testing.T) {
+	if _, err := loadTest(t); err != nil {
+		t.Fatalf("load in DEBUG mode: %v", err)
+	}
+
+	// An override can't leave allow_env_keys on outside DEBUG mode
+	for _, mode := range []string{"DEV", "PROD"} {
+		t.Run(mode, func(t *testing.T) {
+			t.Setenv("GUNDLER_MODE", mode)
+			if _, err := loadTest(t); err == nil || !strings.Contains(err.Error(), "allow_env_keys") {
+				t.Fatalf("load err = %v, want allow_env_keys rejected", err)
+			}
+		})
+	}
+}
+
+func TestEntryPointOverrides(t *testing.T) {
+	v07, v08 := types.EntryPointV07Address.Hex(), types.EntryPointV08Address.Hex()
+	key := "0x00000000000000000000000000000000000000cc"
+	t.Setenv("GUNDLER_SUPPORTED_ENTRY_POINTS", `["`+v07+`", {"address": "`+v08+`", "max_bundle_size": 10, "bundling_interval_ms": 250,
+		"beneficiary": "0x00000000000000000000000000000000000000dd", "keys": ["`+key+`"], "validation_stages": ["simulation"]}]`)
+
+	cfg, err := loadTest(t)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	inherited, overridden := cfg.SupportedEntryPoints[0], cfg.SupportedEntryPoints[1]
+
+	// A plain address inherits every global setting
+	if inherited.MaxBundleSize != 3 || inherited.BundlingIntervalMs != 1000 || inherited.BundleSizeThreshold != 3 ||
+		inherited.Beneficiary != cfg.Beneficiary || len(inherited.Keys) != 0 || len(inherited.ValidationStages) != 0 {
+		t.Fatalf("plain entry point = %+v, want the global settings", inherited)
+	}
+
+	// Overrides apply to their entry point only, the size threshold stays the global one
+	if overridden.MaxBundleSize != 10 || overridden.BundlingIntervalMs != 250 || overridden.BundleSizeThreshold != 3 ||
+		overridden.Beneficiary != "0x00000000000000000000000000000000000000dd" || len(overridden.Keys) != 1 || overridden.Keys[0] != key {
+		t.Fatalf("overridden entry point = %+v, want its own settings", overridden)
+	}
+	if !overridden.HasValidationStage(ValidationStageSimulation) || overridden.HasValidationStage(ValidationStageReputation) {
+		t.Fatalf("overridden validation stages = %v, want simulation only", overridden.ValidationStages)
+	}
+}
+
+func TestInvalidEntryPointOverrides(t *testing.T) {
+	v07 := types.EntryPointV07Address.Hex()
+	tests := []struct {
+		name        string
+		entryPoints string
+	}{
+		{"duplicate", `["` + v07 + `", "` + strings.ToLower(v07) + `"]`},
+		{"invalid beneficiary", `[{"address": "` + v07 + `", "beneficiary": "nobody"}]`},
+		{"invalid key", `[{"address": "` + v07 + `", "keys": ["0x1234"]}]`},
+		{"unknown validation stage", `[{"address": "` + v07 + `", "validation_stages": ["magic"]}]`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("GUNDLER_SUPPORTED_ENTRY_POINTS", test.entryPoints)
+			if _, err := loadTest(t); err == nil {
+				t.Fatalf("load accepted %s", test.entryPoints)
+			}
+		})
+	}
+}
+
+func TestEntryPointReload(t *testing.T) {
+	v07, v08 := types.EntryPointV07Address.Hex(), types.EntryPointV08Address.Hex()
+	t.Setenv("GUNDLER_SUPPORTED_ENTRY_POINTS", `[{"address": "`+v07+`", "max_bundle_size": 4}]`)
+	cfg, err := loadTest(t)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	// Per-entry point settings reload, the set of entry points needs a restart
+	t.Setenv("GUNDLER_SUPPORTED_ENTRY_POINTS", `[{"address": "`+v07+`", "max_bundle_size": 8}]`)
+	tuned, err := cfg.Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if restart := cfg.RestartRequired(tuned); len(restart) != 0 {
+		t.Fatalf("changing max_bundle_size requires a restart of %v", restart)
+	}
+
+	t.Setenv("GUNDLER_SUPPORTED_ENTRY_POINTS", v07+","+v08)
+	added, err := cfg.Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if restart := cfg.RestartRequired(added); len(restart) != 1 || !strings.HasPrefix(restart[0], "supported_entry_points") {
+		t.Fatalf("adding an entry point requires a restart of %v, want supported_entry_points", restart)
+	}
+}
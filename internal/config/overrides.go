@@ -0,0 +1,218 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Where a config value came from, in increasing precedence
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Prefix of the environment variable overriding each config field
+const envPrefix = "GUNDLER_"
+
+// configField is a GundlerConfig field and its json name
+type configField struct {
+	name   string
+	secret string
+	value  reflect.Value
+}
+
+// fields returns the config fields in declaration order
+func (cfg *GundlerConfig) fields() []configField {
+	configValue := reflect.ValueOf(cfg).Elem()
+	configType := configValue.Type()
+
+	fields := make([]configField, 0, configType.NumField())
+	for i := 0; i < configType.NumField(); i++ {
+		name, _, _ := strings.Cut(configType.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fields = append(fields, configField{
+			name:   name,
+			secret: configType.Field(i).Tag.Get("secret"),
+			value:  configValue.Field(i),
+		})
+	}
+	return fields
+}
+
+// EnvName returns the environment variable overriding a config field
+func EnvName(name string) string {
+	return envPrefix + strings.ToUpper(name)
+}
+
+// FlagName returns the CLI flag overriding a config field
+func FlagName(name string) string {
+	return strings.ReplaceAll(name, "_", "-")
+}
+
+// fieldFlag is a CLI flag for a config field, applied after the config file is read
+type fieldFlag struct {
+	isBool bool
+	value  string
+	set    bool
+}
+
+func (f *fieldFlag) String() string {
+	return f.value
+}
+
+func (f *fieldFlag) Set(value string) error {
+	f.value = value
+	f.set = true
+	return nil
+}
+
+func (f *fieldFlag) IsBoolFlag() bool {
+	return f.isBool
+}
+
+// defineFlags defines a flag for every config field on flags
+func (cfg *GundlerConfig) defineFlags(flags *flag.FlagSet) map[string]*fieldFlag {
+	fieldFlags := make(map[string]*fieldFlag)
+	for _, field := range cfg.fields() {
+		fieldFlag := &fieldFlag{isBool: field.value.Kind() == reflect.Bool}
+		flags.Var(fieldFlag, FlagName(field.name), fmt.Sprintf("Overrides %s (env: %s)", field.name, EnvName(field.name)))
+		fieldFlags[field.name] = fieldFlag
+	}
+	return fieldFlags
+}
+
+// recordFileSources marks the fields present in the config file
+func (cfg *GundlerConfig) recordFileSources(data []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	for name := range members {
+		cfg.sources[name] = SourceFile
+	}
+	return nil
+}
+
+// applyEnv overrides fields set in GUNDLER_* environment variables
+func (cfg *GundlerConfig) applyEnv() error {
+	for _, field := range cfg.fields() {
+		value, exists := os.LookupEnv(EnvName(field.name))
+		if !exists {
+			continue
+		}
+		if err := setField(field.value, value); err != nil {
+			return fmt.Errorf("invalid %s: %w", EnvName(field.name), err)
+		}
+		cfg.sources[field.name] = SourceEnv
+	}
+	return nil
+}
+
+// applyFlags overrides fields set with CLI flags
+func (cfg *GundlerConfig) applyFlags(fieldFlags map[string]*fieldFlag) error {
+	for _, field := range cfg.fields() {
+		fieldFlag := fieldFlags[field.name]
+		if fieldFlag == nil || !fieldFlag.set {
+			continue
+		}
+		if err := setField(field.value, fieldFlag.value); err != nil {
+			return fmt.Errorf("invalid --%s: %w", FlagName(field.name), err)
+		}
+		cfg.sources[field.name] = SourceFlag
+	}
+	return nil
+}
+
+// setField parses value into a config field. Lists are comma-separated or a
+// JSON array as in the config file; maps are a JSON object.
+func setField(field reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(parsed)
+	case reflect.Uint, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetUint(parsed)
+	case reflect.Slice:
+		if strings.HasPrefix(value, "[") {
+			return json.Unmarshal([]byte(value), field.Addr().Interface())
+		}
+		items := make([]string, 0)
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		data, err := json.Marshal(items)
+		if err != nil {
+			return err
+		}
+		// Elements decode from strings (EntryPointConfig accepts plain addresses)
+		field.Set(reflect.Zero(field.Type()))
+		return json.Unmarshal(data, field.Addr().Interface())
+	case reflect.Map:
+		field.Set(reflect.Zero(field.Type()))
+		return json.Unmarshal([]byte(value), field.Addr().Interface())
+	default:
+		return fmt.Errorf("unsupported config field type %s", field.Type())
+	}
+	return nil
+}
+
+// Source returns where a config field's value came from
+func (cfg *GundlerConfig) Source(name string) string {
+	if source, exists := cfg.sources[name]; exists {
+		return source
+	}
+	return SourceDefault
+}
+
+// displayValue formats a field for Print, redacting secrets
+func displayValue(field configField) string {
+	value := fmt.Sprintf("%v", field.value.Interface())
+	if field.value.Kind() == reflect.String && value == "" {
+		return "(unset)"
+	}
+
+	switch field.secret {
+	case "":
+		return value
+	case "url":
+		return redactURL(value)
+	default:
+		return "(redacted)"
+	}
+}
+
+// redactURL keeps the scheme and host of a URL, hiding credentials, paths and
+// query strings which often carry API keys
+func redactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return "(redacted)"
+	}
+	if parsed.User == nil && (parsed.Path == "" || parsed.Path == "/") && parsed.RawQuery == "" {
+		return rawURL
+	}
+	return parsed.Scheme + "://" + parsed.Host + "/(redacted)"
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Fields applied on reload without a restart. Changes to any other field are
+// rejected until gundler is restarted.
+var reloadableFields = map[string]bool{
+	"beneficiary":              true,
+	"supported_entry_points":   true, // settings only, the set of EntryPoints is fixed
+	"max_bundle_size":          true,
+	"max_bundle_gas":           true,
+	"bundling_mode":            true,
+	"bundling_interval_ms":     true,
+	"bundle_size_threshold":    true,
+	"bundle_gas_threshold":     true,
+	"validation_stages":        true,
+	"max_mempool_size":         true,
+	"max_ops_per_sender":       true,
+	"max_ops_per_entity":       true,
+	"max_userop_age":           true,
+	"max_batch_size":           true,
+	"max_block_age":            true,
+	"max_mempool_usage":        true,
+	"key_selection":            true,
+	"max_pending_per_key":      true,
+	"gas_limit_buffer_percent": true,
+	"gas_price_margin_percent": true,
+	"log_level":                true,
+	"keystore_dir":             true,
+	"keystore_password_file":   true,
+	"mnemonic_file":            true,
+	"derivation_path":          true,
+	"key_count":                true,
+	"remote_signer_url":        true,
+	"remote_signer_addresses":  true,
+	"require_api_key":          true,
+	"api_keys":                 true,
+	"rate_limits":              true,
+	"trust_forwarded_for":      true,
+	"shutdown_timeout":         true,
+}
+
+// Changed returns the fields whose values differ in next
+func (cfg *GundlerConfig) Changed(next *GundlerConfig) []string {
+	current, updated := cfg.fields(), next.fields()
+
+	changed := make([]string, 0)
+	for i := range current {
+		if !reflect.DeepEqual(current[i].value.Interface(), updated[i].value.Interface()) {
+			changed = append(changed, current[i].name)
+		}
+	}
+	return changed
+}
+
+// RestartRequired returns the fields changed in next that cannot be applied
+// without a restart
+func (cfg *GundlerConfig) RestartRequired(next *GundlerConfig) []string {
+	fields := make([]string, 0)
+	for _, name := range cfg.Changed(next) {
+		switch {
+		case !reloadableFields[name]:
+			fields = append(fields, name)
+		case name == "supported_entry_points" && !sameEntryPoints(cfg.SupportedEntryPoints, next.SupportedEntryPoints):
+			fields = append(fields, "supported_entry_points (adding or removing entry points)")
+		case name == "beneficiary" && cfg.RefillSource == RefillSourceBeneficiary:
+			fields = append(fields, "beneficiary (refill funding key)")
+		}
+	}
+	return fields
+}
+
+// sameEntryPoints reports whether two EntryPoint lists have the same addresses
+func sameEntryPoints(a []EntryPointConfig, b []EntryPointConfig) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	addresses := make(map[common.Address]bool, len(a))
+	for _, ep := range a {
+		addresses[common.HexToAddress(ep.Address)] = true
+	}
+	for _, ep := range b {
+		if !addresses[common.HexToAddress(ep.Address)] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+package events
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+// Event types
+const (
+	TypeUserOpAdded  = "userOpAdded"
+	TypeUserOpStatus = "userOpStatus"
+	TypeBundle       = "bundle"
+)
+
+// UserOp statuses
+const (
+	StatusPending   = types.UserOperationStatusPending
+	StatusSubmitted = types.UserOperationStatusSubmitted
+	StatusIncluded  = types.UserOperationStatusIncluded
+	StatusFailed    = types.UserOperationStatusFailed
+	StatusDropped   = types.UserOperationStatusDropped
+)
+
+type Event struct {
+	Type         string
+	EntryPoint   common.Address
+	UserOpHash   common.Hash
+	UserOp       *types.UserOperation
+	Status       string
+	TxHash       common.Hash
+	UserOpHashes []common.Hash
+}
+
+// Feed fans out events to subscribers. Publishing never blocks: events are
+// dropped for subscribers whose buffer is full. A nil *Feed is valid and
+// publishes nothing.
+type Feed struct {
+	mutex       sync.RWMutex
+	subscribers map[chan Event]struct{}
+}
+
+func NewFeed() *Feed {
+	return &Feed{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving every published event
+func (feed *Feed) Subscribe(buffer int) chan Event {
+	feed.mutex.Lock()
+	defer feed.mutex.Unlock()
+
+	ch := make(chan Event, buffer)
+	feed.subscribers[ch] = struct{}{}
+	return ch
+}
+
+// Unsubscribe stops delivery to and closes the channel
+func (feed *Feed) Unsubscribe(ch chan Event) {
+	feed.mutex.Lock()
+	defer feed.mutex.Unlock()
+
+	if _, exists := feed.subscribers[ch]; exists {
+		delete(feed.subscribers, ch)
+		close(ch)
+	}
+}
+
+func (feed *Feed) Publish(event Event) {
+	if feed == nil {
+		return
+	}
+
+	feed.mutex.RLock()
+	defer feed.mutex.RUnlock()
+
+	for ch := range feed.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// PublishStatus publishes a status change for each userOp
+func (feed *Feed) PublishStatus(entryPoint common.Address, status string, txHash common.Hash, userOpHashes ...common.Hash) {
+	for _, userOpHash := range userOpHashes {
+		feed.Publish(Event{
+			Type:       TypeUserOpStatus,
+			EntryPoint: entryPoint,
+			UserOpHash: userOpHash,
+			Status:     status,
+			TxHash:     txHash,
+		})
+	}
+}
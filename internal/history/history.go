@@ -0,0 +1,427 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+// UserOp outcomes
+const (
+	StatusSuccess  = "success"
+	StatusReverted = "reverted"
+	StatusFailed   = "failed"
+	StatusDropped  = "dropped"
+)
+
+const (
+	historyFileName = "history.log"
+	pruneInterval   = time.Minute
+)
+
+// Entry is the indexed outcome of a bundled userOp
+type Entry struct {
+	UserOpHash    common.Hash          `json:"userOpHash"`
+	EntryPoint    common.Address       `json:"entryPoint"`
+	Sender        common.Address       `json:"sender"`
+	Nonce         *hexutil.Big         `json:"nonce"`
+	Paymaster     common.Address       `json:"paymaster"`
+	UserOp        *types.UserOperation `json:"userOp,omitempty"`
+	TxHash        common.Hash          `json:"txHash"`
+	BlockHash     common.Hash          `json:"blockHash"`
+	BlockNumber   uint64               `json:"blockNumber"`
+	Status        string               `json:"status"`
+	ActualGasCost *hexutil.Big         `json:"actualGasCost"`
+	ActualGasUsed *hexutil.Big         `json:"actualGasUsed"`
+	Reason        hexutil.Bytes        `json:"reason,omitempty"`
+	Logs          []*ethtypes.Log      `json:"logs,omitempty"`
+	TxReceipt     *ethtypes.Receipt    `json:"-"` // shared by the entries of a bundle, stored once per transaction
+	ConfirmedAt   time.Time            `json:"confirmedAt"`
+}
+
+// Included reports whether the userOp was executed on chain (successfully or not)
+func (entry *Entry) Included() bool {
+	return entry.Status == StatusSuccess || entry.Status == StatusReverted
+}
+
+// record is a line of the log file: either a bundle transaction receipt, written
+// before the first entry that refers to it, or an entry
+type record struct {
+	Receipt *ethtypes.Receipt `json:"txReceipt,omitempty"`
+	*Entry
+}
+
+// Retention bounds how long and how many entries are kept. Zero values disable a limit.
+type Retention struct {
+	MaxAge     time.Duration
+	MaxEntries int
+}
+
+// Store is an embedded key-value index of userOpHash => Entry with a secondary
+// index by sender. Bundle transaction receipts are kept once per transaction
+// hash for as long as an entry refers to them. Entries are appended to a log file
+// in the data directory and the log is rewritten when pruned records make up most
+// of it. A Store opened without a directory keeps entries in memory only.
+type Store struct {
+	mutex       sync.RWMutex
+	dir         string
+	file        *os.File
+	writer      *bufio.Writer
+	fileRecords int
+	entries     map[common.Hash]*Entry
+	bySender    map[common.Address][]common.Hash
+	receipts    map[common.Hash]*ethtypes.Receipt // bundle transaction receipts by transaction hash
+	receiptRefs map[common.Hash]int               // number of entries referring to each receipt
+	retention   Retention
+	stopChannel chan struct{}
+	doneChannel chan struct{}
+}
+
+// Open loads the index from dir (or creates an in-memory index if dir is empty)
+// and starts pruning entries according to the retention policy
+func Open(dir string, retention Retention) (*Store, error) {
+	store := &Store{
+		dir:         dir,
+		entries:     make(map[common.Hash]*Entry),
+		bySender:    make(map[common.Address][]common.Hash),
+		receipts:    make(map[common.Hash]*ethtypes.Receipt),
+		receiptRefs: make(map[common.Hash]int),
+		retention:   retention,
+		stopChannel: make(chan struct{}),
+		doneChannel: make(chan struct{}),
+	}
+
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create history directory: %w", err)
+		}
+		if err := store.load(); err != nil {
+			return nil, err
+		}
+		file, err := os.OpenFile(filepath.Join(dir, historyFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open history: %w", err)
+		}
+		store.file = file
+		store.writer = bufio.NewWriter(file)
+		log.Printf("History index opened at %s (entries: %d)", dir, len(store.entries))
+	}
+
+	store.Prune()
+
+	go store.run()
+
+	return store, nil
+}
+
+// Close stops pruning and closes the log file
+func (store *Store) Close() error {
+	close(store.stopChannel)
+	<-store.doneChannel
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.file == nil {
+		return nil
+	}
+	if err := store.writer.Flush(); err != nil {
+		store.file.Close()
+		return fmt.Errorf("failed to flush history: %w", err)
+	}
+
+	return store.file.Close()
+}
+
+// Put indexes entries, replacing existing entries with the same userOpHash
+func (store *Store) Put(entries ...*Entry) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// Receipts not stored yet are written once, before the entries referring to them
+	var records []record
+	for _, entry := range entries {
+		if entry.TxReceipt != nil && store.receiptRefs[entry.TxHash] == 0 {
+			records = append(records, record{Receipt: entry.TxReceipt})
+		}
+		store.index(entry)
+		records = append(records, record{Entry: entry})
+	}
+
+	if store.file == nil {
+		return nil
+	}
+	for _, rec := range records {
+		if err := writeRecord(store.writer, rec); err != nil {
+			return err
+		}
+		store.fileRecords++
+	}
+	if err := store.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to flush history: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns the entry for a userOpHash
+func (store *Store) Get(userOpHash common.Hash) (*Entry, bool) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	entry, exists := store.entries[userOpHash]
+	return entry, exists
+}
+
+// GetBySender returns up to limit entries for a sender, most recent first.
+// A limit of zero returns every entry.
+func (store *Store) GetBySender(sender common.Address, limit int) []*Entry {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	hashes := store.bySender[sender]
+	if limit <= 0 || limit > len(hashes) {
+		limit = len(hashes)
+	}
+
+	entries := make([]*Entry, 0, limit)
+	for i := len(hashes) - 1; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, store.entries[hashes[i]])
+	}
+
+	return entries
+}
+
+// Size returns the number of indexed entries
+func (store *Store) Size() int {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	return len(store.entries)
+}
+
+// Prune removes entries outside the retention policy and compacts the log
+// file when most of it is pruned entries. Returns the number of entries removed.
+func (store *Store) Prune() int {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// Order entries oldest first
+	entries := make([]*Entry, 0, len(store.entries))
+	for _, entry := range store.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(a, b int) bool { return entries[a].ConfirmedAt.Before(entries[b].ConfirmedAt) })
+
+	// Drop entries beyond max entries or max age
+	drop := 0
+	if store.retention.MaxEntries > 0 && len(entries) > store.retention.MaxEntries {
+		drop = len(entries) - store.retention.MaxEntries
+	}
+	if store.retention.MaxAge > 0 {
+		cutoff := time.Now().Add(-store.retention.MaxAge)
+		for drop < len(entries) && entries[drop].ConfirmedAt.Before(cutoff) {
+			drop++
+		}
+	}
+	for _, entry := range entries[:drop] {
+		store.unindex(entry)
+	}
+
+	// Compact log file once pruned records outnumber live ones
+	if store.file != nil && store.fileRecords > 2*(len(store.entries)+len(store.receipts)) {
+		if err := store.compact(); err != nil {
+			log.Printf("History compaction error: %v", err)
+		}
+	}
+
+	if drop > 0 {
+		log.Printf("Pruned %d history entries (remaining: %d)", drop, len(store.entries))
+	}
+
+	return drop
+}
+
+func (store *Store) run() {
+	defer close(store.doneChannel)
+
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-store.stopChannel:
+			return
+		case <-ticker.C:
+			store.Prune()
+		}
+	}
+}
+
+// index adds an entry to the in-memory indices. Caller must hold the write lock.
+func (store *Store) index(entry *Entry) {
+	if existing, exists := store.entries[entry.UserOpHash]; exists {
+		store.unindex(existing)
+	}
+	store.entries[entry.UserOpHash] = entry
+	store.bySender[entry.Sender] = append(store.bySender[entry.Sender], entry.UserOpHash)
+
+	// Share a single receipt between the entries of a transaction
+	if receipt, exists := store.receipts[entry.TxHash]; exists {
+		entry.TxReceipt = receipt
+	} else if entry.TxReceipt != nil {
+		store.receipts[entry.TxHash] = entry.TxReceipt
+	}
+	if entry.TxReceipt != nil {
+		store.receiptRefs[entry.TxHash]++
+	}
+}
+
+// unindex removes an entry from the in-memory indices. Caller must hold the write lock.
+func (store *Store) unindex(entry *Entry) {
+	delete(store.entries, entry.UserOpHash)
+
+	if entry.TxReceipt != nil {
+		store.receiptRefs[entry.TxHash]--
+		if store.receiptRefs[entry.TxHash] <= 0 {
+			delete(store.receipts, entry.TxHash)
+			delete(store.receiptRefs, entry.TxHash)
+		}
+	}
+
+	hashes := store.bySender[entry.Sender]
+	for i, userOpHash := range hashes {
+		if userOpHash == entry.UserOpHash {
+			hashes = append(hashes[:i], hashes[i+1:]...)
+			break
+		}
+	}
+	if len(hashes) == 0 {
+		delete(store.bySender, entry.Sender)
+	} else {
+		store.bySender[entry.Sender] = hashes
+	}
+}
+
+func (store *Store) load() error {
+	file, err := os.Open(filepath.Join(store.dir, historyFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// A crash mid-write can leave a partial last line
+			log.Printf("History load stopped at corrupt record %d: %v", store.fileRecords+1, err)
+			break
+		}
+		switch {
+		case rec.Receipt != nil:
+			// Indexed by the first entry that refers to it
+			store.receipts[rec.Receipt.TxHash] = rec.Receipt
+		case rec.Entry != nil:
+			store.index(rec.Entry)
+		}
+		store.fileRecords++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+
+	// Drop receipts whose entries were all replaced
+	for txHash := range store.receipts {
+		if store.receiptRefs[txHash] == 0 {
+			delete(store.receipts, txHash)
+		}
+	}
+
+	return nil
+}
+
+// compact rewrites the log file with only live entries. Caller must hold the write lock.
+func (store *Store) compact() error {
+	path := filepath.Join(store.dir, historyFileName)
+	tmpPath := path + ".tmp"
+
+	tmpFile, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(tmpFile)
+	records := make([]record, 0, len(store.receipts)+len(store.entries))
+	for _, receipt := range store.receipts {
+		records = append(records, record{Receipt: receipt})
+	}
+	for _, entry := range store.entries {
+		records = append(records, record{Entry: entry})
+	}
+	for _, rec := range records {
+		if err := writeRecord(writer, rec); err != nil {
+			tmpFile.Close()
+			os.Remove(tmpPath)
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	// Swap files and reopen for appending
+	store.writer.Flush()
+	store.file.Close()
+	renameErr := os.Rename(tmpPath, path)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	store.file = file
+	store.writer = bufio.NewWriter(file)
+	if renameErr != nil {
+		return renameErr
+	}
+	store.fileRecords = len(records)
+
+	return nil
+}
+
+// writeRecord encodes a record as a line of the log file
+func writeRecord(writer *bufio.Writer, rec record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode history record: %w", err)
+	}
+	if _, err := writer.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write history record: %w", err)
+	}
+	return nil
+}
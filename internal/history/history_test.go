@@ -0,0 +1,150 @@
+package history
+
+import (
+	"bytes"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+func testEntry(userOpHash common.Hash, confirmedAt time.Time) *Entry {
+	return &Entry{
+		UserOpHash:    userOpHash,
+		Sender:        common.HexToAddress("0x1111111111111111111111111111111111111111"),
+		Nonce:         (*hexutil.Big)(big.NewInt(0)),
+		TxHash:        common.HexToHash("0xaa"),
+		Status:        StatusSuccess,
+		ActualGasCost: (*hexutil.Big)(big.NewInt(1000)),
+		ActualGasUsed: (*hexutil.Big)(big.NewInt(100)),
+		TxReceipt: &ethtypes.Receipt{
+			Status:      ethtypes.ReceiptStatusSuccessful,
+			TxHash:      common.HexToHash("0xaa"),
+			BlockNumber: big.NewInt(1),
+			Logs:        []*ethtypes.Log{},
+		},
+		ConfirmedAt: confirmedAt,
+	}
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return bytes.Count(data, []byte{'\n'})
+}
+
+func TestPruneCompactsLog(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir, Retention{MaxEntries: 2})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	// Seven entries, oldest first, of which only the two newest are retained
+	start := time.Now().Add(-time.Hour)
+	for i := range 7 {
+		if err := store.Put(testEntry(common.BigToHash(big.NewInt(int64(i))), start.Add(time.Duration(i)*time.Minute))); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	path := filepath.Join(dir, historyFileName)
+	// The shared transaction receipt is written once, before the first entry
+	if lines := countLines(t, path); lines != 8 {
+		t.Fatalf("log has %d records before pruning, want 8", lines)
+	}
+
+	if pruned := store.Prune(); pruned != 5 {
+		t.Fatalf("Prune removed %d entries, want 5", pruned)
+	}
+	if lines := countLines(t, path); lines != 3 {
+		t.Fatalf("log has %d records after compaction, want 3", lines)
+	}
+
+	// The compacted log stays appendable and reloads with the stored receipts
+	if err := store.Put(testEntry(common.BigToHash(big.NewInt(7)), time.Now())); err != nil {
+		t.Fatalf("Put after compaction: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := Open(dir, Retention{})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+
+	if size := reopened.Size(); size != 3 {
+		t.Fatalf("reopened store has %d entries, want 3", size)
+	}
+	for _, i := range []int64{0, 1, 2, 3, 4} {
+		if _, exists := reopened.Get(common.BigToHash(big.NewInt(i))); exists {
+			t.Fatalf("pruned entry %d was reloaded", i)
+		}
+	}
+	entry, exists := reopened.Get(common.BigToHash(big.NewInt(6)))
+	if !exists {
+		t.Fatalf("retained entry 6 was not reloaded")
+	}
+	if receipt := entry.Receipt(); receipt.Receipt == nil || receipt.Receipt.TxHash != entry.TxHash {
+		t.Fatalf("reloaded entry receipt = %+v, want the stored transaction receipt", receipt.Receipt)
+	}
+}
+
+func TestReceiptStoredOncePerTransaction(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir, Retention{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	// Two userOps of the same bundle, each built with its own copy of the receipt
+	first := testEntry(common.HexToHash("0x01"), time.Now())
+	second := testEntry(common.HexToHash("0x02"), time.Now())
+	if err := store.Put(first, second); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	path := filepath.Join(dir, historyFileName)
+	if lines := countLines(t, path); lines != 3 {
+		t.Fatalf("log has %d records, want one receipt and two entries", lines)
+	}
+	if first.TxReceipt != second.TxReceipt {
+		t.Fatalf("entries of a transaction do not share its receipt")
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := Open(dir, Retention{MaxEntries: 1})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+
+	// The receipt outlives a pruned entry while another entry refers to it
+	if size := reopened.Size(); size != 1 {
+		t.Fatalf("reopened store has %d entries, want 1", size)
+	}
+	if len(reopened.receipts) != 1 {
+		t.Fatalf("reopened store has %d receipts, want 1", len(reopened.receipts))
+	}
+	for _, entry := range reopened.entries {
+		if entry.TxReceipt == nil || entry.TxReceipt.TxHash != entry.TxHash {
+			t.Fatalf("reloaded entry receipt = %+v, want the stored transaction receipt", entry.TxReceipt)
+		}
+		reopened.mutex.Lock()
+		reopened.unindex(entry)
+		reopened.mutex.Unlock()
+	}
+	if len(reopened.receipts) != 0 {
+		t.Fatalf("receipt kept after its last entry was removed")
+	}
+}
@@ -0,0 +1,141 @@
+package history
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+var (
+	userOperationEventID        = types.EntryPointEventsABI.Events["UserOperationEvent"].ID
+	userOperationRevertReasonID = types.EntryPointEventsABI.Events["UserOperationRevertReason"].ID
+	beforeExecutionID           = types.EntryPointEventsABI.Events["BeforeExecution"].ID
+)
+
+// EntriesFromReceipt builds an entry for every userOp of a bundle from the bundle
+// transaction receipt. UserOps without a UserOperationEvent (or all of them when the
+// receipt is nil) get the fallback status.
+func EntriesFromReceipt(
+	entryPoint common.Address,
+	chainID *big.Int,
+	userOps []*types.UserOperation,
+	txHash common.Hash,
+	receipt *ethtypes.Receipt,
+	fallbackStatus string,
+) []*Entry {
+	now := time.Now()
+	entries := make(map[common.Hash]*Entry, len(userOps))
+
+	if receipt != nil {
+		reasons := make(map[common.Hash][]byte)
+		start := 0
+		for i, eventLog := range receipt.Logs {
+			if eventLog.Address != entryPoint || len(eventLog.Topics) == 0 {
+				continue
+			}
+
+			switch eventLog.Topics[0] {
+			case beforeExecutionID:
+				// Logs before execution belong to account deployment and validation
+				start = i + 1
+			case userOperationRevertReasonID:
+				values, err := types.EntryPointEventsABI.Unpack("UserOperationRevertReason", eventLog.Data)
+				if err == nil && len(eventLog.Topics) > 1 {
+					reasons[eventLog.Topics[1]] = values[1].([]byte)
+				}
+			case userOperationEventID:
+				values, err := types.EntryPointEventsABI.Unpack("UserOperationEvent", eventLog.Data)
+				if err != nil || len(eventLog.Topics) < 4 {
+					continue
+				}
+				userOpHash := eventLog.Topics[1]
+				status := StatusSuccess
+				if !values[1].(bool) {
+					status = StatusReverted
+				}
+				entries[userOpHash] = &Entry{
+					UserOpHash:    userOpHash,
+					EntryPoint:    entryPoint,
+					Sender:        common.BytesToAddress(eventLog.Topics[2].Bytes()),
+					Nonce:         (*hexutil.Big)(values[0].(*big.Int)),
+					Paymaster:     common.BytesToAddress(eventLog.Topics[3].Bytes()),
+					TxHash:        txHash,
+					BlockHash:     receipt.BlockHash,
+					BlockNumber:   receipt.BlockNumber.Uint64(),
+					Status:        status,
+					ActualGasCost: (*hexutil.Big)(values[2].(*big.Int)),
+					ActualGasUsed: (*hexutil.Big)(values[3].(*big.Int)),
+					Reason:        reasons[userOpHash],
+					Logs:          receipt.Logs[start:i],
+					TxReceipt:     receipt,
+					ConfirmedAt:   now,
+				}
+				start = i + 1
+			}
+		}
+	}
+
+	// Attach userOps, falling back for userOps without an event
+	result := make([]*Entry, 0, len(userOps))
+	for _, userOp := range userOps {
+		userOpHash := userOp.Hash(entryPoint, chainID)
+		entry, exists := entries[userOpHash]
+		if !exists {
+			entry = &Entry{
+				UserOpHash:  userOpHash,
+				EntryPoint:  entryPoint,
+				Sender:      userOp.Sender,
+				Nonce:       (*hexutil.Big)(userOp.Nonce),
+				Paymaster:   userOp.Paymaster,
+				TxHash:      txHash,
+				Status:      fallbackStatus,
+				ConfirmedAt: now,
+			}
+			if receipt != nil {
+				entry.BlockHash = receipt.BlockHash
+				entry.BlockNumber = receipt.BlockNumber.Uint64()
+			}
+		}
+		entry.UserOp = userOp
+		result = append(result, entry)
+		delete(entries, userOpHash)
+	}
+
+	// Keep events for userOps the caller didn't know about
+	for _, entry := range entries {
+		result = append(result, entry)
+	}
+
+	return result
+}
+
+// Receipt converts an included entry and the bundle transaction receipt stored
+// with it into an eth_getUserOperationReceipt result
+func (entry *Entry) Receipt() *types.UserOperationReceipt {
+	logs := entry.Logs
+	if logs == nil {
+		logs = []*ethtypes.Log{}
+	}
+	reason := entry.Reason
+	if reason == nil {
+		reason = hexutil.Bytes{}
+	}
+
+	return &types.UserOperationReceipt{
+		UserOpHash:    entry.UserOpHash,
+		EntryPoint:    entry.EntryPoint,
+		Sender:        entry.Sender,
+		Nonce:         entry.Nonce,
+		Paymaster:     entry.Paymaster,
+		ActualGasCost: entry.ActualGasCost,
+		ActualGasUsed: entry.ActualGasUsed,
+		Success:       entry.Status == StatusSuccess,
+		Reason:        reason,
+		Logs:          logs,
+		Receipt:       entry.TxReceipt,
+	}
+}
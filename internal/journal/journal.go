@@ -0,0 +1,505 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+// Record types
+const (
+	RecordTypeAdd             = "add"
+	RecordTypeRemove          = "remove"
+	RecordTypeBundleSubmitted = "bundle_submitted"
+	RecordTypeBundleOutcome   = "bundle_outcome"
+)
+
+const (
+	journalFileName  = "mempool.journal"
+	snapshotFileName = "mempool.snapshot.json"
+)
+
+// Record is a single line of the append-only journal
+type Record struct {
+	Type         string               `json:"type"`
+	EntryPoint   common.Address       `json:"entryPoint"`
+	UserOpHashes []common.Hash        `json:"userOpHashes,omitempty"`
+	UserOp       *types.UserOperation `json:"userOp,omitempty"`
+	AddedAt      *time.Time           `json:"addedAt,omitempty"`
+	Bundle       *PendingBundle       `json:"bundle,omitempty"`
+	TxHash       *common.Hash         `json:"txHash,omitempty"`
+	Status       string               `json:"status,omitempty"`
+}
+
+// PendingBundle is a submitted bundle transaction that has not been confirmed yet
+type PendingBundle struct {
+	EntryPoint   common.Address         `json:"entryPoint"`
+	TxHash       common.Hash            `json:"txHash"`
+	KeyAddress   common.Address         `json:"keyAddress"`
+	Nonce        uint64                 `json:"nonce"` // zero for bundles journaled before nonces were recorded
+	UserOpHashes []common.Hash          `json:"userOpHashes"`
+	UserOps      []*types.UserOperation `json:"userOps"`
+	SubmittedAt  time.Time              `json:"submittedAt"`
+}
+
+// PersistedUserOp is a userOp in the journal with the time it entered the mempool
+type PersistedUserOp struct {
+	UserOp  *types.UserOperation
+	AddedAt time.Time // zero for userOps journaled before added times were recorded
+}
+
+type pendingUserOp struct {
+	seq     uint64
+	userOp  *types.UserOperation
+	addedAt time.Time
+}
+
+type snapshotUserOp struct {
+	EntryPoint common.Address       `json:"entryPoint"`
+	UserOpHash common.Hash          `json:"userOpHash"`
+	UserOp     *types.UserOperation `json:"userOp"`
+	AddedAt    time.Time            `json:"addedAt"`
+}
+
+type snapshot struct {
+	UserOps        []snapshotUserOp `json:"userOps"`
+	PendingBundles []*PendingBundle `json:"pendingBundles"`
+}
+
+// Journal persists mempool contents and pending bundles to a data directory.
+// Every change is appended to a journal file, which is compacted into a
+// snapshot periodically and on Close. Appended records reach the disk on Sync.
+// A nil *Journal is valid and records nothing.
+//
+// The journal keeps its own index of the persisted userOps and pending bundles,
+// sharing the userOp values with the mempools. Snapshots are built from it, so
+// a snapshot holds exactly the records it replaces without reading the
+// mempools, whose locks are held while they record changes.
+type Journal struct {
+	mutex          sync.Mutex
+	dir            string
+	file           *os.File
+	writer         *bufio.Writer
+	seq            uint64
+	written        uint64 // records appended
+	userOps        map[common.Address]map[common.Hash]*pendingUserOp
+	pendingBundles map[common.Hash]*PendingBundle
+	syncMutex      sync.Mutex // serializes fsyncs, guards synced and closed
+	synced         uint64     // records known to be on disk
+	closed         bool
+	stopChannel    chan struct{}
+	doneChannel    chan struct{}
+}
+
+// Open loads the snapshot and journal from dir, replays them, and starts
+// writing a new snapshot every snapshotInterval
+func Open(dir string, snapshotInterval time.Duration) (*Journal, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	j := &Journal{
+		dir:            dir,
+		userOps:        make(map[common.Address]map[common.Hash]*pendingUserOp),
+		pendingBundles: make(map[common.Hash]*PendingBundle),
+		stopChannel:    make(chan struct{}),
+		doneChannel:    make(chan struct{}),
+	}
+
+	// Load snapshot, then replay journal records written after it
+	if err := j.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	replayed, err := j.replay()
+	if err != nil {
+		return nil, err
+	}
+
+	// Open journal for appending
+	file, err := os.OpenFile(filepath.Join(dir, journalFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	j.file = file
+	j.writer = bufio.NewWriter(file)
+
+	log.Printf("Journal opened at %s (userOps: %d, pending bundles: %d, replayed records: %d)",
+		dir, j.userOpCount(), len(j.pendingBundles), replayed)
+
+	go j.run(snapshotInterval)
+
+	return j, nil
+}
+
+// Close writes a final snapshot and closes the journal file
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+
+	close(j.stopChannel)
+	<-j.doneChannel
+
+	if err := j.Snapshot(); err != nil {
+		return err
+	}
+
+	j.syncMutex.Lock()
+	defer j.syncMutex.Unlock()
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.closed = true
+	return j.file.Close()
+}
+
+// Sync waits until every record appended so far is on disk. Callers syncing at
+// the same time share one fsync, so call it after releasing any lock held
+// while recording.
+func (j *Journal) Sync() {
+	if j == nil {
+		return
+	}
+
+	j.mutex.Lock()
+	written := j.written
+	j.mutex.Unlock()
+
+	j.syncMutex.Lock()
+	defer j.syncMutex.Unlock()
+
+	// A sync that started after these records were written covered them
+	if j.closed || j.synced >= written {
+		return
+	}
+
+	// Sync everything written so far, including records appended while waiting
+	j.mutex.Lock()
+	written = j.written
+	j.mutex.Unlock()
+	if err := j.file.Sync(); err != nil {
+		log.Printf("Journal sync error: %v", err)
+		return
+	}
+	j.synced = written
+}
+
+func (j *Journal) RecordAdd(entryPoint common.Address, userOpHash common.Hash, userOp *types.UserOperation, addedAt time.Time) {
+	j.append(&Record{
+		Type:         RecordTypeAdd,
+		EntryPoint:   entryPoint,
+		UserOpHashes: []common.Hash{userOpHash},
+		UserOp:       userOp,
+		AddedAt:      &addedAt,
+	})
+}
+
+func (j *Journal) RecordRemove(entryPoint common.Address, userOpHashes ...common.Hash) {
+	if len(userOpHashes) == 0 {
+		return
+	}
+	j.append(&Record{
+		Type:         RecordTypeRemove,
+		EntryPoint:   entryPoint,
+		UserOpHashes: userOpHashes,
+	})
+}
+
+func (j *Journal) RecordBundleSubmitted(bundle *PendingBundle) {
+	j.append(&Record{
+		Type:       RecordTypeBundleSubmitted,
+		EntryPoint: bundle.EntryPoint,
+		Bundle:     bundle,
+	})
+}
+
+func (j *Journal) RecordBundleOutcome(entryPoint common.Address, txHash common.Hash, status string) {
+	j.append(&Record{
+		Type:       RecordTypeBundleOutcome,
+		EntryPoint: entryPoint,
+		TxHash:     &txHash,
+		Status:     status,
+	})
+}
+
+// UserOps returns the persisted userOps for an entry point in insertion order
+func (j *Journal) UserOps(entryPoint common.Address) []PersistedUserOp {
+	if j == nil {
+		return nil
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	pending := make([]*pendingUserOp, 0, len(j.userOps[entryPoint]))
+	for _, op := range j.userOps[entryPoint] {
+		pending = append(pending, op)
+	}
+	sort.Slice(pending, func(a, b int) bool { return pending[a].seq < pending[b].seq })
+
+	userOps := make([]PersistedUserOp, 0, len(pending))
+	for _, op := range pending {
+		userOps = append(userOps, PersistedUserOp{UserOp: op.userOp, AddedAt: op.addedAt})
+	}
+
+	return userOps
+}
+
+// PendingBundles returns the persisted pending bundles for an entry point
+func (j *Journal) PendingBundles(entryPoint common.Address) []*PendingBundle {
+	if j == nil {
+		return nil
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	bundles := make([]*PendingBundle, 0)
+	for _, bundle := range j.pendingBundles {
+		if bundle.EntryPoint == entryPoint {
+			bundles = append(bundles, bundle)
+		}
+	}
+	sort.Slice(bundles, func(a, b int) bool { return bundles[a].SubmittedAt.Before(bundles[b].SubmittedAt) })
+
+	return bundles
+}
+
+// Snapshot writes the current state to the snapshot file and truncates the journal
+func (j *Journal) Snapshot() error {
+	if j == nil {
+		return nil
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	// Build snapshot in insertion order
+	snap := snapshot{
+		UserOps:        make([]snapshotUserOp, 0, j.userOpCount()),
+		PendingBundles: make([]*PendingBundle, 0, len(j.pendingBundles)),
+	}
+	seqs := make([]uint64, 0, cap(snap.UserOps))
+	for entryPoint, ops := range j.userOps {
+		for userOpHash, op := range ops {
+			snap.UserOps = append(snap.UserOps, snapshotUserOp{EntryPoint: entryPoint, UserOpHash: userOpHash, UserOp: op.userOp, AddedAt: op.addedAt})
+			seqs = append(seqs, op.seq)
+		}
+	}
+	sort.Sort(bySeq{userOps: snap.UserOps, seqs: seqs})
+	for _, bundle := range j.pendingBundles {
+		snap.PendingBundles = append(snap.PendingBundles, bundle)
+	}
+
+	data, err := json.Marshal(&snap)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	// Write to temp file and rename so a crash never leaves a partial snapshot
+	snapshotPath := filepath.Join(j.dir, snapshotFileName)
+	tmpPath := snapshotPath + ".tmp"
+	if err := writeFileSync(tmpPath, data); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmpPath, snapshotPath); err != nil {
+		return fmt.Errorf("failed to rename snapshot: %w", err)
+	}
+
+	// Truncate journal, everything in it is now part of the snapshot
+	if err := j.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to flush journal: %w", err)
+	}
+	if err := j.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate journal: %w", err)
+	}
+
+	return nil
+}
+
+func (j *Journal) run(snapshotInterval time.Duration) {
+	defer close(j.doneChannel)
+
+	ticker := time.NewTicker(snapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-j.stopChannel:
+			return
+		case <-ticker.C:
+			if err := j.Snapshot(); err != nil {
+				log.Printf("Journal snapshot error: %v", err)
+			}
+		}
+	}
+}
+
+func (j *Journal) append(record *Record) {
+	if j == nil {
+		return
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.apply(record)
+
+	// Write record as a single JSON line
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("Journal encode error: %v", err)
+		return
+	}
+	data = append(data, '\n')
+	if _, err := j.writer.Write(data); err != nil {
+		log.Printf("Journal write error: %v", err)
+		return
+	}
+	if err := j.writer.Flush(); err != nil {
+		log.Printf("Journal flush error: %v", err)
+		return
+	}
+	j.written++
+}
+
+// apply updates the in-memory state with a record. Caller must hold the lock.
+func (j *Journal) apply(record *Record) {
+	switch record.Type {
+	case RecordTypeAdd:
+		if record.UserOp == nil || len(record.UserOpHashes) != 1 {
+			return
+		}
+		ops, exists := j.userOps[record.EntryPoint]
+		if !exists {
+			ops = make(map[common.Hash]*pendingUserOp)
+			j.userOps[record.EntryPoint] = ops
+		}
+		j.seq++
+		op := &pendingUserOp{seq: j.seq, userOp: record.UserOp}
+		if record.AddedAt != nil {
+			op.addedAt = *record.AddedAt
+		}
+		ops[record.UserOpHashes[0]] = op
+	case RecordTypeRemove:
+		for _, userOpHash := range record.UserOpHashes {
+			delete(j.userOps[record.EntryPoint], userOpHash)
+		}
+	case RecordTypeBundleSubmitted:
+		if record.Bundle != nil {
+			j.pendingBundles[record.Bundle.TxHash] = record.Bundle
+		}
+	case RecordTypeBundleOutcome:
+		if record.TxHash != nil {
+			delete(j.pendingBundles, *record.TxHash)
+		}
+	}
+}
+
+func (j *Journal) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(j.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+
+	for _, op := range snap.UserOps {
+		j.apply(&Record{
+			Type:         RecordTypeAdd,
+			EntryPoint:   op.EntryPoint,
+			UserOpHashes: []common.Hash{op.UserOpHash},
+			UserOp:       op.UserOp,
+			AddedAt:      &op.AddedAt,
+		})
+	}
+	for _, bundle := range snap.PendingBundles {
+		j.pendingBundles[bundle.TxHash] = bundle
+	}
+
+	return nil
+}
+
+func (j *Journal) replay() (int, error) {
+	file, err := os.Open(filepath.Join(j.dir, journalFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer file.Close()
+
+	replayed := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// A crash mid-write can leave a partial last line
+			log.Printf("Journal replay stopped at corrupt record %d: %v", replayed+1, err)
+			break
+		}
+		j.apply(&record)
+		replayed++
+	}
+	if err := scanner.Err(); err != nil {
+		return replayed, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	return replayed, nil
+}
+
+// userOpCount returns the number of persisted userOps. Caller must hold the lock.
+func (j *Journal) userOpCount() int {
+	count := 0
+	for _, ops := range j.userOps {
+		count += len(ops)
+	}
+	return count
+}
+
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// bySeq sorts snapshot userOps by their insertion sequence
+type bySeq struct {
+	userOps []snapshotUserOp
+	seqs    []uint64
+}
+
+func (s bySeq) Len() int           { return len(s.userOps) }
+func (s bySeq) Less(a, b int) bool { return s.seqs[a] < s.seqs[b] }
+func (s bySeq) Swap(a, b int) {
+	s.userOps[a], s.userOps[b] = s.userOps[b], s.userOps[a]
+	s.seqs[a], s.seqs[b] = s.seqs[b], s.seqs[a]
+}
@@ -0,0 +1,155 @@
+package journal
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+var testEntryPoint = types.EntryPointV07Address
+
+var testAddedAt = time.Unix(1700000000, 0)
+
+func testUserOp(nonce int64) *types.UserOperation {
+	return &types.UserOperation{
+		Sender:               common.HexToAddress("0x1111111111111111111111111111111111111111"),
+		Nonce:                big.NewInt(nonce),
+		CallData:             []byte{0x01},
+		CallGasLimit:         big.NewInt(100000),
+		VerificationGasLimit: big.NewInt(100000),
+		PreVerificationGas:   big.NewInt(50000),
+		MaxFeePerGas:         big.NewInt(2000000000),
+		MaxPriorityFeePerGas: big.NewInt(1000000000),
+		Signature:            []byte{0x02},
+	}
+}
+
+func TestReplayAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+	j, err := Open(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	// Two userOps added, the first bundled, then a bundle outcome for an unrelated tx
+	first, second := testUserOp(0), testUserOp(1)
+	firstHash := common.HexToHash("0x01")
+	secondHash := common.HexToHash("0x02")
+	j.RecordAdd(testEntryPoint, firstHash, first, testAddedAt)
+	j.RecordAdd(testEntryPoint, secondHash, second, testAddedAt.Add(time.Second))
+	bundle := &PendingBundle{
+		EntryPoint:   testEntryPoint,
+		TxHash:       common.HexToHash("0xaa"),
+		KeyAddress:   common.HexToAddress("0x2222222222222222222222222222222222222222"),
+		UserOpHashes: []common.Hash{firstHash},
+		UserOps:      []*types.UserOperation{first},
+		SubmittedAt:  time.Now(),
+	}
+	j.RecordBundleSubmitted(bundle)
+	j.RecordRemove(testEntryPoint, firstHash)
+	j.RecordBundleOutcome(testEntryPoint, common.HexToHash("0xbb"), "success")
+
+	// Simulate a crash mid-write: no snapshot, partial last line
+	file, err := os.OpenFile(filepath.Join(dir, journalFileName), os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatalf("open journal: %v", err)
+	}
+	if _, err := file.WriteString(`{"type":"add","entryPoint":`); err != nil {
+		t.Fatalf("write partial record: %v", err)
+	}
+	file.Close()
+
+	replayed, err := Open(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+
+	userOps := replayed.UserOps(testEntryPoint)
+	if len(userOps) != 1 || userOps[0].UserOp.Nonce.Cmp(second.Nonce) != 0 {
+		t.Fatalf("replayed userOps = %v, want only the second userOp", userOps)
+	}
+	if !userOps[0].AddedAt.Equal(testAddedAt.Add(time.Second)) {
+		t.Fatalf("replayed added time = %v, want %v", userOps[0].AddedAt, testAddedAt.Add(time.Second))
+	}
+	bundles := replayed.PendingBundles(testEntryPoint)
+	if len(bundles) != 1 || bundles[0].TxHash != bundle.TxHash || bundles[0].KeyAddress != bundle.KeyAddress {
+		t.Fatalf("replayed pending bundles = %v, want bundle %s", bundles, bundle.TxHash.Hex())
+	}
+}
+
+func TestReplaySnapshotAndJournal(t *testing.T) {
+	dir := t.TempDir()
+	j, err := Open(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	bundle := &PendingBundle{EntryPoint: testEntryPoint, TxHash: common.HexToHash("0xaa"), SubmittedAt: time.Now()}
+	j.RecordAdd(testEntryPoint, common.HexToHash("0x01"), testUserOp(0), testAddedAt)
+	j.RecordBundleSubmitted(bundle)
+	if err := j.Snapshot(); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	// Records after the snapshot are replayed on top of it
+	j.RecordAdd(testEntryPoint, common.HexToHash("0x02"), testUserOp(1), testAddedAt)
+	j.RecordBundleOutcome(testEntryPoint, bundle.TxHash, "success")
+
+	reopened, err := Open(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+
+	userOps := reopened.UserOps(testEntryPoint)
+	if len(userOps) != 2 || userOps[0].UserOp.Nonce.Int64() != 0 || userOps[1].UserOp.Nonce.Int64() != 1 {
+		t.Fatalf("reopened userOps = %v, want both userOps in insertion order", userOps)
+	}
+	for _, op := range userOps {
+		if !op.AddedAt.Equal(testAddedAt) {
+			t.Fatalf("reopened added time = %v, want %v", op.AddedAt, testAddedAt)
+		}
+	}
+	if bundles := reopened.PendingBundles(testEntryPoint); len(bundles) != 0 {
+		t.Fatalf("reopened pending bundles = %v, want none", bundles)
+	}
+}
+
+func TestConcurrentSync(t *testing.T) {
+	dir := t.TempDir()
+	j, err := Open(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	// Concurrent writers share fsyncs and every record is on disk once Sync returns
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			j.RecordAdd(testEntryPoint, common.BigToHash(big.NewInt(int64(i+1))), testUserOp(int64(i)), testAddedAt)
+			j.Sync()
+		}()
+	}
+	wg.Wait()
+
+	j.syncMutex.Lock()
+	synced := j.synced
+	j.syncMutex.Unlock()
+	if synced != 20 {
+		t.Fatalf("synced records = %d, want 20", synced)
+	}
+
+	reopened, err := Open(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if userOps := reopened.UserOps(testEntryPoint); len(userOps) != 20 {
+		t.Fatalf("reopened %d userOps, want 20", len(userOps))
+	}
+}
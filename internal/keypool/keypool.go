@@ -8,112 +8,436 @@ import (
 	"math/big"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	ethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/vorpalengineering/gundler/internal/logging"
+	"github.com/vorpalengineering/gundler/internal/metrics"
+)
+
+// Extra gas added on top of the estimate for each transaction, unless set with SetFeeMargins
+const defaultGasLimitBufferPercent = 20
+
+// Key selection strategies
+const (
+	StrategyRoundRobin        = "round_robin"
+	StrategyLeastRecentlyUsed = "least_recently_used"
+	StrategyHighestBalance    = "highest_balance"
 )
 
 type PooledKey struct {
-	PrivateKey *ecdsa.PrivateKey
-	Address    common.Address
-	InFlight   bool
+	Signer       Signer
+	Address      common.Address
+	Pending      int       // transactions sent and not yet released
+	NextNonce    uint64    // nonce of the next transaction while others are pending
+	LastUsed     time.Time // last time the key was selected
+	Balance      *big.Int  // last balance seen by the balance monitor, nil until checked
+	LowBalance   bool      // below the minimum balance, skipped when selecting keys
+	Retired      bool      // removed from the pool, kept until its pending transactions are released
+	Disabled     bool      // disabled by an operator, skipped when selecting keys
+	DroppedNonce uint64    // nonce of the last transaction found dropped
+	DroppedAt    time.Time // when that transaction was found dropped, zero if none was
 }
 
 type KeyPool struct {
-	keys      []*PooledKey
-	ethClient *ethclient.Client
-	chainID   *big.Int
-	mutex     sync.Mutex
-	cond      *sync.Cond
+	keys             []*PooledKey
+	ethClient        *ethclient.Client
+	chainID          *big.Int
+	strategy         string
+	maxPendingPerKey int
+	nextIndex        int    // next key to try for round robin
+	gasLimitBuffer   uint64 // percent added to the estimated gas limit
+	gasPriceMargin   uint64 // percent added to the suggested gas price
+	mutex            sync.Mutex
+	cond             *sync.Cond
+	monitor          *balanceMonitor
+	keyFunders       map[common.Address]common.Address // key => pool key that refills it
 }
 
-func NewKeyPool(privateKeyStrings []string, ethClient *ethclient.Client, chainID *big.Int) (*KeyPool, error) {
-	if len(privateKeyStrings) == 0 {
-		return nil, fmt.Errorf("no private keys provided")
+// NewKeyPool creates a pool of keys selected with strategy. Each key can have up
+// to maxPendingPerKey transactions in flight, sent with sequential nonces.
+func NewKeyPool(signers []Signer, ethClient *ethclient.Client, chainID *big.Int, strategy string, maxPendingPerKey uint) (*KeyPool, error) {
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("no signers provided")
+	}
+	if err := ValidateStrategy(strategy); err != nil {
+		return nil, err
+	}
+	if maxPendingPerKey == 0 {
+		maxPendingPerKey = 1
 	}
 
-	keys := make([]*PooledKey, 0, len(privateKeyStrings))
-
-	for i, pkStr := range privateKeyStrings {
-		// Remove 0x prefix if present
-		pkStr = strings.TrimPrefix(strings.TrimSpace(pkStr), "0x")
-
-		// Parse private key
-		privateKey, err := crypto.HexToECDSA(pkStr)
-		if err != nil {
-			return nil, fmt.Errorf("invalid private key at index %d: %w", i, err)
-		}
+	keys := make([]*PooledKey, 0, len(signers))
 
-		// Derive address from private key
-		publicKey := privateKey.Public()
-		publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-		if !ok {
-			return nil, fmt.Errorf("failed to cast public key to ECDSA at index %d", i)
+	for i, signer := range signers {
+		// Reject duplicate addresses
+		address := signer.Address()
+		for _, key := range keys {
+			if key.Address == address {
+				return nil, fmt.Errorf("duplicate key at index %d: %s", i, address.Hex())
+			}
 		}
-		address := crypto.PubkeyToAddress(*publicKeyECDSA)
 
 		keys = append(keys, &PooledKey{
-			PrivateKey: privateKey,
-			Address:    address,
-			InFlight:   false,
+			Signer:  signer,
+			Address: address,
 		})
 
 		log.Printf("Loaded key %d: Address: %s", i+1, address.Hex())
 	}
 
 	pool := &KeyPool{
-		keys:      keys,
-		ethClient: ethClient,
-		chainID:   chainID,
+		keys:             keys,
+		ethClient:        ethClient,
+		chainID:          chainID,
+		strategy:         strategy,
+		maxPendingPerKey: int(maxPendingPerKey),
+		gasLimitBuffer:   defaultGasLimitBufferPercent,
 	}
 	pool.cond = sync.NewCond(&pool.mutex)
 
-	log.Printf("KeyPool initialized with %d keys", len(keys))
+	log.Printf("KeyPool initialized with %d keys (strategy: %s, max pending per key: %d)", len(keys), strategy, maxPendingPerKey)
 
 	return pool, nil
 }
 
-func (kp *KeyPool) SubmitTransaction(ctx context.Context, tx *ethtypes.Transaction) (common.Hash, common.Address, error) {
-	// Get next available key (blocks if all keys are in-flight)
-	key, err := kp.getNextAvailableKey(ctx)
+// SetFeeMargins changes the percentages added to the estimated gas limit and the
+// suggested gas price of each transaction
+func (kp *KeyPool) SetFeeMargins(gasLimitBufferPercent uint, gasPriceMarginPercent uint) {
+	kp.mutex.Lock()
+	defer kp.mutex.Unlock()
+
+	if kp.gasLimitBuffer != uint64(gasLimitBufferPercent) || kp.gasPriceMargin != uint64(gasPriceMarginPercent) {
+		kp.gasLimitBuffer = uint64(gasLimitBufferPercent)
+		kp.gasPriceMargin = uint64(gasPriceMarginPercent)
+		log.Printf("KeyPool fee margins set to %d%% gas limit buffer, %d%% gas price margin", gasLimitBufferPercent, gasPriceMarginPercent)
+	}
+}
+
+// feeMargins returns the gas limit buffer and gas price margin percentages
+func (kp *KeyPool) feeMargins() (uint64, uint64) {
+	kp.mutex.Lock()
+	defer kp.mutex.Unlock()
+
+	return kp.gasLimitBuffer, kp.gasPriceMargin
+}
+
+// ValidateStrategy checks that a key selection strategy is known
+func ValidateStrategy(strategy string) error {
+	switch strategy {
+	case StrategyRoundRobin, StrategyLeastRecentlyUsed, StrategyHighestBalance:
+		return nil
+	default:
+		return fmt.Errorf("unknown key selection strategy: %s", strategy)
+	}
+}
+
+// SetSelection changes the key selection strategy, checked with ValidateStrategy,
+// and the per-key pending limit
+func (kp *KeyPool) SetSelection(strategy string, maxPendingPerKey uint) {
+	if maxPendingPerKey == 0 {
+		maxPendingPerKey = 1
+	}
+
+	kp.mutex.Lock()
+	defer kp.mutex.Unlock()
+
+	if kp.strategy != strategy || kp.maxPendingPerKey != int(maxPendingPerKey) {
+		kp.strategy = strategy
+		kp.maxPendingPerKey = int(maxPendingPerKey)
+		log.Printf("KeyPool selection set to %s (max pending per key: %d)", strategy, maxPendingPerKey)
+		kp.cond.Broadcast() // A higher limit may free keys
+	}
+}
+
+// ValidateSigners checks that signers can replace the pool's keys: at least one, without duplicates
+func ValidateSigners(signers []Signer) error {
+	if len(signers) == 0 {
+		return fmt.Errorf("no signers provided")
+	}
+
+	seen := make(map[common.Address]bool, len(signers))
+	for _, signer := range signers {
+		if seen[signer.Address()] {
+			return fmt.Errorf("duplicate key: %s", signer.Address().Hex())
+		}
+		seen[signer.Address()] = true
+	}
+	return nil
+}
+
+// SetSigners replaces the pool's keys with signers checked with ValidateSigners.
+// New signers are added, and keys without a signer are removed once their
+// pending transactions are released.
+func (kp *KeyPool) SetSigners(signers []Signer) {
+	kp.mutex.Lock()
+	defer kp.mutex.Unlock()
+
+	// Add new keys and keep (or restore) existing ones
+	kept := make(map[common.Address]bool, len(signers))
+	for _, signer := range signers {
+		address := signer.Address()
+		kept[address] = true
+
+		if key := kp.findKey(address); key != nil {
+			key.Signer = signer
+			if key.Retired {
+				key.Retired = false
+				log.Printf("Key restored: %s", address.Hex())
+			}
+			continue
+		}
+		kp.keys = append(kp.keys, &PooledKey{
+			Signer:  signer,
+			Address: address,
+		})
+		log.Printf("Key added: %s", address.Hex())
+	}
+
+	// Remove keys that are no longer configured
+	keys := kp.keys[:0]
+	for _, key := range kp.keys {
+		if !kept[key.Address] {
+			if key.Pending == 0 {
+				kp.dropKeyMetrics(key.Address)
+				log.Printf("Key removed: %s", key.Address.Hex())
+				continue
+			}
+			if !key.Retired {
+				key.Retired = true
+				log.Printf("Key retired: %s (removed once %d pending transactions are released)", key.Address.Hex(), key.Pending)
+			}
+		}
+		keys = append(keys, key)
+	}
+	kp.keys = keys
+
+	kp.cond.Broadcast() // New keys may be available
+}
+
+// keySigner returns a key's current signer, which SetSigners may replace
+func (kp *KeyPool) keySigner(key *PooledKey) Signer {
+	kp.mutex.Lock()
+	defer kp.mutex.Unlock()
+
+	return key.Signer
+}
+
+// findKey returns the pooled key for an address, or nil. Caller must hold the lock.
+func (kp *KeyPool) findKey(address common.Address) *PooledKey {
+	for _, key := range kp.keys {
+		if key.Address == address {
+			return key
+		}
+	}
+	return nil
+}
+
+// dropKeyMetrics deletes the gauges of a removed key
+func (kp *KeyPool) dropKeyMetrics(address common.Address) {
+	metrics.KeyBalance.Delete(address.Hex())
+	metrics.KeyLowBalance.Delete(address.Hex())
+	metrics.KeyInFlight.Delete(address.Hex())
+}
+
+// ParsePrivateKey parses a hex private key (with or without 0x prefix) and derives its address
+func ParsePrivateKey(pkStr string) (*ecdsa.PrivateKey, common.Address, error) {
+	// Remove 0x prefix if present
+	pkStr = strings.TrimPrefix(strings.TrimSpace(pkStr), "0x")
+
+	privateKey, err := crypto.HexToECDSA(pkStr)
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+
+	// Derive address from private key
+	publicKey := privateKey.Public()
+	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, common.Address{}, fmt.Errorf("failed to cast public key to ECDSA")
+	}
+
+	return privateKey, crypto.PubkeyToAddress(*publicKeyECDSA), nil
+}
+
+// SubmitTransaction builds, signs and sends a transaction calling `to` with `data` from the
+// next available key, chosen from `keys` (all keys if empty). The transaction counts against
+// the key's pending limit until ReleaseKey is called for it.
+func (kp *KeyPool) SubmitTransaction(ctx context.Context, keys []common.Address, to common.Address, data []byte) (common.Hash, common.Address, error) {
+	address, err := kp.AcquireKey(ctx, keys)
+	if err != nil {
+		return common.Hash{}, common.Address{}, err
+	}
+
+	tx, err := kp.SendTransaction(ctx, address, to, data)
 	if err != nil {
-		return common.Hash{}, common.Address{}, fmt.Errorf("failed to get available key: %w", err)
+		return common.Hash{}, common.Address{}, err
+	}
+
+	return tx.Hash(), address, nil
+}
+
+// AcquireKey reserves a pending slot on the next available key, chosen from `keys`
+// (all keys if empty), blocking while every key is at its limit. The slot is held
+// until ReleaseKey is called or SendTransaction fails.
+func (kp *KeyPool) AcquireKey(ctx context.Context, keys []common.Address) (common.Address, error) {
+	key, err := kp.getNextAvailableKey(ctx, keys)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to get available key: %w", err)
+	}
+	return key.Address, nil
+}
+
+// SendTransaction builds, signs and sends a transaction calling `to` with `data` from a
+// key acquired with AcquireKey, and returns the signed transaction. The key is released
+// if the transaction is not sent.
+func (kp *KeyPool) SendTransaction(ctx context.Context, address common.Address, to common.Address, data []byte) (*ethtypes.Transaction, error) {
+	kp.mutex.Lock()
+	key := kp.findKey(address)
+	kp.mutex.Unlock()
+	if key == nil {
+		return nil, fmt.Errorf("key %s is not a bundler key", address.Hex())
 	}
 
-	// Mark key as in-flight
-	kp.markKeyInFlight(key.Address)
+	// Build transaction
+	tx, err := kp.buildTransaction(ctx, key, to, data)
+	if err != nil {
+		kp.ReleaseKey(key.Address) // Release key on error
+		return nil, fmt.Errorf("failed to build transaction: %w", err)
+	}
 
 	// Sign transaction
-	signedTx, err := ethtypes.SignTx(tx, ethtypes.NewEIP155Signer(kp.chainID), key.PrivateKey)
+	signedTx, err := kp.keySigner(key).SignTx(ctx, tx, kp.chainID)
 	if err != nil {
+		kp.resyncNonce(key)
 		kp.ReleaseKey(key.Address) // Release key on error
-		return common.Hash{}, common.Address{}, fmt.Errorf("failed to sign transaction: %w", err)
+		return nil, fmt.Errorf("failed to sign transaction: %w", err)
 	}
 
 	// Submit transaction
 	err = kp.ethClient.SendTransaction(ctx, signedTx)
 	if err != nil {
+		kp.resyncNonce(key)
 		kp.ReleaseKey(key.Address) // Release key on error
-		return common.Hash{}, common.Address{}, fmt.Errorf("failed to send transaction: %w", err)
+		return nil, fmt.Errorf("failed to send transaction: %w", err)
 	}
 
-	txHash := signedTx.Hash()
-	log.Printf("Transaction submitted: %s from key: %s", txHash.Hex(), key.Address.Hex())
+	logging.Debugf("Transaction submitted: %s from key: %s", signedTx.Hash().Hex(), key.Address.Hex())
 
-	return txHash, key.Address, nil
+	return signedTx, nil
 }
 
+func (kp *KeyPool) buildTransaction(ctx context.Context, key *PooledKey, to common.Address, data []byte) (*ethtypes.Transaction, error) {
+	from := key.Address
+	gasLimitBuffer, gasPriceMargin := kp.feeMargins()
+
+	// Get gas price, with the margin on top
+	gasPrice, err := kp.ethClient.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gas price: %w", err)
+	}
+	gasPrice.Mul(gasPrice, new(big.Int).SetUint64(100+gasPriceMargin))
+	gasPrice.Div(gasPrice, big.NewInt(100))
+
+	// Estimate gas limit
+	gasLimit, err := kp.ethClient.EstimateGas(ctx, ethereum.CallMsg{
+		From:     from,
+		To:       &to,
+		GasPrice: gasPrice,
+		Data:     data,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate gas: %w", err)
+	}
+	gasLimit += gasLimit * gasLimitBuffer / 100
+
+	// Allocate nonce last so a failed estimate doesn't leave a gap
+	nonce, err := kp.allocateNonce(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return ethtypes.NewTransaction(nonce, to, big.NewInt(0), gasLimit, gasPrice, data), nil
+}
+
+// allocateNonce returns the next nonce for a key. While the key has other
+// transactions pending, nonces are assigned sequentially rather than trusting
+// the node's pending nonce, which may not include them yet.
+func (kp *KeyPool) allocateNonce(ctx context.Context, key *PooledKey) (uint64, error) {
+	pendingNonce, err := kp.ethClient.PendingNonceAt(ctx, key.Address)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get nonce: %w", err)
+	}
+
+	kp.mutex.Lock()
+	defer kp.mutex.Unlock()
+
+	nonce := pendingNonce
+	if key.NextNonce > nonce {
+		nonce = key.NextNonce
+	}
+	key.NextNonce = nonce + 1
+
+	return nonce, nil
+}
+
+// resyncNonce makes the key's next transaction take the node's pending nonce,
+// which fills the gap an unsent transaction left in its sequence
+func (kp *KeyPool) resyncNonce(key *PooledKey) {
+	kp.mutex.Lock()
+	defer kp.mutex.Unlock()
+
+	key.NextNonce = 0
+}
+
+// ResyncNonce resyncs a key's nonce with the node after its transaction with
+// nonce was dropped, so new transactions fill the gap instead of waiting behind it
+func (kp *KeyPool) ResyncNonce(address common.Address, nonce uint64) {
+	kp.mutex.Lock()
+	defer kp.mutex.Unlock()
+
+	if key := kp.findKey(address); key != nil {
+		key.NextNonce = 0
+		key.DroppedNonce = nonce
+		key.DroppedAt = time.Now()
+	}
+}
+
+// BehindDroppedNonce reports whether a transaction sent at sentAt with nonce is
+// stuck behind a dropped transaction of the key: one with a lower nonce sent
+// before it and found dropped since. It can never be mined, as new transactions
+// reuse the dropped nonces.
+func (kp *KeyPool) BehindDroppedNonce(address common.Address, nonce uint64, sentAt time.Time) bool {
+	kp.mutex.Lock()
+	defer kp.mutex.Unlock()
+
+	key := kp.findKey(address)
+	return key != nil && !key.DroppedAt.IsZero() && sentAt.Before(key.DroppedAt) && nonce > key.DroppedNonce
+}
+
+// ReleaseKey frees one of the key's pending transaction slots
 func (kp *KeyPool) ReleaseKey(address common.Address) {
 	kp.mutex.Lock()
 	defer kp.mutex.Unlock()
 
 	for _, key := range kp.keys {
 		if key.Address == address {
-			if key.InFlight {
-				key.InFlight = false
-				log.Printf("Key released: %s", address.Hex())
+			if key.Pending > 0 {
+				key.Pending--
+				logging.Debugf("Key released: %s (pending: %d)", address.Hex(), key.Pending)
+				if key.Pending == 0 {
+					// Resync with the node's pending nonce (e.g. after a dropped transaction)
+					key.NextNonce = 0
+				}
+				if key.Pending == 0 && key.Retired {
+					kp.removeKey(address)
+					kp.dropKeyMetrics(address)
+					log.Printf("Key removed: %s", address.Hex())
+				}
 				kp.cond.Signal() // Wake up one waiting goroutine
 			}
 			return
@@ -121,14 +445,85 @@ func (kp *KeyPool) ReleaseKey(address common.Address) {
 	}
 }
 
+// removeKey deletes a key from the pool. Caller must hold the lock.
+func (kp *KeyPool) removeKey(address common.Address) {
+	for i, key := range kp.keys {
+		if key.Address == address {
+			kp.keys = append(kp.keys[:i], kp.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+// KeyStatus is a snapshot of a pooled key's state
+type KeyStatus struct {
+	Address    common.Address
+	Pending    int
+	Balance    *big.Int
+	LowBalance bool
+	Disabled   bool
+}
+
+// Keys returns the status of every key
+func (kp *KeyPool) Keys() []KeyStatus {
+	kp.mutex.Lock()
+	defer kp.mutex.Unlock()
+
+	statuses := make([]KeyStatus, 0, len(kp.keys))
+	for _, key := range kp.keys {
+		var balance *big.Int
+		if key.Balance != nil {
+			balance = new(big.Int).Set(key.Balance)
+		}
+		statuses = append(statuses, KeyStatus{
+			Address:    key.Address,
+			Pending:    key.Pending,
+			Balance:    balance,
+			LowBalance: key.LowBalance,
+			Disabled:   key.Disabled,
+		})
+	}
+	return statuses
+}
+
+// SetKeyEnabled enables or disables a key for new bundles. A disabled key's
+// pending transactions still confirm.
+func (kp *KeyPool) SetKeyEnabled(address common.Address, enabled bool) error {
+	kp.mutex.Lock()
+	defer kp.mutex.Unlock()
+
+	key := kp.findKey(address)
+	if key == nil || key.Retired {
+		return fmt.Errorf("key %s is not a bundler key", address.Hex())
+	}
+	key.Disabled = !enabled
+
+	// Wake bundlers waiting for a key
+	if enabled {
+		kp.cond.Broadcast()
+	}
+	return nil
+}
+
 func (kp *KeyPool) GetKeyCount() int {
+	kp.mutex.Lock()
+	defer kp.mutex.Unlock()
+
 	return len(kp.keys)
 }
 
-func (kp *KeyPool) getNextAvailableKey(ctx context.Context) (*PooledKey, error) {
+func (kp *KeyPool) getNextAvailableKey(ctx context.Context, keys []common.Address) (*PooledKey, error) {
 	kp.mutex.Lock()
 	defer kp.mutex.Unlock()
 
+	// Wake the wait below when ctx is cancelled
+	stop := context.AfterFunc(ctx, func() {
+		kp.mutex.Lock()
+		defer kp.mutex.Unlock()
+		kp.cond.Broadcast()
+	})
+	defer stop()
+
 	for {
 		// Check context cancellation
 		select {
@@ -137,26 +532,114 @@ func (kp *KeyPool) getNextAvailableKey(ctx context.Context) (*PooledKey, error)
 		default:
 		}
 
-		// Find first available key
+		// Select a key below its pending limit, skipping disabled keys and keys below the minimum balance
+		funded := false
 		for _, key := range kp.keys {
-			if !key.InFlight {
-				return key, nil
+			if !key.LowBalance && !key.Retired && !key.Disabled && isAllowed(key, keys) {
+				funded = true
+				break
 			}
 		}
+		if !funded {
+			return nil, fmt.Errorf("all usable keys are disabled or below the minimum balance")
+		}
+		if key := kp.selectKey(keys); key != nil {
+			key.Pending++
+			key.LastUsed = time.Now()
+			logging.Debugf("Key marked as in-flight: %s (pending: %d)", key.Address.Hex(), key.Pending)
+			return key, nil
+		}
 
-		// All keys are in-flight, wait for signal
-		log.Println("All keys are in-flight, waiting for available key...")
+		// All keys are at their pending limit, wait for signal
+		logging.Debugf("All keys are in-flight, waiting for available key...")
 		kp.cond.Wait()
 	}
 }
 
-func (kp *KeyPool) markKeyInFlight(address common.Address) {
+// selectKey picks an available key from `keys` (all keys if empty) according to
+// the strategy, or nil if none is available. Caller must hold the lock.
+func (kp *KeyPool) selectKey(keys []common.Address) *PooledKey {
+	var selected *PooledKey
+	switch kp.strategy {
+	case StrategyRoundRobin:
+		for i := range kp.keys {
+			index := (kp.nextIndex + i) % len(kp.keys)
+			if kp.isAvailable(kp.keys[index]) && isAllowed(kp.keys[index], keys) {
+				selected = kp.keys[index]
+				kp.nextIndex = index + 1
+				break
+			}
+		}
+	case StrategyLeastRecentlyUsed:
+		for _, key := range kp.keys {
+			if kp.isAvailable(key) && isAllowed(key, keys) && (selected == nil || key.LastUsed.Before(selected.LastUsed)) {
+				selected = key
+			}
+		}
+	case StrategyHighestBalance:
+		for _, key := range kp.keys {
+			if kp.isAvailable(key) && isAllowed(key, keys) && (selected == nil || compareBalances(key.Balance, selected.Balance) > 0) {
+				selected = key
+			}
+		}
+	}
+
+	return selected
+}
+
+// isAvailable reports whether a key can take another transaction. Caller must hold the lock.
+func (kp *KeyPool) isAvailable(key *PooledKey) bool {
+	return !key.LowBalance && !key.Retired && !key.Disabled && key.Pending < kp.maxPendingPerKey
+}
+
+// isAllowed reports whether a key is in `keys`, or true if `keys` is empty
+func isAllowed(key *PooledKey, keys []common.Address) bool {
+	if len(keys) == 0 {
+		return true
+	}
+	for _, address := range keys {
+		if key.Address == address {
+			return true
+		}
+	}
+	return false
+}
+
+// compareBalances compares balances, treating unknown (nil) balances as lowest
+func compareBalances(a *big.Int, b *big.Int) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	return a.Cmp(b)
+}
+
+// Signer returns the signer of a pooled key
+func (kp *KeyPool) Signer(address common.Address) (Signer, bool) {
+	kp.mutex.Lock()
+	defer kp.mutex.Unlock()
+
+	for _, key := range kp.keys {
+		if key.Address == address {
+			return key.Signer, true
+		}
+	}
+	return nil, false
+}
+
+// MarkKeyInFlight counts a pending transaction against a key without submitting
+// one (e.g. for a restored pending bundle)
+func (kp *KeyPool) MarkKeyInFlight(address common.Address) {
 	kp.mutex.Lock()
 	defer kp.mutex.Unlock()
 
 	for _, key := range kp.keys {
 		if key.Address == address {
-			key.InFlight = true
+			key.Pending++
 			log.Printf("Key marked as in-flight: %s", address.Hex())
 			return
 		}
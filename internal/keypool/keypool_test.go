@@ -0,0 +1,75 @@
+package keypool
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func newLocalSigner(t *testing.T) *LocalSigner {
+	t.Helper()
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return NewLocalSigner(privateKey)
+}
+
+func TestValidateSigners(t *testing.T) {
+	first, second := newLocalSigner(t), newLocalSigner(t)
+	tests := []struct {
+		name    string
+		signers []Signer
+		wantErr bool
+	}{
+		{"none", nil, true},
+		{"distinct", []Signer{first, second}, false},
+		{"duplicate", []Signer{first, second, first}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := ValidateSigners(test.signers); (err != nil) != test.wantErr {
+				t.Fatalf("ValidateSigners err = %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateStrategy(t *testing.T) {
+	for _, strategy := range []string{StrategyRoundRobin, StrategyLeastRecentlyUsed, StrategyHighestBalance} {
+		if err := ValidateStrategy(strategy); err != nil {
+			t.Fatalf("ValidateStrategy(%s): %v", strategy, err)
+		}
+	}
+	if err := ValidateStrategy("random"); err == nil {
+		t.Fatalf("ValidateStrategy accepted an unknown strategy")
+	}
+}
+
+// SetSigners keeps the pending state of keys that stay in the pool and retires
+// removed keys with pending transactions instead of dropping them
+func TestSetSigners(t *testing.T) {
+	kept, removed, added := newLocalSigner(t), newLocalSigner(t), newLocalSigner(t)
+	kp, err := NewKeyPool([]Signer{kept, removed}, nil, testChainID, StrategyRoundRobin, 1)
+	if err != nil {
+		t.Fatalf("NewKeyPool: %v", err)
+	}
+	kp.keys[0].Pending = 1
+	kp.keys[1].Pending = 1
+
+	kp.SetSigners([]Signer{kept, added})
+
+	keys := make(map[string]*PooledKey)
+	for _, key := range kp.keys {
+		keys[key.Address.Hex()] = key
+	}
+	if key := keys[kept.Address().Hex()]; key == nil || key.Pending != 1 || key.Retired {
+		t.Fatalf("kept key = %+v, want pending state kept", key)
+	}
+	if key := keys[removed.Address().Hex()]; key == nil || !key.Retired {
+		t.Fatalf("removed key with pending transactions = %+v, want retired", key)
+	}
+	if key := keys[added.Address().Hex()]; key == nil || key.Retired {
+		t.Fatalf("added key = %+v, want active", key)
+	}
+}
@@ -0,0 +1,294 @@
+package keypool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/vorpalengineering/gundler/internal/metrics"
+)
+
+const (
+	blockPollInterval   = 2 * time.Second
+	balanceCheckTimeout = 10 * time.Second
+	// Refills without a receipt after this long are sent again
+	refillTimeout    = 5 * time.Minute
+	transferGasLimit = 21000
+)
+
+// BalanceMonitorConfig controls key balance monitoring. Keys below MinBalance
+// are taken out of rotation. If Funder is set, they are topped up from it to
+// TargetBalance; otherwise keys with a funding pool key (see SetKeyFunders) are
+// topped up from it.
+type BalanceMonitorConfig struct {
+	MinBalance    *big.Int
+	TargetBalance *big.Int
+	Funder        Signer
+}
+
+type balanceMonitor struct {
+	config      BalanceMonitorConfig
+	refills     map[common.Address]*pendingRefill // key address => refill in flight
+	stopChannel chan struct{}
+	doneChannel chan struct{}
+}
+
+type pendingRefill struct {
+	txHash common.Hash
+	sentAt time.Time
+}
+
+// StartBalanceMonitor checks every key balance on each new block until Stop is called
+func (kp *KeyPool) StartBalanceMonitor(config BalanceMonitorConfig) {
+	monitor := &balanceMonitor{
+		config:      config,
+		refills:     make(map[common.Address]*pendingRefill),
+		stopChannel: make(chan struct{}),
+		doneChannel: make(chan struct{}),
+	}
+	kp.mutex.Lock()
+	keyFunders := len(kp.keyFunders)
+	kp.mutex.Unlock()
+	if config.Funder != nil {
+		log.Printf("Key refill enabled from %s up to %v wei", config.Funder.Address().Hex(), config.TargetBalance)
+	} else if keyFunders > 0 {
+		log.Printf("Key refill enabled from funding pool keys up to %v wei", config.TargetBalance)
+	}
+	kp.monitor = monitor
+
+	log.Printf("Starting key balance monitor (min balance: %v wei)", config.MinBalance)
+	go kp.monitorBalances()
+}
+
+// Stop stops the balance monitor, if started
+func (kp *KeyPool) Stop() {
+	if kp.monitor == nil {
+		return
+	}
+	close(kp.monitor.stopChannel)
+	<-kp.monitor.doneChannel
+}
+
+func (kp *KeyPool) monitorBalances() {
+	defer close(kp.monitor.doneChannel)
+
+	ticker := time.NewTicker(blockPollInterval)
+	defer ticker.Stop()
+
+	var lastBlock uint64
+	for {
+		// Check balances once per new block
+		ctx, cancel := context.WithTimeout(context.Background(), balanceCheckTimeout)
+		blockNumber, err := kp.ethClient.BlockNumber(ctx)
+		if err != nil {
+			log.Printf("Balance monitor failed to get block number: %v", err)
+		} else if blockNumber != lastBlock {
+			lastBlock = blockNumber
+			kp.checkBalances(ctx)
+		}
+		cancel()
+
+		select {
+		case <-kp.monitor.stopChannel:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (kp *KeyPool) checkBalances(ctx context.Context) {
+	for _, key := range kp.Keys() {
+		balance, err := kp.ethClient.BalanceAt(ctx, key.Address, nil)
+		if err != nil {
+			log.Printf("Failed to get balance of key %s: %v", key.Address.Hex(), err)
+			continue
+		}
+
+		low := balance.Cmp(kp.monitor.config.MinBalance) < 0
+		kp.setBalance(key.Address, balance, low)
+
+		if funder, exists := kp.funderOf(key.Address); low && exists && funder != key.Address {
+			if err := kp.refill(ctx, key.Address, balance, funder); err != nil {
+				log.Printf("WARNING: failed to refill key %s: %v", key.Address.Hex(), err)
+				metrics.KeyRefills.Inc(key.Address.Hex(), "failed")
+			}
+		}
+	}
+}
+
+// setBalance records a key balance and takes the key in or out of rotation
+func (kp *KeyPool) setBalance(address common.Address, balance *big.Int, low bool) {
+	balanceFloat, _ := new(big.Float).SetInt(balance).Float64()
+	metrics.KeyBalance.Set(balanceFloat, address.Hex())
+	lowFloat := 0.0
+	if low {
+		lowFloat = 1
+	}
+	metrics.KeyLowBalance.Set(lowFloat, address.Hex())
+
+	kp.mutex.Lock()
+	defer kp.mutex.Unlock()
+
+	for _, key := range kp.keys {
+		if key.Address != address {
+			continue
+		}
+		key.Balance = balance
+		if low && !key.LowBalance {
+			log.Printf("WARNING: key %s balance %v wei is below minimum %v wei, removed from rotation", address.Hex(), balance, kp.monitor.config.MinBalance)
+		}
+		if !low && key.LowBalance {
+			log.Printf("Key %s balance %v wei restored, returned to rotation", address.Hex(), balance)
+			kp.cond.Broadcast() // Wake up goroutines waiting for a key
+		}
+		key.LowBalance = low
+		return
+	}
+}
+
+// SetKeyFunders sets the pool key that refills each key (key => funding key) when
+// the balance monitor has no Funder
+func (kp *KeyPool) SetKeyFunders(funders map[common.Address]common.Address) {
+	kp.mutex.Lock()
+	defer kp.mutex.Unlock()
+
+	kp.keyFunders = funders
+}
+
+// funderOf returns the address that refills a key, if any
+func (kp *KeyPool) funderOf(address common.Address) (common.Address, bool) {
+	if kp.monitor.config.Funder != nil {
+		return kp.monitor.config.Funder.Address(), true
+	}
+
+	kp.mutex.Lock()
+	defer kp.mutex.Unlock()
+
+	funder, exists := kp.keyFunders[address]
+	return funder, exists
+}
+
+// refill sends TargetBalance - balance from funderAddress to a key, unless a refill
+// is already in flight. A funder that is a pool key keeps MinBalance for itself.
+func (kp *KeyPool) refill(ctx context.Context, address common.Address, balance *big.Int, funderAddress common.Address) error {
+	monitor := kp.monitor
+
+	// Wait for a previous refill to confirm
+	if pending, exists := monitor.refills[address]; exists {
+		_, err := kp.ethClient.TransactionReceipt(ctx, pending.txHash)
+		if errors.Is(err, ethereum.NotFound) && time.Since(pending.sentAt) < refillTimeout {
+			return nil
+		}
+		delete(monitor.refills, address)
+	}
+
+	amount := new(big.Int).Sub(monitor.config.TargetBalance, balance)
+	if amount.Sign() <= 0 {
+		return nil
+	}
+
+	// A funder that is also a pool key takes one of its pending slots and shares its nonces
+	var funderKey *PooledKey
+	if kp.hasKey(funderAddress) {
+		funderKey = kp.tryAcquireKey(funderAddress)
+		if funderKey == nil {
+			return nil
+		}
+		defer kp.ReleaseKey(funderAddress)
+	} else if monitor.config.Funder == nil || monitor.config.Funder.Address() != funderAddress {
+		return fmt.Errorf("funder %s is not a bundler key", funderAddress.Hex())
+	}
+
+	// Send no more than the funder can spare, a pool key stays above the minimum balance
+	gasPrice, err := kp.ethClient.SuggestGasPrice(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get gas price: %w", err)
+	}
+	funderBalance, err := kp.ethClient.BalanceAt(ctx, funderAddress, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get funder balance: %w", err)
+	}
+	available := new(big.Int).Sub(funderBalance, new(big.Int).Mul(gasPrice, big.NewInt(transferGasLimit)))
+	if funderKey != nil {
+		available.Sub(available, monitor.config.MinBalance)
+	}
+	if available.Sign() <= 0 {
+		return fmt.Errorf("funder %s balance %v wei is too low to refill", funderAddress.Hex(), funderBalance)
+	}
+	if amount.Cmp(available) > 0 {
+		amount = available
+	}
+
+	// Build transfer, allocating the nonce last so a failed check doesn't leave a gap
+	var nonce uint64
+	if funderKey != nil {
+		nonce, err = kp.allocateNonce(ctx, funderKey)
+	} else {
+		nonce, err = kp.ethClient.PendingNonceAt(ctx, funderAddress)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get funder nonce: %w", err)
+	}
+	resyncNonce := func() {
+		if funderKey != nil {
+			kp.resyncNonce(funderKey)
+		}
+	}
+	tx := ethtypes.NewTransaction(nonce, address, amount, transferGasLimit, gasPrice, nil)
+
+	// Sign and send transfer, a pool key with its signer since the last reload
+	funder := monitor.config.Funder
+	if funderKey != nil {
+		funder = kp.keySigner(funderKey)
+	}
+	signedTx, err := funder.SignTx(ctx, tx, kp.chainID)
+	if err != nil {
+		resyncNonce()
+		return fmt.Errorf("failed to sign refill: %w", err)
+	}
+	if err := kp.ethClient.SendTransaction(ctx, signedTx); err != nil {
+		resyncNonce()
+		return fmt.Errorf("failed to send refill: %w", err)
+	}
+
+	monitor.refills[address] = &pendingRefill{
+		txHash: signedTx.Hash(),
+		sentAt: time.Now(),
+	}
+	metrics.KeyRefills.Inc(address.Hex(), "sent")
+	log.Printf("Refill sent: %v wei to key %s (tx: %s)", amount, address.Hex(), signedTx.Hash().Hex())
+
+	return nil
+}
+
+func (kp *KeyPool) hasKey(address common.Address) bool {
+	kp.mutex.Lock()
+	defer kp.mutex.Unlock()
+
+	for _, key := range kp.keys {
+		if key.Address == address {
+			return true
+		}
+	}
+	return false
+}
+
+// tryAcquireKey takes a pending slot on a key if it is below its limit, without waiting
+func (kp *KeyPool) tryAcquireKey(address common.Address) *PooledKey {
+	kp.mutex.Lock()
+	defer kp.mutex.Unlock()
+
+	for _, key := range kp.keys {
+		if key.Address == address && key.Pending < kp.maxPendingPerKey {
+			key.Pending++
+			return key
+		}
+	}
+	return nil
+}
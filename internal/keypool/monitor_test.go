@@ -0,0 +1,131 @@
+package keypool
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const testGasPrice = 1000
+
+// refillNode is a fake node reporting balances and recording the transactions sent to it
+type refillNode struct {
+	balances map[common.Address]*big.Int
+	sent     []*ethtypes.Transaction
+}
+
+func (node *refillNode) serve(t *testing.T) *ethclient.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "eth_gasPrice":
+			resp["result"] = hexutil.EncodeBig(big.NewInt(testGasPrice))
+		case "eth_getTransactionCount":
+			resp["result"] = "0x0"
+		case "eth_getBalance":
+			var address common.Address
+			json.Unmarshal(req.Params[0], &address)
+			balance := node.balances[address]
+			if balance == nil {
+				balance = new(big.Int)
+			}
+			resp["result"] = hexutil.EncodeBig(balance)
+		case "eth_sendRawTransaction":
+			var raw hexutil.Bytes
+			json.Unmarshal(req.Params[0], &raw)
+			tx := new(ethtypes.Transaction)
+			if err := tx.UnmarshalBinary(raw); err != nil {
+				t.Errorf("invalid raw transaction: %v", err)
+			}
+			node.sent = append(node.sent, tx)
+			resp["result"] = tx.Hash()
+		default:
+			resp["error"] = map[string]any{"code": -32601, "message": "method not found"}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := ethclient.Dial(server.URL)
+	if err != nil {
+		t.Fatalf("failed to dial fake node: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+func TestRefillKeepsFunderKeyMinimum(t *testing.T) {
+	minBalance := big.NewInt(1000000)
+	fee := big.NewInt(testGasPrice * transferGasLimit)
+	tests := []struct {
+		name          string
+		poolFunder    bool     // the funder is a pool key, rather than a separate funding key
+		funderBalance *big.Int // on top of the transfer fee
+		want          int64    // amount sent, 0 if no refill is sent
+	}{
+		{"pool key with plenty", true, big.NewInt(2000000), 100},
+		{"pool key capped at its minimum", true, big.NewInt(1000040), 40},
+		{"pool key at its minimum", true, big.NewInt(1000000), 0},
+		{"funding key spends down to the fee", false, big.NewInt(40), 40},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, funder := newLocalSigner(t), newLocalSigner(t)
+			node := &refillNode{balances: map[common.Address]*big.Int{
+				funder.Address(): new(big.Int).Add(test.funderBalance, fee),
+			}}
+
+			signers := []Signer{key}
+			config := BalanceMonitorConfig{MinBalance: minBalance, TargetBalance: big.NewInt(100)}
+			if test.poolFunder {
+				signers = append(signers, funder)
+			} else {
+				config.Funder = funder
+			}
+			kp, err := NewKeyPool(signers, node.serve(t), testChainID, StrategyRoundRobin, 1)
+			if err != nil {
+				t.Fatalf("NewKeyPool: %v", err)
+			}
+			if test.poolFunder {
+				kp.SetKeyFunders(map[common.Address]common.Address{key.Address(): funder.Address()})
+			}
+			kp.monitor = &balanceMonitor{config: config, refills: make(map[common.Address]*pendingRefill)}
+
+			funderAddress, exists := kp.funderOf(key.Address())
+			if !exists || funderAddress != funder.Address() {
+				t.Fatalf("funder of key = %s (%v), want %s", funderAddress.Hex(), exists, funder.Address().Hex())
+			}
+			err = kp.refill(context.Background(), key.Address(), new(big.Int), funderAddress)
+			if test.want == 0 {
+				if err == nil || len(node.sent) != 0 {
+					t.Fatalf("refill sent %d transactions (err: %v), want none", len(node.sent), err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("refill: %v", err)
+			}
+			if len(node.sent) != 1 || node.sent[0].Value().Int64() != test.want || *node.sent[0].To() != key.Address() {
+				t.Fatalf("refill sent %v, want one transfer of %d wei to the key", node.sent, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package keypool
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	gethrpc "github.com/ethereum/go-ethereum/rpc"
+)
+
+// Signer signs transactions sent from one address
+type Signer interface {
+	Address() common.Address
+	SignTx(ctx context.Context, tx *ethtypes.Transaction, chainID *big.Int) (*ethtypes.Transaction, error)
+}
+
+// LocalSigner signs with a private key held in memory
+type LocalSigner struct {
+	privateKey *ecdsa.PrivateKey
+	address    common.Address
+}
+
+func NewLocalSigner(privateKey *ecdsa.PrivateKey) *LocalSigner {
+	return &LocalSigner{
+		privateKey: privateKey,
+		address:    crypto.PubkeyToAddress(privateKey.PublicKey),
+	}
+}
+
+func (signer *LocalSigner) Address() common.Address {
+	return signer.address
+}
+
+func (signer *LocalSigner) SignTx(ctx context.Context, tx *ethtypes.Transaction, chainID *big.Int) (*ethtypes.Transaction, error) {
+	return ethtypes.SignTx(tx, ethtypes.LatestSignerForChainID(chainID), signer.privateKey)
+}
+
+// RemoteSigner signs with eth_signTransaction on a Web3Signer or Clef compatible
+// JSON-RPC signer, so the private key never enters gundler's memory
+type RemoteSigner struct {
+	client  *gethrpc.Client
+	address common.Address
+}
+
+func NewRemoteSigner(client *gethrpc.Client, address common.Address) *RemoteSigner {
+	return &RemoteSigner{
+		client:  client,
+		address: address,
+	}
+}
+
+// DialRemoteSigners connects to a remote signer and returns a signer for each
+// address, or for every account it reports (eth_accounts) if none are given
+func DialRemoteSigners(ctx context.Context, url string, addresses []common.Address) ([]Signer, error) {
+	client, err := gethrpc.DialContext(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to remote signer: %w", err)
+	}
+
+	if len(addresses) == 0 {
+		if err := client.CallContext(ctx, &addresses, "eth_accounts"); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to list remote signer accounts: %w", err)
+		}
+		if len(addresses) == 0 {
+			client.Close()
+			return nil, fmt.Errorf("remote signer has no accounts")
+		}
+	}
+
+	signers := make([]Signer, 0, len(addresses))
+	for _, address := range addresses {
+		signers = append(signers, NewRemoteSigner(client, address))
+	}
+	return signers, nil
+}
+
+func (signer *RemoteSigner) Address() common.Address {
+	return signer.address
+}
+
+// Close closes the connection to the remote signer, shared by every signer
+// returned from the same DialRemoteSigners call
+func (signer *RemoteSigner) Close() error {
+	signer.client.Close()
+	return nil
+}
+
+// signTransactionArgs are the eth_signTransaction parameters
+type signTransactionArgs struct {
+	From     common.Address  `json:"from"`
+	To       *common.Address `json:"to,omitempty"`
+	Gas      hexutil.Uint64  `json:"gas"`
+	GasPrice *hexutil.Big    `json:"gasPrice"`
+	Value    *hexutil.Big    `json:"value"`
+	Nonce    hexutil.Uint64  `json:"nonce"`
+	Data     hexutil.Bytes   `json:"data"`
+	ChainID  *hexutil.Big    `json:"chainId"`
+}
+
+func (signer *RemoteSigner) SignTx(ctx context.Context, tx *ethtypes.Transaction, chainID *big.Int) (*ethtypes.Transaction, error) {
+	args := signTransactionArgs{
+		From:     signer.address,
+		To:       tx.To(),
+		Gas:      hexutil.Uint64(tx.Gas()),
+		GasPrice: (*hexutil.Big)(tx.GasPrice()),
+		Value:    (*hexutil.Big)(tx.Value()),
+		Nonce:    hexutil.Uint64(tx.Nonce()),
+		Data:     tx.Data(),
+		ChainID:  (*hexutil.Big)(chainID),
+	}
+
+	var result json.RawMessage
+	if err := signer.client.CallContext(ctx, &result, "eth_signTransaction", args); err != nil {
+		return nil, fmt.Errorf("remote signer error: %w", err)
+	}
+
+	// Web3Signer returns the raw transaction, Clef returns {raw, tx}
+	var raw hexutil.Bytes
+	if strings.HasPrefix(strings.TrimSpace(string(result)), "{") {
+		var response struct {
+			Raw hexutil.Bytes `json:"raw"`
+		}
+		if err := json.Unmarshal(result, &response); err != nil {
+			return nil, fmt.Errorf("invalid remote signer response: %w", err)
+		}
+		raw = response.Raw
+	} else if err := json.Unmarshal(result, &raw); err != nil {
+		return nil, fmt.Errorf("invalid remote signer response: %w", err)
+	}
+
+	signedTx := new(ethtypes.Transaction)
+	if err := signedTx.UnmarshalBinary(raw); err != nil {
+		return nil, fmt.Errorf("invalid signed transaction from remote signer: %w", err)
+	}
+
+	// Make sure the signer signed what was asked, with the same fees, for this
+	// chain and from the expected address
+	if signedTx.Nonce() != tx.Nonce() || signedTx.Gas() != tx.Gas() ||
+		!sameRecipient(signedTx.To(), tx.To()) || signedTx.Value().Cmp(tx.Value()) != 0 || !bytes.Equal(signedTx.Data(), tx.Data()) ||
+		signedTx.GasPrice().Cmp(tx.GasPrice()) != 0 || signedTx.GasTipCap().Cmp(tx.GasTipCap()) != 0 ||
+		signedTx.GasFeeCap().Cmp(tx.GasFeeCap()) != 0 {
+		return nil, fmt.Errorf("remote signer returned a different transaction")
+	}
+	if signedTx.ChainId().Cmp(chainID) != 0 {
+		return nil, fmt.Errorf("remote signer signed for chain %s instead of %s", signedTx.ChainId(), chainID)
+	}
+	from, err := ethtypes.Sender(ethtypes.LatestSignerForChainID(chainID), signedTx)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote signature: %w", err)
+	}
+	if from != signer.address {
+		return nil, fmt.Errorf("remote signer signed from %s instead of %s", from.Hex(), signer.address.Hex())
+	}
+
+	return signedTx, nil
+}
+
+// sameRecipient reports whether two transaction recipients are equal, nil being contract creation
+func sameRecipient(a *common.Address, b *common.Address) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,210 @@
+package keypool
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	gethrpc "github.com/ethereum/go-ethereum/rpc"
+)
+
+var testChainID = big.NewInt(1337)
+
+// memorySigner is an in-memory stand-in for a Web3Signer/Clef compatible signer,
+// serving eth_accounts and eth_signTransaction for its keys
+type memorySigner struct {
+	keys        map[common.Address]*ecdsa.PrivateKey
+	tamper      func(tx *ethtypes.LegacyTx) ethtypes.TxData // changes the transaction before signing, if set
+	signChainID *big.Int                                    // signs for this chain instead of the requested one, if set
+}
+
+func newMemorySigner(t *testing.T, count int) *memorySigner {
+	t.Helper()
+	signer := &memorySigner{keys: make(map[common.Address]*ecdsa.PrivateKey)}
+	for range count {
+		privateKey, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatalf("generate key: %v", err)
+		}
+		signer.keys[crypto.PubkeyToAddress(privateKey.PublicKey)] = privateKey
+	}
+	return signer
+}
+
+func (signer *memorySigner) Accounts() []common.Address {
+	addresses := make([]common.Address, 0, len(signer.keys))
+	for address := range signer.keys {
+		addresses = append(addresses, address)
+	}
+	return addresses
+}
+
+func (signer *memorySigner) SignTransaction(args signTransactionArgs) (hexutil.Bytes, error) {
+	privateKey, exists := signer.keys[args.From]
+	if !exists {
+		return nil, fmt.Errorf("unknown account %s", args.From.Hex())
+	}
+	tx := &ethtypes.LegacyTx{
+		Nonce:    uint64(args.Nonce),
+		GasPrice: args.GasPrice.ToInt(),
+		Gas:      uint64(args.Gas),
+		To:       args.To,
+		Value:    args.Value.ToInt(),
+		Data:     args.Data,
+	}
+	var txData ethtypes.TxData = tx
+	if signer.tamper != nil {
+		txData = signer.tamper(tx)
+	}
+	chainID := args.ChainID.ToInt()
+	if signer.signChainID != nil {
+		chainID = signer.signChainID
+	}
+	signedTx, err := ethtypes.SignNewTx(privateKey, ethtypes.LatestSignerForChainID(chainID), txData)
+	if err != nil {
+		return nil, err
+	}
+	return signedTx.MarshalBinary()
+}
+
+// serve starts the stand-in as a JSON-RPC server over HTTP and returns its URL
+func (signer *memorySigner) serve(t *testing.T) string {
+	t.Helper()
+	server := gethrpc.NewServer()
+	if err := server.RegisterName("eth", signer); err != nil {
+		t.Fatalf("register signer service: %v", err)
+	}
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(func() {
+		httpServer.Close()
+		server.Stop()
+	})
+	return httpServer.URL
+}
+
+func testTransaction(to *common.Address) *ethtypes.Transaction {
+	return ethtypes.NewTx(&ethtypes.LegacyTx{
+		Nonce:    7,
+		GasPrice: big.NewInt(1000000000),
+		Gas:      100000,
+		To:       to,
+		Value:    big.NewInt(0),
+		Data:     []byte{0x01, 0x02},
+	})
+}
+
+func TestRemoteSigner(t *testing.T) {
+	stand := newMemorySigner(t, 2)
+	signers, err := DialRemoteSigners(context.Background(), stand.serve(t), nil)
+	if err != nil {
+		t.Fatalf("DialRemoteSigners: %v", err)
+	}
+	defer signers[0].(*RemoteSigner).Close()
+	if len(signers) != 2 {
+		t.Fatalf("got %d signers, want one for each of the 2 accounts", len(signers))
+	}
+
+	to := common.HexToAddress("0x0000000071727De22E5E9d8BAf0edAc6f37da032")
+	for _, tx := range []*ethtypes.Transaction{testTransaction(&to), testTransaction(nil)} {
+		for _, signer := range signers {
+			signedTx, err := signer.SignTx(context.Background(), tx, testChainID)
+			if err != nil {
+				t.Fatalf("SignTx (to %v): %v", tx.To(), err)
+			}
+			from, err := ethtypes.Sender(ethtypes.LatestSignerForChainID(testChainID), signedTx)
+			if err != nil || from != signer.Address() {
+				t.Fatalf("signed from %s (%v), want %s", from.Hex(), err, signer.Address().Hex())
+			}
+		}
+	}
+}
+
+func TestRemoteSignerRejectsDifferentTransaction(t *testing.T) {
+	to := common.HexToAddress("0x0000000071727De22E5E9d8BAf0edAc6f37da032")
+	other := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	// dynamicFee re-signs the request as an EIP-1559 transaction with the given fees
+	dynamicFee := func(tipCap, feeCap func(gasPrice *big.Int) *big.Int) func(tx *ethtypes.LegacyTx) ethtypes.TxData {
+		return func(tx *ethtypes.LegacyTx) ethtypes.TxData {
+			return &ethtypes.DynamicFeeTx{
+				ChainID:   testChainID,
+				Nonce:     tx.Nonce,
+				GasTipCap: tipCap(tx.GasPrice),
+				GasFeeCap: feeCap(tx.GasPrice),
+				Gas:       tx.Gas,
+				To:        tx.To,
+				Value:     tx.Value,
+				Data:      tx.Data,
+			}
+		}
+	}
+	same := func(gasPrice *big.Int) *big.Int { return gasPrice }
+	lower := func(gasPrice *big.Int) *big.Int { return new(big.Int).Sub(gasPrice, big.NewInt(1)) }
+	higher := func(gasPrice *big.Int) *big.Int { return new(big.Int).Add(gasPrice, big.NewInt(1)) }
+
+	tests := []struct {
+		name        string
+		to          *common.Address
+		tamper      func(tx *ethtypes.LegacyTx) ethtypes.TxData
+		signChainID *big.Int
+	}{
+		{"different nonce", &to, func(tx *ethtypes.LegacyTx) ethtypes.TxData { tx.Nonce++; return tx }, nil},
+		{"different recipient", &to, func(tx *ethtypes.LegacyTx) ethtypes.TxData { tx.To = &other; return tx }, nil},
+		{"recipient dropped", &to, func(tx *ethtypes.LegacyTx) ethtypes.TxData { tx.To = nil; return tx }, nil},
+		{"recipient added", nil, func(tx *ethtypes.LegacyTx) ethtypes.TxData { tx.To = &other; return tx }, nil},
+		{"different data", &to, func(tx *ethtypes.LegacyTx) ethtypes.TxData { tx.Data = []byte{0xff}; return tx }, nil},
+		{"different gas price", &to, func(tx *ethtypes.LegacyTx) ethtypes.TxData { tx.GasPrice = higher(tx.GasPrice); return tx }, nil},
+		{"different gas tip cap", &to, dynamicFee(lower, same), nil},
+		{"different gas fee cap", &to, dynamicFee(same, higher), nil},
+		{"different chain", &to, nil, big.NewInt(1)},
+	}
+	// The same fees as an EIP-1559 transaction are accepted
+	stand := newMemorySigner(t, 1)
+	stand.tamper = dynamicFee(same, same)
+	signers, err := DialRemoteSigners(context.Background(), stand.serve(t), nil)
+	if err != nil {
+		t.Fatalf("DialRemoteSigners: %v", err)
+	}
+	defer signers[0].(*RemoteSigner).Close()
+	if _, err := signers[0].SignTx(context.Background(), testTransaction(&to), testChainID); err != nil {
+		t.Fatalf("SignTx rejected the same fees as an EIP-1559 transaction: %v", err)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stand := newMemorySigner(t, 1)
+			stand.tamper = test.tamper
+			stand.signChainID = test.signChainID
+			signers, err := DialRemoteSigners(context.Background(), stand.serve(t), nil)
+			if err != nil {
+				t.Fatalf("DialRemoteSigners: %v", err)
+			}
+			defer signers[0].(*RemoteSigner).Close()
+
+			if _, err := signers[0].SignTx(context.Background(), testTransaction(test.to), testChainID); err == nil {
+				t.Fatalf("SignTx accepted a transaction the signer changed")
+			}
+		})
+	}
+}
+
+func TestRemoteSignerUnknownAccount(t *testing.T) {
+	stand := newMemorySigner(t, 1)
+	client, err := gethrpc.DialContext(context.Background(), stand.serve(t))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+
+	// Ask for a key the stand-in doesn't hold
+	signer := NewRemoteSigner(client, common.HexToAddress("0x3333333333333333333333333333333333333333"))
+	if _, err := signer.SignTx(context.Background(), testTransaction(nil), testChainID); err == nil {
+		t.Fatalf("SignTx succeeded for an address the signer does not hold")
+	}
+}
@@ -0,0 +1,100 @@
+package keys
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"golang.org/x/term"
+)
+
+// LoadKeystoreDir decrypts every Web3 Secret Storage (keystore JSON) file in dir
+// with the same password. Files are loaded in name order; hidden files and
+// subdirectories are skipped.
+func LoadKeystoreDir(dir string, password string) ([]*ecdsa.PrivateKey, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore directory: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	privateKeys := make([]*ecdsa.PrivateKey, 0, len(names))
+	for _, name := range names {
+		privateKey, err := LoadKeystoreFile(filepath.Join(dir, name), password)
+		if err != nil {
+			return nil, err
+		}
+		privateKeys = append(privateKeys, privateKey)
+	}
+
+	if len(privateKeys) == 0 {
+		return nil, fmt.Errorf("no keystore files found in %s", dir)
+	}
+
+	return privateKeys, nil
+}
+
+// LoadKeystoreFile decrypts a single keystore JSON file
+func LoadKeystoreFile(path string, password string) (*ecdsa.PrivateKey, error) {
+	keyJSON, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore file %s: %w", path, err)
+	}
+
+	key, err := keystore.DecryptKey(keyJSON, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt keystore file %s: %w", path, err)
+	}
+
+	log.Printf("Decrypted keystore %s: Address: %s", filepath.Base(path), key.Address.Hex())
+
+	return key.PrivateKey, nil
+}
+
+// ReadPassword reads a password from file, or prompts for it on the terminal if
+// file is empty. A single trailing newline is stripped from password files.
+func ReadPassword(file string, prompt string) (string, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read password file: %w", err)
+		}
+		return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r"), nil
+	}
+
+	// Prompt without echo
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no password file configured and stdin is not a terminal")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+
+	return string(password), nil
+}
+
+// ReadMnemonic reads a BIP-39 mnemonic from file, normalizing whitespace
+func ReadMnemonic(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read mnemonic file: %w", err)
+	}
+	return strings.Join(strings.Fields(string(data)), " "), nil
+}
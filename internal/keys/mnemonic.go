@@ -0,0 +1,113 @@
+package keys
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Default Ethereum derivation path, the last component is incremented per key
+const DefaultDerivationPath = "m/44'/60'/0'/0/0"
+
+// DeriveMnemonicKeys derives count keys from a BIP-39 mnemonic (without a BIP-39
+// passphrase) along a BIP-32 path, incrementing the last path component for
+// each key. The mnemonic checksum is not verified, so check the logged
+// addresses.
+func DeriveMnemonicKeys(mnemonic string, path string, count uint) ([]*ecdsa.PrivateKey, error) {
+	// Check mnemonic length
+	words := strings.Fields(mnemonic)
+	switch len(words) {
+	case 12, 15, 18, 21, 24:
+	default:
+		return nil, fmt.Errorf("mnemonic must have 12, 15, 18, 21 or 24 words (got: %d)", len(words))
+	}
+
+	// Parse derivation path
+	basePath, err := accounts.ParseDerivationPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid derivation path %s: %w", path, err)
+	}
+
+	// BIP-39 seed
+	seed, err := pbkdf2.Key(sha512.New, strings.Join(words, " "), []byte("mnemonic"), 2048, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive seed: %w", err)
+	}
+
+	// BIP-32 master key
+	masterKey, masterChainCode := hmacSHA512([]byte("Bitcoin seed"), seed)
+
+	privateKeys := make([]*ecdsa.PrivateKey, 0, count)
+	for i := uint(0); i < count; i++ {
+		keyPath := make(accounts.DerivationPath, len(basePath))
+		copy(keyPath, basePath)
+		keyPath[len(keyPath)-1] += uint32(i)
+
+		key, chainCode := masterKey, masterChainCode
+		for _, index := range keyPath {
+			key, chainCode, err = deriveChild(key, chainCode, index)
+			if err != nil {
+				return nil, fmt.Errorf("failed to derive %s: %w", keyPath, err)
+			}
+		}
+
+		privateKey, err := crypto.ToECDSA(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive %s: %w", keyPath, err)
+		}
+		privateKeys = append(privateKeys, privateKey)
+
+		log.Printf("Derived key %s: Address: %s", keyPath, crypto.PubkeyToAddress(privateKey.PublicKey).Hex())
+	}
+
+	return privateKeys, nil
+}
+
+// deriveChild implements BIP-32 private parent key => private child key derivation
+func deriveChild(key []byte, chainCode []byte, index uint32) ([]byte, []byte, error) {
+	// Hardened children commit to the private key, normal children to the public key
+	var data []byte
+	if index >= 0x80000000 {
+		data = append([]byte{0}, key...)
+	} else {
+		privateKey, err := crypto.ToECDSA(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		data = crypto.CompressPubkey(&privateKey.PublicKey)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	childOffset, childChainCode := hmacSHA512(chainCode, data)
+
+	// child = (offset + parent) mod n
+	curveOrder := crypto.S256().Params().N
+	offset := new(big.Int).SetBytes(childOffset)
+	if offset.Cmp(curveOrder) >= 0 {
+		return nil, nil, fmt.Errorf("invalid child key at index %d", index)
+	}
+	child := offset.Add(offset, new(big.Int).SetBytes(key))
+	child.Mod(child, curveOrder)
+	if child.Sign() == 0 {
+		return nil, nil, fmt.Errorf("invalid child key at index %d", index)
+	}
+
+	return math.PaddedBigBytes(child, 32), childChainCode, nil
+}
+
+func hmacSHA512(key []byte, data []byte) ([]byte, []byte) {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+	return sum[:32], sum[32:]
+}
@@ -0,0 +1,40 @@
+// Package logging adds a log level to the standard logger. Messages logged for
+// every userOp, bundle attempt and key reservation are debug messages, logged
+// with Debugf; everything else is logged with the log package as before.
+package logging
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+)
+
+// Log levels
+const (
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+)
+
+var debug atomic.Bool
+
+// ValidateLevel checks that a log level is debug or info
+func ValidateLevel(level string) error {
+	switch level {
+	case LevelDebug, LevelInfo:
+		return nil
+	default:
+		return fmt.Errorf("unknown log level: %s", level)
+	}
+}
+
+// SetLevel sets a log level checked with ValidateLevel
+func SetLevel(level string) {
+	debug.Store(level == LevelDebug)
+}
+
+// Debugf logs like log.Printf when the log level is debug
+func Debugf(format string, args ...any) {
+	if debug.Load() {
+		log.Output(2, fmt.Sprintf(format, args...))
+	}
+}
@@ -2,38 +2,79 @@ package mempool
 
 import (
 	"fmt"
+	"log"
 	"math/big"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/vorpalengineering/gundler/internal/events"
+	"github.com/vorpalengineering/gundler/internal/journal"
+	"github.com/vorpalengineering/gundler/internal/metrics"
 	"github.com/vorpalengineering/gundler/pkg/types"
 )
 
+// Eviction reasons
+const (
+	EvictionReasonOutbid  = "outbid"
+	EvictionReasonExpired = "expired"
+)
+
+// Limits bounds the size and age of a mempool. Zero values disable a limit;
+// gundler's config replaces unset limits with defaults before building mempools.
+type Limits struct {
+	MaxSize         int
+	MaxOpsPerSender int
+	MaxOpsPerEntity int
+	MaxAge          time.Duration
+}
+
 type Mempool struct {
-	mutex         sync.RWMutex
-	userOps       []*types.UserOperation
-	userOpsByHash map[common.Hash]*types.UserOperation
-	EntryPoint    common.Address
-	ChainID       *big.Int
-	// userOpsBySender map[common.Address]*types.UserOperation
+	mutex           sync.RWMutex
+	userOps         []*types.UserOperation
+	userOpsByHash   map[common.Hash]*types.UserOperation
+	addedAt         map[common.Hash]time.Time
+	userOpsBySender map[common.Address]int
+	userOpsByEntity map[common.Address]int
+	evictions       map[string]uint64
+	limits          Limits
+	journal         *journal.Journal
+	feed            *events.Feed
+	added           chan struct{}
+	EntryPoint      common.Address
+	ChainID         *big.Int
 }
 
-func NewMempool(entryPoint common.Address, chainID *big.Int) *Mempool {
+func NewMempool(entryPoint common.Address, chainID *big.Int, limits Limits) *Mempool {
 	return &Mempool{
-		userOps:       make([]*types.UserOperation, 0),
-		userOpsByHash: make(map[common.Hash]*types.UserOperation, 0),
-		EntryPoint:    entryPoint,
-		ChainID:       chainID,
+		userOps:         make([]*types.UserOperation, 0),
+		userOpsByHash:   make(map[common.Hash]*types.UserOperation, 0),
+		addedAt:         make(map[common.Hash]time.Time, 0),
+		userOpsBySender: make(map[common.Address]int, 0),
+		userOpsByEntity: make(map[common.Address]int, 0),
+		evictions:       make(map[string]uint64, 0),
+		limits:          limits,
+		added:           make(chan struct{}, 1),
+		EntryPoint:      entryPoint,
+		ChainID:         chainID,
 	}
 }
 
 func (pool *Mempool) Add(userOp *types.UserOperation) error {
+	return pool.add(userOp, time.Now(), true)
+}
+
+// add admits a userOp that entered the mempool at addedAt, journaling it if record is set
+func (pool *Mempool) add(userOp *types.UserOperation, addedAt time.Time, record bool) error {
+	defer pool.syncJournal()
+
 	// Acquire write lock
 	pool.mutex.Lock()
 	defer pool.mutex.Unlock()
 
 	// Validate user operation
 	if err := pool.validateUserOp(userOp); err != nil {
+		metrics.UserOpsRejected.Inc(pool.EntryPoint.Hex(), "invalid")
 		return fmt.Errorf("userOp validation failed: %w", err)
 	}
 
@@ -41,42 +82,160 @@ func (pool *Mempool) Add(userOp *types.UserOperation) error {
 	userOpHash := userOp.Hash(pool.EntryPoint, pool.ChainID)
 	_, exists := pool.userOpsByHash[userOpHash]
 	if exists {
+		metrics.UserOpsRejected.Inc(pool.EntryPoint.Hex(), "duplicate")
 		return fmt.Errorf("duplicate userOp: %v", userOpHash)
 	}
 
-	// TODO: Check pending userOps from sender
+	// Check pending userOps from sender
+	if pool.limits.MaxOpsPerSender > 0 && pool.userOpsBySender[userOp.Sender] >= pool.limits.MaxOpsPerSender {
+		metrics.UserOpsRejected.Inc(pool.EntryPoint.Hex(), "sender_limit")
+		return fmt.Errorf("sender %s has too many pending userOps (max: %d)", userOp.Sender.Hex(), pool.limits.MaxOpsPerSender)
+	}
+
+	// Check pending userOps referencing the same factory or paymaster
+	if pool.limits.MaxOpsPerEntity > 0 {
+		for _, entity := range userOpEntities(userOp) {
+			if pool.userOpsByEntity[entity] >= pool.limits.MaxOpsPerEntity {
+				metrics.UserOpsRejected.Inc(pool.EntryPoint.Hex(), "entity_limit")
+				return fmt.Errorf("entity %s has too many pending userOps (max: %d)", entity.Hex(), pool.limits.MaxOpsPerEntity)
+			}
+		}
+	}
+
+	// Check capacity, new userOp must outbid the cheapest one when full
+	if pool.limits.MaxSize > 0 && len(pool.userOps) >= pool.limits.MaxSize {
+		cheapestIndex := pool.cheapestIndex()
+		if compareFees(userOp, pool.userOps[cheapestIndex]) <= 0 {
+			metrics.UserOpsRejected.Inc(pool.EntryPoint.Hex(), "mempool_full")
+			return fmt.Errorf("mempool is full (max: %d) and userOp does not outbid the cheapest pending userOp", pool.limits.MaxSize)
+		}
+		pool.evictAt(cheapestIndex, EvictionReasonOutbid)
+	}
 
 	// Append userop to array
 	pool.userOps = append(pool.userOps, userOp)
 	pool.userOpsByHash[userOpHash] = userOp
+	pool.addedAt[userOpHash] = addedAt
+	pool.userOpsBySender[userOp.Sender]++
+	for _, entity := range userOpEntities(userOp) {
+		pool.userOpsByEntity[entity]++
+	}
+	if record {
+		pool.journal.RecordAdd(pool.EntryPoint, userOpHash, userOp, addedAt)
+	}
+	pool.feed.Publish(events.Event{
+		Type:       events.TypeUserOpAdded,
+		EntryPoint: pool.EntryPoint,
+		UserOpHash: userOpHash,
+		UserOp:     userOp,
+	})
+	pool.feed.PublishStatus(pool.EntryPoint, events.StatusPending, common.Hash{}, userOpHash)
+	metrics.UserOpsAdmitted.Inc(pool.EntryPoint.Hex())
+
+	// Notify without blocking, one pending notification is enough
+	select {
+	case pool.added <- struct{}{}:
+	default:
+	}
 
 	return nil
 }
 
+// Validate checks a userOp's fields without adding it. Mempool limits are only
+// checked by Add.
+func (pool *Mempool) Validate(userOp *types.UserOperation) error {
+	if err := pool.validateUserOp(userOp); err != nil {
+		return fmt.Errorf("userOp validation failed: %w", err)
+	}
+	return nil
+}
+
+// SetLimits changes the mempool limits. Pending userOps above a lowered limit are
+// kept; the limits apply to new userOps.
+func (pool *Mempool) SetLimits(limits Limits) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	pool.limits = limits
+}
+
+// Added returns a channel that receives a value after userOps are added
+func (pool *Mempool) Added() <-chan struct{} {
+	return pool.added
+}
+
+// SetJournal persists every subsequent change to the mempool in the journal
+func (pool *Mempool) SetJournal(j *journal.Journal) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	pool.journal = j
+}
+
+// SetEventFeed publishes subsequent additions and drops to the feed
+func (pool *Mempool) SetEventFeed(feed *events.Feed) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	pool.feed = feed
+}
+
+// syncJournal waits until the journaled changes are on disk. Deferred before
+// taking the lock, so it runs once the lock is released and readers never wait
+// on the disk.
+func (pool *Mempool) syncJournal() {
+	pool.mutex.RLock()
+	j := pool.journal
+	pool.mutex.RUnlock()
+
+	j.Sync()
+}
+
+// Restore re-adds persisted userOps, re-validating each one. They keep the time
+// they first entered the mempool, so restarts don't extend their max age, and
+// are not journaled again. Returns the number of userOps restored.
+func (pool *Mempool) Restore(userOps []journal.PersistedUserOp) int {
+	defer pool.syncJournal()
+
+	restored := 0
+	for _, persisted := range userOps {
+		userOp, addedAt := persisted.UserOp, persisted.AddedAt
+		if addedAt.IsZero() {
+			addedAt = time.Now()
+		}
+		if err := pool.add(userOp, addedAt, false); err != nil {
+			userOpHash := userOp.Hash(pool.EntryPoint, pool.ChainID)
+			log.Printf("Dropped persisted userOp %s from mempool %s: %v", userOpHash.Hex(), pool.EntryPoint.Hex(), err)
+			pool.journal.RecordRemove(pool.EntryPoint, userOpHash)
+			continue
+		}
+		restored++
+	}
+
+	return restored
+}
+
 func (pool *Mempool) RemoveByIndex(index int) error {
+	defer pool.syncJournal()
+
 	// Acquire write lock
 	pool.mutex.Lock()
 	defer pool.mutex.Unlock()
 
 	// Validate index
 	if index < 0 || index >= len(pool.userOps) {
-		return fmt.Errorf("invalid index: %s", index)
-	}
-
-	// Remove userOp by hash
-	userOpHash := pool.userOps[index].Hash(pool.EntryPoint, pool.ChainID)
-	_, exists := pool.userOpsByHash[userOpHash]
-	if exists {
-		delete(pool.userOpsByHash, userOpHash)
+		return fmt.Errorf("invalid index: %d", index)
 	}
 
 	// Remove userOp at index
-	pool.userOps = append(pool.userOps[:index], pool.userOps[index+1:]...)
+	pool.removeAt(index)
 
 	return nil
 }
 
 func (pool *Mempool) RemoveByIndexRange(begin int, end int) error {
+	defer pool.syncJournal()
+
 	// Acquire write lock
 	pool.mutex.Lock()
 	defer pool.mutex.Unlock()
@@ -86,23 +245,86 @@ func (pool *Mempool) RemoveByIndexRange(begin int, end int) error {
 		return fmt.Errorf("invalid range bounds: begin=%d, end=%d, length=%d", begin, end, len(pool.userOps))
 	}
 
-	// Remove userOps from hash map
-	for i := begin; i < end; i++ {
-		userOpHash := pool.userOps[i].Hash(pool.EntryPoint, pool.ChainID)
-		delete(pool.userOpsByHash, userOpHash)
+	// Remove userOps in reverse so earlier indices stay valid
+	for i := end - 1; i >= begin; i-- {
+		pool.removeAt(i)
 	}
 
-	// Remove userOps from slice
-	pool.userOps = append(pool.userOps[:begin], pool.userOps[end:]...)
-
 	return nil
 }
 
-func (pool *Mempool) GetByIndex(index int) (*types.UserOperation, error) {
-	// Acquire read lock
+// Remove removes the userOps with the given hashes, ignoring unknown hashes.
+// Returns the number of userOps removed.
+func (pool *Mempool) Remove(userOpHashes ...common.Hash) int {
+	defer pool.syncJournal()
+
+	// Acquire write lock
 	pool.mutex.Lock()
 	defer pool.mutex.Unlock()
 
+	removed := 0
+	for _, userOpHash := range userOpHashes {
+		if index := pool.indexOf(userOpHash); index >= 0 {
+			pool.removeAt(index)
+			removed++
+		}
+	}
+
+	return removed
+}
+
+// Drop removes the userOps with the given hashes on an operator's request,
+// publishing them as dropped. Returns the number of userOps dropped.
+func (pool *Mempool) Drop(userOpHashes ...common.Hash) int {
+	defer pool.syncJournal()
+
+	// Acquire write lock
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	dropped := make([]common.Hash, 0, len(userOpHashes))
+	for _, userOpHash := range userOpHashes {
+		if index := pool.indexOf(userOpHash); index >= 0 {
+			pool.removeAt(index)
+			dropped = append(dropped, userOpHash)
+		}
+	}
+	pool.feed.PublishStatus(pool.EntryPoint, events.StatusDropped, common.Hash{}, dropped...)
+
+	return len(dropped)
+}
+
+// EvictExpired removes every userOp older than the configured max age.
+// Returns the number of userOps evicted.
+func (pool *Mempool) EvictExpired() int {
+	defer pool.syncJournal()
+
+	// Acquire write lock
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	if pool.limits.MaxAge <= 0 {
+		return 0
+	}
+
+	cutoff := time.Now().Add(-pool.limits.MaxAge)
+	evicted := 0
+	for i := len(pool.userOps) - 1; i >= 0; i-- {
+		userOpHash := pool.userOps[i].Hash(pool.EntryPoint, pool.ChainID)
+		if pool.addedAt[userOpHash].Before(cutoff) {
+			pool.evictAt(i, EvictionReasonExpired)
+			evicted++
+		}
+	}
+
+	return evicted
+}
+
+func (pool *Mempool) GetByIndex(index int) (*types.UserOperation, error) {
+	// Acquire read lock
+	pool.mutex.RLock()
+	defer pool.mutex.RUnlock()
+
 	// Validate index
 	if index < 0 || index >= len(pool.userOps) {
 		return nil, fmt.Errorf("index out of range")
@@ -111,10 +333,20 @@ func (pool *Mempool) GetByIndex(index int) (*types.UserOperation, error) {
 	return pool.userOps[index], nil
 }
 
+// GetByHash returns the pending userOp with the given hash
+func (pool *Mempool) GetByHash(userOpHash common.Hash) (*types.UserOperation, bool) {
+	// Acquire read lock
+	pool.mutex.RLock()
+	defer pool.mutex.RUnlock()
+
+	userOp, exists := pool.userOpsByHash[userOpHash]
+	return userOp, exists
+}
+
 func (pool *Mempool) GetAll() []*types.UserOperation {
 	// Acquire read lock
-	pool.mutex.Lock()
-	defer pool.mutex.Unlock()
+	pool.mutex.RLock()
+	defer pool.mutex.RUnlock()
 
 	// Get all user operations in mempool
 	// Create a copy to avoid external modifications
@@ -126,8 +358,8 @@ func (pool *Mempool) GetAll() []*types.UserOperation {
 
 func (pool *Mempool) GetRange(begin int, end int) ([]*types.UserOperation, error) {
 	// Acquire read lock
-	pool.mutex.Lock()
-	defer pool.mutex.Unlock()
+	pool.mutex.RLock()
+	defer pool.mutex.RUnlock()
 
 	// Validate range bounds
 	if begin > end || end > len(pool.userOps) {
@@ -142,11 +374,33 @@ func (pool *Mempool) GetRange(begin int, end int) ([]*types.UserOperation, error
 }
 
 func (pool *Mempool) Clear() {
+	defer pool.syncJournal()
+
 	// Acquire write lock
 	pool.mutex.Lock()
 	defer pool.mutex.Unlock()
 
+	userOpHashes := make([]common.Hash, 0, len(pool.userOpsByHash))
+	for userOpHash := range pool.userOpsByHash {
+		userOpHashes = append(userOpHashes, userOpHash)
+	}
+	pool.journal.RecordRemove(pool.EntryPoint, userOpHashes...)
+	pool.feed.PublishStatus(pool.EntryPoint, events.StatusDropped, common.Hash{}, userOpHashes...)
+
 	pool.userOps = make([]*types.UserOperation, 0)
+	pool.userOpsByHash = make(map[common.Hash]*types.UserOperation, 0)
+	pool.addedAt = make(map[common.Hash]time.Time, 0)
+	pool.userOpsBySender = make(map[common.Address]int, 0)
+	pool.userOpsByEntity = make(map[common.Address]int, 0)
+}
+
+// Capacity returns the maximum number of userOps, or zero if unbounded
+func (pool *Mempool) Capacity() int {
+	// Acquire read lock
+	pool.mutex.RLock()
+	defer pool.mutex.RUnlock()
+
+	return pool.limits.MaxSize
 }
 
 func (pool *Mempool) Size() int {
@@ -156,3 +410,107 @@ func (pool *Mempool) Size() int {
 
 	return len(pool.userOps)
 }
+
+// Evictions returns the number of evicted userOps by eviction reason
+func (pool *Mempool) Evictions() map[string]uint64 {
+	// Acquire read lock
+	pool.mutex.RLock()
+	defer pool.mutex.RUnlock()
+
+	evictions := make(map[string]uint64, len(pool.evictions))
+	for reason, count := range pool.evictions {
+		evictions[reason] = count
+	}
+
+	return evictions
+}
+
+func (pool *Mempool) indexOf(userOpHash common.Hash) int {
+	userOp, exists := pool.userOpsByHash[userOpHash]
+	if !exists {
+		return -1
+	}
+	for i, op := range pool.userOps {
+		if op == userOp {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeAt removes the userOp at index from every index. Caller must hold the write lock.
+func (pool *Mempool) removeAt(index int) {
+	userOp := pool.userOps[index]
+	userOpHash := userOp.Hash(pool.EntryPoint, pool.ChainID)
+
+	delete(pool.userOpsByHash, userOpHash)
+	delete(pool.addedAt, userOpHash)
+	decrementCount(pool.userOpsBySender, userOp.Sender)
+	for _, entity := range userOpEntities(userOp) {
+		decrementCount(pool.userOpsByEntity, entity)
+	}
+
+	pool.userOps = append(pool.userOps[:index], pool.userOps[index+1:]...)
+	pool.journal.RecordRemove(pool.EntryPoint, userOpHash)
+}
+
+// evictAt removes the userOp at index and records the eviction. Caller must hold the write lock.
+func (pool *Mempool) evictAt(index int, reason string) {
+	userOpHash := pool.userOps[index].Hash(pool.EntryPoint, pool.ChainID)
+	pool.removeAt(index)
+	pool.evictions[reason]++
+	metrics.UserOpsEvicted.Inc(pool.EntryPoint.Hex(), reason)
+	pool.feed.PublishStatus(pool.EntryPoint, events.StatusDropped, common.Hash{}, userOpHash)
+
+	log.Printf("Evicted userOp %s from mempool %s (reason: %s)", userOpHash.Hex(), pool.EntryPoint.Hex(), reason)
+}
+
+// cheapestIndex returns the index of the lowest paying userOp. Caller must hold a lock.
+func (pool *Mempool) cheapestIndex() int {
+	cheapest := 0
+	for i := 1; i < len(pool.userOps); i++ {
+		if compareFees(pool.userOps[i], pool.userOps[cheapest]) < 0 {
+			cheapest = i
+		}
+	}
+	return cheapest
+}
+
+// compareFees orders userOps by maxPriorityFeePerGas, then maxFeePerGas. Unset
+// fees count as zero.
+func compareFees(a *types.UserOperation, b *types.UserOperation) int {
+	if cmp := compareFee(a.MaxPriorityFeePerGas, b.MaxPriorityFeePerGas); cmp != 0 {
+		return cmp
+	}
+	return compareFee(a.MaxFeePerGas, b.MaxFeePerGas)
+}
+
+func compareFee(a *big.Int, b *big.Int) int {
+	if a == nil {
+		a = common.Big0
+	}
+	if b == nil {
+		b = common.Big0
+	}
+	return a.Cmp(b)
+}
+
+// userOpEntities returns the factory and paymaster referenced by the userOp, if set
+func userOpEntities(userOp *types.UserOperation) []common.Address {
+	entities := make([]common.Address, 0, 2)
+	if userOp.Factory != (common.Address{}) {
+		entities = append(entities, userOp.Factory)
+	}
+	if userOp.Paymaster != (common.Address{}) {
+		entities = append(entities, userOp.Paymaster)
+	}
+	return entities
+}
+
+func decrementCount(counts map[common.Address]int, address common.Address) {
+	if counts[address] <= 1 {
+		delete(counts, address)
+		return
+	}
+	counts[address]--
+}
@@ -0,0 +1,228 @@
+package mempool
+
+import (
+	"bytes"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vorpalengineering/gundler/internal/journal"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+var testEntryPoint = types.EntryPointV07Address
+
+func testUserOp(sender byte, nonce int64) *types.UserOperation {
+	return &types.UserOperation{
+		Sender:               common.Address{sender},
+		Nonce:                big.NewInt(nonce),
+		CallData:             []byte{0x01},
+		CallGasLimit:         big.NewInt(100000),
+		VerificationGasLimit: big.NewInt(100000),
+		PreVerificationGas:   big.NewInt(50000),
+		MaxFeePerGas:         big.NewInt(2000000000),
+		MaxPriorityFeePerGas: big.NewInt(1000000000),
+		Signature:            []byte{0x02},
+	}
+}
+
+func TestRestoreKeepsAddedTime(t *testing.T) {
+	dir := t.TempDir()
+	j, err := journal.Open(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to open journal: %v", err)
+	}
+	defer j.Close()
+
+	pool := NewMempool(testEntryPoint, big.NewInt(1), Limits{MaxAge: time.Hour})
+	pool.SetJournal(j)
+	restored := pool.Restore([]journal.PersistedUserOp{
+		{UserOp: testUserOp(0xaa, 0), AddedAt: time.Now().Add(-2 * time.Hour)},
+		{UserOp: testUserOp(0xbb, 0), AddedAt: time.Now().Add(-time.Minute)},
+	})
+	if restored != 2 {
+		t.Fatalf("restored %d userOps, want 2", restored)
+	}
+
+	// Restored userOps are not journaled again
+	data, err := os.ReadFile(filepath.Join(dir, "mempool.journal"))
+	if err != nil {
+		t.Fatalf("failed to read journal: %v", err)
+	}
+	if adds := bytes.Count(data, []byte(`"type":"add"`)); adds != 0 {
+		t.Fatalf("journal has %d add records after restore, want 0", adds)
+	}
+
+	// The userOp older than the max age before the restart expires
+	if evicted := pool.EvictExpired(); evicted != 1 {
+		t.Fatalf("evicted %d userOps, want 1", evicted)
+	}
+	if _, exists := pool.GetByHash(testUserOp(0xbb, 0).Hash(testEntryPoint, big.NewInt(1))); !exists {
+		t.Fatalf("recent userOp evicted")
+	}
+}
+
+// withFees sets the userOp's maxPriorityFeePerGas and maxFeePerGas in gwei
+func withFees(userOp *types.UserOperation, priorityFee int64, maxFee int64) *types.UserOperation {
+	userOp.MaxPriorityFeePerGas = big.NewInt(priorityFee * 1000000000)
+	userOp.MaxFeePerGas = big.NewInt(maxFee * 1000000000)
+	return userOp
+}
+
+// withFactory sets the userOp's factory
+func withFactory(userOp *types.UserOperation, factory byte) *types.UserOperation {
+	userOp.Factory = common.Address{factory}
+	userOp.FactoryData = []byte{0x03}
+	return userOp
+}
+
+func TestAddLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  Limits
+		pending []*types.UserOperation
+		userOp  *types.UserOperation
+		wantErr bool
+		evicted *types.UserOperation // pending userOp evicted to make room, if any
+	}{
+		{
+			name:    "outbids the cheapest userOp when full",
+			limits:  Limits{MaxSize: 2},
+			pending: []*types.UserOperation{withFees(testUserOp(0xa1, 0), 1, 2), withFees(testUserOp(0xa2, 0), 2, 3)},
+			userOp:  withFees(testUserOp(0xa3, 0), 3, 3),
+			evicted: withFees(testUserOp(0xa1, 0), 1, 2),
+		},
+		{
+			name:    "outbids on maxFeePerGas with the same priority fee",
+			limits:  Limits{MaxSize: 2},
+			pending: []*types.UserOperation{withFees(testUserOp(0xa1, 0), 1, 2), withFees(testUserOp(0xa2, 0), 1, 3)},
+			userOp:  withFees(testUserOp(0xa3, 0), 1, 4),
+			evicted: withFees(testUserOp(0xa1, 0), 1, 2),
+		},
+		{
+			name:    "same fees as the cheapest userOp when full",
+			limits:  Limits{MaxSize: 2},
+			pending: []*types.UserOperation{withFees(testUserOp(0xa1, 0), 1, 2), withFees(testUserOp(0xa2, 0), 2, 3)},
+			userOp:  withFees(testUserOp(0xa3, 0), 1, 2),
+			wantErr: true,
+		},
+		{
+			name:    "room left",
+			limits:  Limits{MaxSize: 3},
+			pending: []*types.UserOperation{withFees(testUserOp(0xa1, 0), 2, 2)},
+			userOp:  withFees(testUserOp(0xa2, 0), 1, 1),
+		},
+		{
+			name:    "sender at its limit",
+			limits:  Limits{MaxOpsPerSender: 2},
+			pending: []*types.UserOperation{testUserOp(0xa1, 0), testUserOp(0xa1, 1)},
+			userOp:  testUserOp(0xa1, 2),
+			wantErr: true,
+		},
+		{
+			name:    "other sender",
+			limits:  Limits{MaxOpsPerSender: 2},
+			pending: []*types.UserOperation{testUserOp(0xa1, 0), testUserOp(0xa1, 1)},
+			userOp:  testUserOp(0xa2, 0),
+		},
+		{
+			name:    "factory at its limit",
+			limits:  Limits{MaxOpsPerEntity: 2},
+			pending: []*types.UserOperation{withFactory(testUserOp(0xa1, 0), 0xf1), withFactory(testUserOp(0xa2, 0), 0xf1)},
+			userOp:  withFactory(testUserOp(0xa3, 0), 0xf1),
+			wantErr: true,
+		},
+		{
+			name:    "other factory",
+			limits:  Limits{MaxOpsPerEntity: 2},
+			pending: []*types.UserOperation{withFactory(testUserOp(0xa1, 0), 0xf1), withFactory(testUserOp(0xa2, 0), 0xf1)},
+			userOp:  withFactory(testUserOp(0xa3, 0), 0xf2),
+		},
+		{
+			name:    "limits disabled",
+			pending: []*types.UserOperation{withFactory(testUserOp(0xa1, 0), 0xf1), withFactory(testUserOp(0xa1, 1), 0xf1)},
+			userOp:  withFactory(testUserOp(0xa1, 2), 0xf1),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pool := NewMempool(testEntryPoint, big.NewInt(1), test.limits)
+			for _, userOp := range test.pending {
+				if err := pool.Add(userOp); err != nil {
+					t.Fatalf("Add pending userOp: %v", err)
+				}
+			}
+
+			err := pool.Add(test.userOp)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Add err = %v, want error %v", err, test.wantErr)
+			}
+
+			size := len(test.pending)
+			if !test.wantErr {
+				size++
+			}
+			if test.evicted != nil {
+				size--
+				if _, exists := pool.GetByHash(test.evicted.Hash(testEntryPoint, big.NewInt(1))); exists {
+					t.Fatalf("cheapest userOp was not evicted")
+				}
+				if evictions := pool.Evictions()[EvictionReasonOutbid]; evictions != 1 {
+					t.Fatalf("outbid evictions = %d, want 1", evictions)
+				}
+			}
+			if pool.Size() != size {
+				t.Fatalf("mempool size = %d, want %d", pool.Size(), size)
+			}
+		})
+	}
+}
+
+func TestEvictExpired(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxAge  time.Duration
+		ages    []time.Duration
+		evicted int
+	}{
+		{"none expired", time.Hour, []time.Duration{time.Minute, 59 * time.Minute}, 0},
+		{"some expired", time.Hour, []time.Duration{time.Minute, 61 * time.Minute, 2 * time.Hour}, 2},
+		{"max age disabled", 0, []time.Duration{24 * time.Hour}, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pool := NewMempool(testEntryPoint, big.NewInt(1), Limits{MaxAge: test.maxAge})
+			for i, age := range test.ages {
+				if err := pool.add(testUserOp(byte(0xa0+i), 0), time.Now().Add(-age), false); err != nil {
+					t.Fatalf("add: %v", err)
+				}
+			}
+
+			if evicted := pool.EvictExpired(); evicted != test.evicted {
+				t.Fatalf("evicted %d userOps, want %d", evicted, test.evicted)
+			}
+			if pool.Size() != len(test.ages)-test.evicted {
+				t.Fatalf("mempool size = %d, want %d", pool.Size(), len(test.ages)-test.evicted)
+			}
+			if evictions := pool.Evictions()[EvictionReasonExpired]; evictions != uint64(test.evicted) {
+				t.Fatalf("expired evictions = %d, want %d", evictions, test.evicted)
+			}
+		})
+	}
+}
+
+func TestCompareFeesUnset(t *testing.T) {
+	unset := testUserOp(0xa1, 0)
+	unset.MaxPriorityFeePerGas = nil
+	unset.MaxFeePerGas = nil
+
+	if cmp := compareFees(unset, withFees(testUserOp(0xa2, 0), 1, 1)); cmp >= 0 {
+		t.Fatalf("compareFees(unset, paying) = %d, want < 0", cmp)
+	}
+	if cmp := compareFees(unset, unset); cmp != 0 {
+		t.Fatalf("compareFees(unset, unset) = %d, want 0", cmp)
+	}
+}
@@ -0,0 +1,391 @@
+package metrics
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bundler metrics
+var (
+	MempoolSize = newGaugeVec(
+		"gundler_mempool_size",
+		"Number of userOps in the mempool",
+		"entry_point",
+	)
+	UserOpsAdmitted = newCounterVec(
+		"gundler_userops_admitted_total",
+		"UserOps admitted to the mempool",
+		"entry_point",
+	)
+	UserOpsRejected = newCounterVec(
+		"gundler_userops_rejected_total",
+		"UserOps rejected from the mempool by reason",
+		"entry_point", "reason",
+	)
+	UserOpsEvicted = newCounterVec(
+		"gundler_userops_evicted_total",
+		"UserOps evicted from the mempool by reason",
+		"entry_point", "reason",
+	)
+	BundlesBuilt = newCounterVec(
+		"gundler_bundles_built_total",
+		"Bundles built from the mempool",
+		"entry_point",
+	)
+	BundlesSubmitted = newCounterVec(
+		"gundler_bundles_submitted_total",
+		"Bundle transactions sent to the node",
+		"entry_point",
+	)
+	BundlesIncluded = newCounterVec(
+		"gundler_bundles_included_total",
+		"Bundle transactions included successfully",
+		"entry_point",
+	)
+	BundlesFailed = newCounterVec(
+		"gundler_bundles_failed_total",
+		"Bundles that failed simulation or to submit, reverted or were dropped",
+		"entry_point", "reason",
+	)
+	BundleSize = newHistogramVec(
+		"gundler_bundle_size",
+		"Number of userOps per bundle",
+		[]float64{1, 2, 4, 8, 16, 32, 64},
+		"entry_point",
+	)
+	BundleGas = newHistogramVec(
+		"gundler_bundle_gas",
+		"Total userOp gas limit per bundle",
+		[]float64{1e5, 2.5e5, 5e5, 1e6, 2.5e6, 5e6, 1e7, 2.5e7},
+		"entry_point",
+	)
+	RPCDuration = newHistogramVec(
+		"gundler_rpc_request_duration_seconds",
+		"RPC request latency by method",
+		[]float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+		"method",
+	)
+	RPCErrors = newCounterVec(
+		"gundler_rpc_errors_total",
+		"RPC error responses by method and code",
+		"method", "code",
+	)
+	APIRequests = newCounterVec(
+		"gundler_api_requests_total",
+		"RPC requests by API key (anonymous without one) and rate limit result (allowed, limited)",
+		"api_key", "result",
+	)
+	KeyBalance = newGaugeVec(
+		"gundler_key_balance_wei",
+		"Balance of each bundler key",
+		"address",
+	)
+	KeyLowBalance = newGaugeVec(
+		"gundler_key_low_balance",
+		"Whether each bundler key is below the minimum balance and out of rotation (1) or not (0)",
+		"address",
+	)
+	KeyRefills = newCounterVec(
+		"gundler_key_refills_total",
+		"Key refill transfers by result",
+		"address", "result",
+	)
+	KeyInFlight = newGaugeVec(
+		"gundler_key_in_flight",
+		"Number of pending bundle transactions sent by each bundler key",
+		"address",
+	)
+)
+
+// Time limit for collect hooks that query the node
+const collectTimeout = 5 * time.Second
+
+type metric interface {
+	write(builder *strings.Builder)
+}
+
+var (
+	registryMutex sync.Mutex
+	registry      []metric
+	collectHooks  []func(ctx context.Context)
+)
+
+// OnCollect registers a hook that updates gauges before each scrape
+func OnCollect(hook func(ctx context.Context)) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	collectHooks = append(collectHooks, hook)
+}
+
+// Handler serves all metrics in the Prometheus text exposition format
+func Handler(w http.ResponseWriter, r *http.Request) {
+	registryMutex.Lock()
+	hooks := append([]func(ctx context.Context){}, collectHooks...)
+	metrics := append([]metric{}, registry...)
+	registryMutex.Unlock()
+
+	// Refresh collected gauges
+	ctx, cancel := context.WithTimeout(r.Context(), collectTimeout)
+	defer cancel()
+	for _, hook := range hooks {
+		hook(ctx)
+	}
+
+	var builder strings.Builder
+	for _, m := range metrics {
+		m.write(&builder)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(builder.String()))
+}
+
+func register(m metric) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	registry = append(registry, m)
+}
+
+// vec holds one value per label combination
+type vec[T any] struct {
+	name       string
+	help       string
+	metricType string
+	labels     []string
+	mutex      sync.Mutex
+	values     map[string]*labeledValue[T]
+}
+
+type labeledValue[T any] struct {
+	labelValues []string
+	value       T
+}
+
+func (v *vec[T]) get(labelValues []string, init func() T) *labeledValue[T] {
+	key := strings.Join(labelValues, "\xff")
+	value, exists := v.values[key]
+	if !exists {
+		value = &labeledValue[T]{
+			labelValues: append([]string{}, labelValues...),
+			value:       init(),
+		}
+		v.values[key] = value
+	}
+	return value
+}
+
+// sorted returns values ordered by labels for stable output. Caller must hold the lock.
+func (v *vec[T]) sorted() []*labeledValue[T] {
+	keys := make([]string, 0, len(v.values))
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := make([]*labeledValue[T], 0, len(keys))
+	for _, key := range keys {
+		values = append(values, v.values[key])
+	}
+	return values
+}
+
+func (v *vec[T]) writeHeader(builder *strings.Builder) {
+	builder.WriteString("# HELP " + v.name + " " + v.help + "\n")
+	builder.WriteString("# TYPE " + v.name + " " + v.metricType + "\n")
+}
+
+type CounterVec struct {
+	vec[float64]
+}
+
+func newCounterVec(name string, help string, labels ...string) *CounterVec {
+	counter := &CounterVec{vec[float64]{
+		name:       name,
+		help:       help,
+		metricType: "counter",
+		labels:     labels,
+		values:     make(map[string]*labeledValue[float64]),
+	}}
+	register(counter)
+	return counter
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.get(labelValues, zero).value += delta
+}
+
+func (c *CounterVec) write(builder *strings.Builder) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.writeHeader(builder)
+	for _, value := range c.sorted() {
+		writeSample(builder, c.name, c.labels, value.labelValues, "", "", value.value)
+	}
+}
+
+type GaugeVec struct {
+	vec[float64]
+}
+
+func newGaugeVec(name string, help string, labels ...string) *GaugeVec {
+	gauge := &GaugeVec{vec[float64]{
+		name:       name,
+		help:       help,
+		metricType: "gauge",
+		labels:     labels,
+		values:     make(map[string]*labeledValue[float64]),
+	}}
+	register(gauge)
+	return gauge
+}
+
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.get(labelValues, zero).value = value
+}
+
+// Delete removes the value for a label combination
+func (g *GaugeVec) Delete(labelValues ...string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	delete(g.values, strings.Join(labelValues, "\xff"))
+}
+
+func (g *GaugeVec) write(builder *strings.Builder) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.writeHeader(builder)
+	for _, value := range g.sorted() {
+		writeSample(builder, g.name, g.labels, value.labelValues, "", "", value.value)
+	}
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, non-cumulative
+	count  uint64
+	sum    float64
+}
+
+type HistogramVec struct {
+	vec[*histogram]
+	buckets []float64
+}
+
+func newHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	hist := &HistogramVec{
+		vec: vec[*histogram]{
+			name:       name,
+			help:       help,
+			metricType: "histogram",
+			labels:     labels,
+			values:     make(map[string]*labeledValue[*histogram]),
+		},
+		buckets: buckets,
+	}
+	register(hist)
+	return hist
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	hist := h.get(labelValues, func() *histogram {
+		return &histogram{counts: make([]uint64, len(h.buckets))}
+	}).value
+	for i, bound := range h.buckets {
+		if value <= bound {
+			hist.counts[i]++
+			break
+		}
+	}
+	hist.count++
+	hist.sum += value
+}
+
+// ObserveDuration records the time elapsed since start in seconds
+func (h *HistogramVec) ObserveDuration(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (h *HistogramVec) write(builder *strings.Builder) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.writeHeader(builder)
+	for _, value := range h.sorted() {
+		hist := value.value
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += hist.counts[i]
+			writeSample(builder, h.name+"_bucket", h.labels, value.labelValues, "le", formatFloat(bound), float64(cumulative))
+		}
+		writeSample(builder, h.name+"_bucket", h.labels, value.labelValues, "le", "+Inf", float64(hist.count))
+		writeSample(builder, h.name+"_sum", h.labels, value.labelValues, "", "", hist.sum)
+		writeSample(builder, h.name+"_count", h.labels, value.labelValues, "", "", float64(hist.count))
+	}
+}
+
+func zero() float64 {
+	return 0
+}
+
+// writeSample writes one sample line with an optional extra label (e.g. le)
+func writeSample(builder *strings.Builder, name string, labels []string, labelValues []string, extraLabel string, extraValue string, value float64) {
+	builder.WriteString(name)
+
+	pairs := make([]string, 0, len(labels)+1)
+	for i, label := range labels {
+		labelValue := ""
+		if i < len(labelValues) {
+			labelValue = labelValues[i]
+		}
+		pairs = append(pairs, label+"=\""+escapeLabelValue(labelValue)+"\"")
+	}
+	if extraLabel != "" {
+		pairs = append(pairs, extraLabel+"=\""+extraValue+"\"")
+	}
+	if len(pairs) > 0 {
+		builder.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+
+	builder.WriteString(" " + formatFloat(value) + "\n")
+}
+
+func escapeLabelValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
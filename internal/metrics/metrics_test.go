@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// scrape returns the metrics served by Handler
+func scrape(t *testing.T) string {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	Handler(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Fatalf("Content-Type = %s, want the Prometheus text format", contentType)
+	}
+	return recorder.Body.String()
+}
+
+func expectLines(t *testing.T, output string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(output, line+"\n") {
+			t.Fatalf("metrics are missing %q:\n%s", line, output)
+		}
+	}
+}
+
+func TestCounterAndGauge(t *testing.T) {
+	counter := newCounterVec("test_requests_total", "Test requests", "method", "code")
+	counter.Inc("eth_chainId", "0")
+	counter.Add(2, "eth_chainId", "0")
+	counter.Inc("eth_sendUserOperation", "-32602")
+
+	gauge := newGaugeVec("test_balance", "Test balance", "address")
+	gauge.Set(5, "0xaa")
+	gauge.Set(7, "0xbb")
+	gauge.Delete("0xbb")
+
+	expectLines(t, scrape(t),
+		"# HELP test_requests_total Test requests",
+		"# TYPE test_requests_total counter",
+		`test_requests_total{method="eth_chainId",code="0"} 3`,
+		`test_requests_total{method="eth_sendUserOperation",code="-32602"} 1`,
+		"# TYPE test_balance gauge",
+		`test_balance{address="0xaa"} 5`,
+	)
+	if output := scrape(t); strings.Contains(output, `test_balance{address="0xbb"}`) {
+		t.Fatalf("deleted gauge value is still served")
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	hist := newHistogramVec("test_bundle_size", "Test bundle size", []float64{1, 4}, "entry_point")
+	for _, value := range []float64{1, 2, 3, 10} {
+		hist.Observe(value, "0xep")
+	}
+
+	// Buckets are cumulative, +Inf counts every observation
+	expectLines(t, scrape(t),
+		"# TYPE test_bundle_size histogram",
+		`test_bundle_size_bucket{entry_point="0xep",le="1"} 1`,
+		`test_bundle_size_bucket{entry_point="0xep",le="4"} 3`,
+		`test_bundle_size_bucket{entry_point="0xep",le="+Inf"} 4`,
+		`test_bundle_size_sum{entry_point="0xep"} 16`,
+		`test_bundle_size_count{entry_point="0xep"} 4`,
+	)
+}
+
+func TestLabelEscaping(t *testing.T) {
+	counter := newCounterVec("test_escaped_total", "Test escaping", "reason")
+	counter.Inc("quote \" backslash \\ newline \n")
+
+	expectLines(t, scrape(t), `test_escaped_total{reason="quote \" backslash \\ newline \n"} 1`)
+}
+
+func TestCollectHooks(t *testing.T) {
+	gauge := newGaugeVec("test_collected", "Test collected gauge")
+	collected := 0
+	OnCollect(func(ctx context.Context) {
+		collected++
+		gauge.Set(float64(collected))
+	})
+
+	scrape(t)
+	expectLines(t, scrape(t), "test_collected 2")
+}
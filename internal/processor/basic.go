@@ -2,67 +2,198 @@ package processor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math/big"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/vorpalengineering/gundler/internal/events"
+	"github.com/vorpalengineering/gundler/internal/history"
+	"github.com/vorpalengineering/gundler/internal/journal"
 	"github.com/vorpalengineering/gundler/internal/keypool"
+	"github.com/vorpalengineering/gundler/internal/logging"
 	"github.com/vorpalengineering/gundler/internal/mempool"
+	"github.com/vorpalengineering/gundler/internal/metrics"
+	"github.com/vorpalengineering/gundler/internal/reputation"
 	"github.com/vorpalengineering/gundler/pkg/types"
 )
 
+// Submitted bundles without a receipt after this long are considered dropped
+const pendingBundleTimeout = 10 * time.Minute
+
+// How often pending bundles are checked for receipts while shutting down
+const shutdownPollInterval = time.Second
+
+// Bundle simulation attempts on node transport errors, and the delay between them
+const (
+	simulationAttempts   = 3
+	simulationRetryDelay = 500 * time.Millisecond
+)
+
+// Rejected bundles a userOp can be part of before it is evicted, when the
+// EntryPoint rejects them without the userOp failing on its own
+const maxUserOpFailures = 3
+
+// ticker paces the processing loop, tests replace it with a fake clock
+type ticker interface {
+	Chan() <-chan time.Time
+	Reset(d time.Duration)
+	Stop()
+}
+
+type timeTicker struct {
+	*time.Ticker
+}
+
+func (t timeTicker) Chan() <-chan time.Time {
+	return t.C
+}
+
+func newTimeTicker(d time.Duration) ticker {
+	return timeTicker{time.NewTicker(d)}
+}
+
 type BasicProcessor struct {
-	mempool       *mempool.Mempool
-	ethClient     *ethclient.Client
-	interval      time.Duration
-	stopChannel   chan struct{}
-	doneChannel   chan struct{}
-	paused        bool
-	pauseMutex    sync.RWMutex
-	maxBundleSize uint
-	keyPool       *keypool.KeyPool
+	mempool            *mempool.Mempool
+	ethClient          *ethclient.Client
+	bundling           BundlingConfig
+	bundlingMutex      sync.RWMutex // guards bundling, keys, beneficiary and simulate
+	intervalChanged    chan struct{}
+	newTicker          func(d time.Duration) ticker
+	stopChannel        chan struct{}
+	stopOnce           sync.Once // Stop may be called more than once
+	doneChannel        chan struct{}
+	cancel             context.CancelFunc // abandons a bundle in progress on Stop
+	state              string
+	stateMutex         sync.RWMutex
+	running            bool
+	runningMutex       sync.RWMutex
+	keyPool            *keypool.KeyPool
+	keys               []common.Address // keys used for bundles, all keys if empty
+	beneficiary        common.Address
+	simulate           bool
+	journal            *journal.Journal
+	history            *history.Store
+	reputation         *reputation.Reputation
+	feed               *events.Feed
+	bundleMutex        sync.Mutex
+	failures           map[common.Hash]int // rejected bundles per userOp, guarded by bundleMutex
+	pendingBundles     map[common.Hash]*journal.PendingBundle
+	pendingBundleMutex sync.Mutex
 }
 
 func NewBasicProcessor(
 	mempool *mempool.Mempool,
 	ethClient *ethclient.Client,
-	interval time.Duration,
-	maxBundleSize uint,
+	bundling BundlingConfig,
 	keyPool *keypool.KeyPool,
+	keys []common.Address,
+	beneficiary common.Address,
+	simulate bool,
+	mempoolJournal *journal.Journal,
+	historyStore *history.Store,
+	entityReputation *reputation.Reputation,
+	eventFeed *events.Feed,
 ) *BasicProcessor {
 	return &BasicProcessor{
-		mempool:       mempool,
-		ethClient:     ethClient,
-		interval:      interval,
-		stopChannel:   make(chan struct{}),
-		doneChannel:   make(chan struct{}),
-		maxBundleSize: maxBundleSize,
-		keyPool:       keyPool,
+		mempool:         mempool,
+		ethClient:       ethClient,
+		bundling:        bundling,
+		state:           StateActive,
+		intervalChanged: make(chan struct{}, 1),
+		newTicker:       newTimeTicker,
+		stopChannel:     make(chan struct{}),
+		doneChannel:     make(chan struct{}),
+		keyPool:         keyPool,
+		keys:            keys,
+		beneficiary:     beneficiary,
+		simulate:        simulate,
+		journal:         mempoolJournal,
+		history:         historyStore,
+		reputation:      entityReputation,
+		feed:            eventFeed,
+		pendingBundles:  make(map[common.Hash]*journal.PendingBundle),
+		failures:        make(map[common.Hash]int),
 	}
 }
 
 func (processor *BasicProcessor) Start(ctx context.Context) error {
-	log.Printf("Starting Basic Processor with %v interval", processor.interval)
+	log.Printf("Starting Basic Processor in %s mode with %v interval", processor.bundling.Mode, processor.bundling.Interval)
 
+	// Restore pending bundles so their keys are not reused before they confirm
+	for _, bundle := range processor.journal.PendingBundles(processor.mempool.EntryPoint) {
+		processor.keyPool.MarkKeyInFlight(bundle.KeyAddress)
+		processor.addPendingBundle(bundle)
+		log.Printf("Restored pending bundle: tx=%s, key=%s", bundle.TxHash.Hex(), bundle.KeyAddress.Hex())
+	}
+
+	ctx, processor.cancel = context.WithCancel(ctx)
 	go processor.run(ctx)
 
 	return nil
 }
 
-func (processor *BasicProcessor) Stop() error {
-	log.Println("Stopping Basic Processor...")
-	close(processor.stopChannel)
-	<-processor.doneChannel
+// Stop stops the processing loop, letting a bundle in progress finish until ctx
+// is done. An abandoned bundle's userOps stay in the mempool. Calling Stop again
+// waits for the loop to finish.
+func (processor *BasicProcessor) Stop(ctx context.Context) error {
+	processor.stopOnce.Do(func() {
+		log.Println("Stopping Basic Processor...")
+		close(processor.stopChannel)
+	})
+
+	select {
+	case <-processor.doneChannel:
+	case <-ctx.Done():
+		log.Println("Abandoning bundle in progress")
+		processor.cancel()
+		<-processor.doneChannel
+	}
+	processor.cancel()
+
 	log.Println("Basic Processor Stopped")
 	return nil
 }
 
+// WaitPendingBundles checks submitted bundles for receipts until all are
+// finalized or ctx is done. Returns the number of bundles still pending, which
+// stay in the journal and are checked again after a restart.
+func (processor *BasicProcessor) WaitPendingBundles(ctx context.Context) int {
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for {
+		processor.checkPendingBundles(ctx)
+		pending := len(processor.getPendingBundles())
+		if pending == 0 {
+			return 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return pending
+		case <-ticker.C:
+		}
+	}
+}
+
 func (processor *BasicProcessor) run(ctx context.Context) {
 	defer close(processor.doneChannel)
 
-	ticker := time.NewTicker(processor.interval)
+	processor.setRunning(true)
+	defer processor.setRunning(false)
+
+	ticker := processor.newTicker(processor.getBundlingConfig().Interval)
 	defer ticker.Stop()
 
 	for {
@@ -71,118 +202,646 @@ func (processor *BasicProcessor) run(ctx context.Context) {
 			return
 		case <-processor.stopChannel:
 			return
-		case <-ticker.C:
+		case <-ticker.Chan():
 			if err := processor.processOnce(ctx); err != nil {
 				log.Printf("Processing error: %v", err)
 			}
+		case <-processor.intervalChanged:
+			ticker.Reset(processor.getBundlingConfig().Interval)
+		case <-processor.mempool.Added():
+			if err := processor.processThreshold(ctx); err != nil {
+				log.Printf("Processing error: %v", err)
+			}
 		}
 	}
 }
 
 func (processor *BasicProcessor) processOnce(ctx context.Context) error {
-	// Check if paused
-	if processor.IsPaused() {
+	// Evict expired userOps (even while paused)
+	processor.mempool.EvictExpired()
+
+	// Check submitted bundles for receipts (even while paused)
+	processor.checkPendingBundles(ctx)
+
+	// Recover entity reputation over time
+	processor.reputation.Decay()
+
+	// Check if paused or bundling manually
+	if processor.IsPaused() || processor.BundlingMode() != types.BundlingModeAuto {
+		return nil
+	}
+
+	_, err := processor.bundle(ctx)
+	return err
+}
+
+// processThreshold bundles ahead of the interval once the pending userOps reach the size or gas threshold
+func (processor *BasicProcessor) processThreshold(ctx context.Context) error {
+	// Check if paused or bundling manually
+	if processor.IsPaused() || processor.BundlingMode() != types.BundlingModeAuto {
+		return nil
+	}
+
+	if !processor.thresholdReached() {
 		return nil
 	}
 
+	_, err := processor.bundle(ctx)
+	return err
+}
+
+func (processor *BasicProcessor) thresholdReached() bool {
+	bundling := processor.getBundlingConfig()
+
+	// Check size threshold
+	mempoolSize := processor.mempool.Size()
+	if bundling.SizeThreshold > 0 && mempoolSize >= int(bundling.SizeThreshold) {
+		return true
+	}
+
+	// Check gas threshold of the userOps that would be bundled next
+	if bundling.GasThreshold == 0 {
+		return false
+	}
+	bundleSize := min(mempoolSize, int(bundling.MaxBundleSize))
+	userOps, err := processor.mempool.GetRange(0, bundleSize)
+	if err != nil {
+		return false
+	}
+	userOps = limitBundleGas(userOps, bundling.MaxBundleGas)
+	totalGas := new(big.Int)
+	for _, userOp := range userOps {
+		totalGas.Add(totalGas, userOp.TotalGasLimit())
+	}
+
+	return totalGas.Cmp(new(big.Int).SetUint64(bundling.GasThreshold)) >= 0
+}
+
+// SendBundleNow builds and submits a bundle immediately, even while paused.
+// Returns the bundle transaction hash, or the zero hash if the mempool is empty.
+func (processor *BasicProcessor) SendBundleNow(ctx context.Context) (common.Hash, error) {
+	return processor.bundle(ctx)
+}
+
+func (processor *BasicProcessor) bundle(ctx context.Context) (common.Hash, error) {
+	// Only one bundle is built at a time so userOps are never bundled twice
+	processor.bundleMutex.Lock()
+	defer processor.bundleMutex.Unlock()
+
 	// Check mempool size
 	mempoolSize := processor.mempool.Size()
 	if mempoolSize == 0 {
-		return nil
+		return common.Hash{}, nil
 	}
 
 	// Calculate bundle size (min of mempool size and max bundle size)
-	bundleSize := int(processor.maxBundleSize)
+	bundling := processor.getBundlingConfig()
+	bundleSize := int(bundling.MaxBundleSize)
 	if mempoolSize < bundleSize {
 		bundleSize = mempoolSize
 	}
 
-	// Get userops from mempool by range
+	// Get userops from mempool by range, within the bundle gas limit
 	userOps, err := processor.mempool.GetRange(0, bundleSize)
 	if err != nil {
-		return fmt.Errorf("error getting userOps by range: %v", err)
+		return common.Hash{}, fmt.Errorf("error getting userOps by range: %v", err)
 	}
+	userOps = limitBundleGas(userOps, bundling.MaxBundleGas)
 
 	// Create Bundle from mempool userops
 	bundle := processor.createBundle(userOps)
-
-	// TODO: simulate bundle
+	processor.observeBundle(bundle)
 
 	// Submit Bundle to Chain
-	err = processor.submitBundle(ctx, bundle, bundleSize)
+	txHash, err := processor.submitBundle(ctx, bundle)
 	if err != nil {
-		return fmt.Errorf("error submitting bundle: %v", err)
+		return common.Hash{}, fmt.Errorf("error submitting bundle: %v", err)
 	}
 
-	return nil
+	return txHash, nil
 }
 
 func (processor *BasicProcessor) createBundle(userOps []*types.UserOperation) *Bundle {
+	processor.bundlingMutex.RLock()
+	defer processor.bundlingMutex.RUnlock()
+
 	return &Bundle{
-		UserOps:    userOps,
-		EntryPoint: processor.mempool.EntryPoint,
+		UserOps:     userOps,
+		EntryPoint:  processor.mempool.EntryPoint,
+		Beneficiary: processor.beneficiary,
 	}
 }
 
-func (processor *BasicProcessor) simulateBundle(ctx context.Context, bundle *Bundle) error {
-	return nil
+// observeBundle records the size and total gas limit of a built bundle
+func (processor *BasicProcessor) observeBundle(bundle *Bundle) {
+	totalGas := new(big.Int)
+	for _, userOp := range bundle.UserOps {
+		totalGas.Add(totalGas, userOp.TotalGasLimit())
+	}
+	gas, _ := new(big.Float).SetInt(totalGas).Float64()
+
+	entryPoint := bundle.EntryPoint.Hex()
+	metrics.BundlesBuilt.Inc(entryPoint)
+	metrics.BundleSize.Observe(float64(len(bundle.UserOps)), entryPoint)
+	metrics.BundleGas.Observe(gas, entryPoint)
 }
 
-func (processor *BasicProcessor) submitBundle(ctx context.Context, bundle *Bundle, bundleSize int) error {
-	log.Printf("Submitting bundle to chain... size: %v", len(bundle.UserOps))
+// limitBundleGas returns the leading userOps whose total gas limit fits in
+// maxGas (at least one userOp), or all of them if maxGas is 0
+func limitBundleGas(userOps []*types.UserOperation, maxGas uint64) []*types.UserOperation {
+	if maxGas == 0 {
+		return userOps
+	}
+	limit := new(big.Int).SetUint64(maxGas)
+	totalGas := new(big.Int)
+	for i, userOp := range userOps {
+		totalGas.Add(totalGas, userOp.TotalGasLimit())
+		if i > 0 && totalGas.Cmp(limit) > 0 {
+			return userOps[:i]
+		}
+	}
+	return userOps
+}
 
-	// TODO: Build actual transaction for EntryPoint.handleOps() call
-	// For now, this is a placeholder that demonstrates keypool usage
-	// In a real implementation, you would:
-	// 1. Pack the userOps into the EntryPoint.handleOps() call data
-	// 2. Create a transaction with proper gas limits and values
-	// 3. Submit via keypool
+// simulateBundle calls handleOps from the sending key without sending a transaction.
+// Node transport errors are retried, a revert is returned as is.
+func (processor *BasicProcessor) simulateBundle(ctx context.Context, bundle *Bundle, from common.Address, callData []byte) error {
+	var err error
+	for attempt := range simulationAttempts {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(simulationRetryDelay):
+			}
+		}
 
-	// Placeholder: We'll add actual transaction building in a future update
-	log.Printf("TODO: Build and submit transaction for %d userOps to EntryPoint %s",
-		len(bundle.UserOps), bundle.EntryPoint.Hex())
+		_, err = processor.ethClient.CallContract(ctx, ethereum.CallMsg{
+			From: from,
+			To:   &bundle.EntryPoint,
+			Data: callData,
+		}, nil)
+		var rpcErr rpc.Error
+		if err == nil || errors.As(err, &rpcErr) || ctx.Err() != nil {
+			return err
+		}
+		log.Printf("Bundle simulation attempt %d of %d failed: %v", attempt+1, simulationAttempts, err)
+	}
+	return err
+}
 
-	// Example of how keypool will be used:
-	// tx := ethtypes.NewTransaction(...)
-	// txHash, keyAddress, err := processor.keyPool.SubmitTransaction(ctx, tx)
-	// if err != nil {
-	//     return fmt.Errorf("failed to submit bundle transaction: %w", err)
-	// }
-	// defer processor.keyPool.ReleaseKey(keyAddress)
-	// log.Printf("Bundle submitted: tx=%s, key=%s", txHash.Hex(), keyAddress.Hex())
+func (processor *BasicProcessor) submitBundle(ctx context.Context, bundle *Bundle) (common.Hash, error) {
+	logging.Debugf("Submitting bundle to chain... size: %v", len(bundle.UserOps))
 
-	// Remove bundled userOps from mempool
-	err := processor.mempool.RemoveByIndexRange(0, bundleSize)
+	// The userOps stay in the mempool until the bundle is sent, so a key, fee or
+	// node error leaves them for a later bundle
+	userOpHashes := make([]common.Hash, 0, len(bundle.UserOps))
+	for _, userOp := range bundle.UserOps {
+		userOpHashes = append(userOpHashes, userOp.Hash(bundle.EntryPoint, processor.mempool.ChainID))
+	}
+
+	// Pack the userOps into the EntryPoint.handleOps() call data
+	entryPoint, err := types.GetEntryPoint(bundle.EntryPoint)
 	if err != nil {
-		return fmt.Errorf("error removing bundled userops: %v", err)
+		metrics.BundlesFailed.Inc(bundle.EntryPoint.Hex(), "submit")
+		return common.Hash{}, err
+	}
+	callData, err := entryPoint.PackHandleOps(bundle.UserOps, bundle.Beneficiary)
+	if err != nil {
+		metrics.BundlesFailed.Inc(bundle.EntryPoint.Hex(), "submit")
+		return common.Hash{}, fmt.Errorf("failed to pack handleOps: %w", err)
 	}
 
-	return nil
+	// Reserve the sending key, the key stays in-flight until the bundle confirms
+	keyAddress, err := processor.keyPool.AcquireKey(ctx, processor.getKeys())
+	if err != nil {
+		return common.Hash{}, processor.submitFailed(ctx, bundle, "submit", err)
+	}
+
+	// Simulate from the sending key, evicting a userOp the EntryPoint rejects
+	if processor.isSimulating() {
+		if err := processor.simulateBundle(ctx, bundle, keyAddress, callData); err != nil {
+			processor.keyPool.ReleaseKey(keyAddress)
+			processor.evictRejectedOps(ctx, bundle, userOpHashes, keyAddress, err)
+			return common.Hash{}, processor.submitFailed(ctx, bundle, "simulation", fmt.Errorf("bundle simulation failed: %w", err))
+		}
+	}
+
+	// Send, evicting a userOp the EntryPoint rejects and keeping the rest for a later bundle
+	tx, err := processor.keyPool.SendTransaction(ctx, keyAddress, bundle.EntryPoint, callData)
+	if err != nil {
+		processor.evictRejectedOps(ctx, bundle, userOpHashes, keyAddress, err)
+		return common.Hash{}, processor.submitFailed(ctx, bundle, "submit", fmt.Errorf("failed to submit bundle transaction: %w", err))
+	}
+	txHash := tx.Hash()
+	log.Printf("Bundle submitted: tx=%s, key=%s", txHash.Hex(), keyAddress.Hex())
+	metrics.BundlesSubmitted.Inc(bundle.EntryPoint.Hex())
+
+	// Track the pending bundle before leaving the mempool so the userOps are always known
+	pendingBundle := &journal.PendingBundle{
+		EntryPoint:   bundle.EntryPoint,
+		TxHash:       txHash,
+		KeyAddress:   keyAddress,
+		Nonce:        tx.Nonce(),
+		UserOpHashes: userOpHashes,
+		UserOps:      bundle.UserOps,
+		SubmittedAt:  time.Now(),
+	}
+	processor.addPendingBundle(pendingBundle)
+	processor.journal.RecordBundleSubmitted(pendingBundle)
+	processor.journal.Sync()
+
+	// Remove bundled userOps from mempool (by hash, evictions may have shifted indices)
+	processor.mempool.Remove(userOpHashes...)
+	for _, userOpHash := range userOpHashes {
+		delete(processor.failures, userOpHash)
+	}
+
+	// Notify subscribers
+	processor.feed.Publish(events.Event{
+		Type:         events.TypeBundle,
+		EntryPoint:   bundle.EntryPoint,
+		TxHash:       txHash,
+		UserOpHashes: userOpHashes,
+	})
+	processor.feed.PublishStatus(bundle.EntryPoint, events.StatusSubmitted, txHash, userOpHashes...)
+
+	return txHash, nil
 }
 
-func (processor *BasicProcessor) Pause() {
-	processor.pauseMutex.Lock()
-	defer processor.pauseMutex.Unlock()
+// submitFailed counts a bundle that was not sent and returns its error, or the
+// abandon error if ctx was cancelled (e.g. on shutdown)
+func (processor *BasicProcessor) submitFailed(ctx context.Context, bundle *Bundle, reason string, err error) error {
+	if ctx.Err() != nil {
+		log.Printf("Bundle abandoned, %d userOps kept in the mempool", len(bundle.UserOps))
+		return fmt.Errorf("bundle abandoned: %w", ctx.Err())
+	}
+	metrics.BundlesFailed.Inc(bundle.EntryPoint.Hex(), reason)
+	return err
+}
+
+// evictRejectedOps removes the userOps to blame for a bundle the EntryPoint
+// rejected, so they don't block the userOps behind them. A FailedOp revert names
+// the userOp. Otherwise each userOp is simulated alone and those that fail are
+// removed, and if none does, every userOp in the bundle is charged a failure and
+// removed after maxUserOpFailures. Errors other than reverts (node, key or fee
+// errors) remove nothing. Caller must hold bundleMutex.
+func (processor *BasicProcessor) evictRejectedOps(ctx context.Context, bundle *Bundle, userOpHashes []common.Hash, from common.Address, err error) {
+	if ctx.Err() != nil || !isRevert(err) {
+		return
+	}
+	if processor.removeFailedOp(bundle, userOpHashes, err) {
+		return
+	}
+
+	// Simulate each userOp alone to find the ones that fail
+	isolated := false
+	for i, userOp := range bundle.UserOps {
+		opErr := processor.simulateUserOp(ctx, bundle, userOp, from)
+		if opErr == nil || !isRevert(opErr) {
+			continue
+		}
+		reason := opErr.Error()
+		if failedOp, ok := unpackFailedOp(opErr); ok {
+			reason = failedOp.Reason
+		}
+		processor.removeUserOp(bundle.EntryPoint, userOpHashes[i], reason)
+		isolated = true
+	}
+	if isolated {
+		return
+	}
+
+	// Charge every userOp when the bundle only fails as a whole
+	for userOpHash := range processor.failures {
+		if _, exists := processor.mempool.GetByHash(userOpHash); !exists {
+			delete(processor.failures, userOpHash)
+		}
+	}
+	for _, userOpHash := range userOpHashes {
+		processor.failures[userOpHash]++
+		if processor.failures[userOpHash] >= maxUserOpFailures {
+			processor.removeUserOp(bundle.EntryPoint, userOpHash, fmt.Sprintf("part of %d rejected bundles: %v", maxUserOpFailures, err))
+		}
+	}
+}
+
+// simulateUserOp calls handleOps with the userOp alone from the sending key
+func (processor *BasicProcessor) simulateUserOp(ctx context.Context, bundle *Bundle, userOp *types.UserOperation, from common.Address) error {
+	entryPoint, err := types.GetEntryPoint(bundle.EntryPoint)
+	if err != nil {
+		return err
+	}
+	callData, err := entryPoint.PackHandleOps([]*types.UserOperation{userOp}, bundle.Beneficiary)
+	if err != nil {
+		return fmt.Errorf("failed to pack handleOps: %w", err)
+	}
+
+	single := &Bundle{
+		UserOps:     []*types.UserOperation{userOp},
+		EntryPoint:  bundle.EntryPoint,
+		Beneficiary: bundle.Beneficiary,
+	}
+	return processor.simulateBundle(ctx, single, from, callData)
+}
+
+// removeFailedOp removes the userOp named by a FailedOp revert in err from the
+// mempool. Returns false if err is not a FailedOp revert.
+func (processor *BasicProcessor) removeFailedOp(bundle *Bundle, userOpHashes []common.Hash, err error) bool {
+	failedOp, ok := unpackFailedOp(err)
+	if !ok || failedOp.OpIndex < 0 || failedOp.OpIndex >= len(userOpHashes) {
+		return false
+	}
+
+	processor.removeUserOp(bundle.EntryPoint, userOpHashes[failedOp.OpIndex], failedOp.Reason)
+	return true
+}
+
+// removeUserOp removes a userOp rejected by the EntryPoint from the mempool and publishes it as failed
+func (processor *BasicProcessor) removeUserOp(entryPoint common.Address, userOpHash common.Hash, reason string) {
+	log.Printf("Removing userOp %s rejected by EntryPoint: %s", userOpHash.Hex(), reason)
+	delete(processor.failures, userOpHash)
+	processor.mempool.Remove(userOpHash)
+	processor.feed.PublishStatus(entryPoint, events.StatusFailed, common.Hash{}, userOpHash)
+}
+
+// isRevert reports whether a node error is the EntryPoint reverting, as opposed
+// to a node, key or fee error that says nothing about the userOps
+func isRevert(err error) bool {
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) && dataErr.ErrorData() != nil {
+		return true
+	}
+	return strings.Contains(err.Error(), "execution reverted")
+}
+
+// unpackFailedOp decodes the FailedOp revert carried by a node error, if any
+func unpackFailedOp(err error) (*types.FailedOp, bool) {
+	var dataErr rpc.DataError
+	if !errors.As(err, &dataErr) {
+		return nil, false
+	}
+	hexData, ok := dataErr.ErrorData().(string)
+	if !ok {
+		return nil, false
+	}
+	data, decodeErr := hexutil.Decode(hexData)
+	if decodeErr != nil {
+		return nil, false
+	}
+	failedOp, unpackErr := types.UnpackFailedOp(data)
+	if unpackErr != nil {
+		return nil, false
+	}
+	return failedOp, true
+}
+
+func (processor *BasicProcessor) checkPendingBundles(ctx context.Context) {
+	// Oldest first, so a dropped transaction is found before the ones stuck behind it
+	bundles := processor.getPendingBundles()
+	sort.Slice(bundles, func(a, b int) bool { return bundles[a].SubmittedAt.Before(bundles[b].SubmittedAt) })
+
+	for _, bundle := range bundles {
+		receipt, err := processor.ethClient.TransactionReceipt(ctx, bundle.TxHash)
+		if errors.Is(err, ethereum.NotFound) {
+			switch {
+			case processor.keyPool.BehindDroppedNonce(bundle.KeyAddress, bundle.Nonce, bundle.SubmittedAt):
+				// Stuck behind a dropped transaction of the key, dropped with it
+				processor.finalizeBundle(bundle, nil, BundleStatusDropped)
+			case time.Since(bundle.SubmittedAt) > pendingBundleTimeout:
+				// A dropped transaction leaves a gap in the key's nonces
+				processor.keyPool.ResyncNonce(bundle.KeyAddress, bundle.Nonce)
+				processor.finalizeBundle(bundle, nil, BundleStatusDropped)
+			}
+			continue
+		}
+		if err != nil {
+			log.Printf("Error getting receipt for bundle %s: %v", bundle.TxHash.Hex(), err)
+			continue
+		}
+
+		if receipt.Status == ethtypes.ReceiptStatusSuccessful {
+			processor.finalizeBundle(bundle, receipt, BundleStatusIncluded)
+		} else {
+			processor.finalizeBundle(bundle, receipt, BundleStatusFailed)
+		}
+	}
+}
+
+func (processor *BasicProcessor) finalizeBundle(bundle *journal.PendingBundle, receipt *ethtypes.Receipt, status string) {
+	processor.keyPool.ReleaseKey(bundle.KeyAddress)
+
+	// Index userOp outcomes before the bundle is forgotten by the journal
+	fallbackStatus := history.StatusFailed
+	if status == BundleStatusDropped {
+		fallbackStatus = history.StatusDropped
+	}
+	entries := history.EntriesFromReceipt(
+		bundle.EntryPoint,
+		processor.mempool.ChainID,
+		bundle.UserOps,
+		bundle.TxHash,
+		receipt,
+		fallbackStatus,
+	)
+	if err := processor.history.Put(entries...); err != nil {
+		log.Printf("Error indexing bundle %s: %v", bundle.TxHash.Hex(), err)
+	}
+
+	// Forget the bundle once its userOps are indexed, so lookups always find them
+	processor.pendingBundleMutex.Lock()
+	delete(processor.pendingBundles, bundle.TxHash)
+	processor.pendingBundleMutex.Unlock()
+
+	// Notify subscribers of final userOp statuses
+	for _, entry := range entries {
+		switch entry.Status {
+		case history.StatusSuccess:
+			processor.feed.PublishStatus(entry.EntryPoint, events.StatusIncluded, entry.TxHash, entry.UserOpHash)
+		case history.StatusDropped:
+			processor.feed.PublishStatus(entry.EntryPoint, events.StatusDropped, entry.TxHash, entry.UserOpHash)
+		default:
+			processor.feed.PublishStatus(entry.EntryPoint, events.StatusFailed, entry.TxHash, entry.UserOpHash)
+		}
+	}
+
+	// Credit entities of included userOps
+	for _, entry := range entries {
+		if !entry.Included() {
+			continue
+		}
+		if entry.UserOp != nil {
+			processor.reputation.UpdateIncluded(reputation.Entities(entry.UserOp)...)
+		} else {
+			processor.reputation.UpdateIncluded(entry.Sender)
+		}
+	}
+
+	processor.journal.RecordBundleOutcome(bundle.EntryPoint, bundle.TxHash, status)
+	processor.journal.Sync()
+
+	switch status {
+	case BundleStatusIncluded:
+		metrics.BundlesIncluded.Inc(bundle.EntryPoint.Hex())
+	case BundleStatusDropped:
+		metrics.BundlesFailed.Inc(bundle.EntryPoint.Hex(), "dropped")
+	default:
+		metrics.BundlesFailed.Inc(bundle.EntryPoint.Hex(), "reverted")
+	}
+
+	log.Printf("Bundle %s: tx=%s, userOps=%d", status, bundle.TxHash.Hex(), len(bundle.UserOpHashes))
+}
+
+func (processor *BasicProcessor) addPendingBundle(bundle *journal.PendingBundle) {
+	processor.pendingBundleMutex.Lock()
+	defer processor.pendingBundleMutex.Unlock()
 
-	if !processor.paused {
-		processor.paused = true
-		log.Println("Basic Processor Paused")
+	processor.pendingBundles[bundle.TxHash] = bundle
+}
+
+func (processor *BasicProcessor) getPendingBundles() []*journal.PendingBundle {
+	processor.pendingBundleMutex.Lock()
+	defer processor.pendingBundleMutex.Unlock()
+
+	bundles := make([]*journal.PendingBundle, 0, len(processor.pendingBundles))
+	for _, bundle := range processor.pendingBundles {
+		bundles = append(bundles, bundle)
 	}
+
+	return bundles
 }
 
-func (processor *BasicProcessor) Unpause() {
-	processor.pauseMutex.Lock()
-	defer processor.pauseMutex.Unlock()
+// SubmittedUserOp returns a userOp whose bundle is submitted but not finalized,
+// along with the bundle transaction hash
+func (processor *BasicProcessor) SubmittedUserOp(userOpHash common.Hash) (*types.UserOperation, common.Hash, bool) {
+	processor.pendingBundleMutex.Lock()
+	defer processor.pendingBundleMutex.Unlock()
 
-	if processor.paused {
-		processor.paused = false
-		log.Println("Basic Processor Unpaused")
+	for _, bundle := range processor.pendingBundles {
+		for i, hash := range bundle.UserOpHashes {
+			if hash == userOpHash && i < len(bundle.UserOps) {
+				return bundle.UserOps[i], bundle.TxHash, true
+			}
+		}
 	}
+
+	return nil, common.Hash{}, false
+}
+
+func (processor *BasicProcessor) SetBundlingMode(mode string) error {
+	if mode != types.BundlingModeAuto && mode != types.BundlingModeManual {
+		return fmt.Errorf("invalid bundling mode: %s (expected auto or manual)", mode)
+	}
+
+	processor.bundlingMutex.Lock()
+	defer processor.bundlingMutex.Unlock()
+
+	if processor.bundling.Mode != mode {
+		processor.bundling.Mode = mode
+		log.Printf("Basic Processor bundling mode set to %s", mode)
+	}
+
+	return nil
+}
+
+// Reconfigure applies new bundling settings, keys, beneficiary and simulation
+// while running. An empty bundling mode keeps the current mode.
+func (processor *BasicProcessor) Reconfigure(bundling BundlingConfig, keys []common.Address, beneficiary common.Address, simulate bool) {
+	processor.bundlingMutex.Lock()
+	defer processor.bundlingMutex.Unlock()
+
+	if bundling.Mode == "" {
+		bundling.Mode = processor.bundling.Mode
+	}
+	intervalChanged := bundling.Interval != processor.bundling.Interval
+	processor.bundling = bundling
+	processor.keys = keys
+	processor.beneficiary = beneficiary
+	processor.simulate = simulate
+
+	// Restart the bundling ticker without blocking, one pending notification is enough
+	if intervalChanged {
+		select {
+		case processor.intervalChanged <- struct{}{}:
+		default:
+		}
+	}
+
+	log.Printf("Basic Processor reconfigured: %s mode, %v interval, max bundle size %v", bundling.Mode, bundling.Interval, bundling.MaxBundleSize)
+}
+
+func (processor *BasicProcessor) getKeys() []common.Address {
+	processor.bundlingMutex.RLock()
+	defer processor.bundlingMutex.RUnlock()
+
+	return processor.keys
+}
+
+func (processor *BasicProcessor) isSimulating() bool {
+	processor.bundlingMutex.RLock()
+	defer processor.bundlingMutex.RUnlock()
+
+	return processor.simulate
+}
+
+func (processor *BasicProcessor) BundlingMode() string {
+	return processor.getBundlingConfig().Mode
+}
+
+func (processor *BasicProcessor) getBundlingConfig() BundlingConfig {
+	processor.bundlingMutex.RLock()
+	defer processor.bundlingMutex.RUnlock()
+
+	return processor.bundling
+}
+
+// Pause stops bundling, userOps are still accepted
+func (processor *BasicProcessor) Pause() {
+	processor.setState(StatePaused)
+}
+
+// Resume bundles and accepts userOps again after Pause or Drain
+func (processor *BasicProcessor) Resume() {
+	processor.setState(StateActive)
+}
+
+// Drain keeps bundling the pending userOps but stops accepting new ones
+func (processor *BasicProcessor) Drain() {
+	processor.setState(StateDraining)
+}
+
+func (processor *BasicProcessor) State() string {
+	processor.stateMutex.RLock()
+	defer processor.stateMutex.RUnlock()
+
+	return processor.state
 }
 
 func (processor *BasicProcessor) IsPaused() bool {
-	processor.pauseMutex.RLock()
-	defer processor.pauseMutex.RUnlock()
+	return processor.State() == StatePaused
+}
+
+func (processor *BasicProcessor) setState(state string) {
+	processor.stateMutex.Lock()
+	defer processor.stateMutex.Unlock()
+
+	if processor.state != state {
+		log.Printf("Basic Processor for %s %s (was %s)", processor.mempool.EntryPoint.Hex(), state, processor.state)
+		processor.state = state
+	}
+}
+
+// IsRunning reports whether the processing loop is running
+func (processor *BasicProcessor) IsRunning() bool {
+	processor.runningMutex.RLock()
+	defer processor.runningMutex.RUnlock()
+
+	return processor.running
+}
+
+func (processor *BasicProcessor) setRunning(running bool) {
+	processor.runningMutex.Lock()
+	defer processor.runningMutex.Unlock()
 
-	return processor.paused
+	processor.running = running
 }
@@ -0,0 +1,383 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/vorpalengineering/gundler/internal/history"
+	"github.com/vorpalengineering/gundler/internal/journal"
+	"github.com/vorpalengineering/gundler/internal/keypool"
+	"github.com/vorpalengineering/gundler/internal/mempool"
+	"github.com/vorpalengineering/gundler/internal/reputation"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+var testEntryPoint = types.EntryPointV07Address
+
+// An Error(string) revert, which does not name the failing userOp
+var testRevertData = hexutil.Encode(append(hexutil.MustDecode("0x08c379a0"), make([]byte, 64)...))
+
+func testUserOp(sender byte) *types.UserOperation {
+	return &types.UserOperation{
+		Sender:               common.Address{sender},
+		Nonce:                big.NewInt(0),
+		CallData:             []byte{0x01},
+		CallGasLimit:         big.NewInt(100000),
+		VerificationGasLimit: big.NewInt(100000),
+		PreVerificationGas:   big.NewInt(50000),
+		MaxFeePerGas:         big.NewInt(2000000000),
+		MaxPriorityFeePerGas: big.NewInt(1000000000),
+		Signature:            []byte{0x02},
+	}
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    string `json:"data,omitempty"`
+}
+
+// fakeNode answers eth_call with the error call returns for the call data, or an
+// empty result, and eth_getTransactionReceipt with no receipt
+func fakeNode(t *testing.T, call func(data []byte) *rpcError) *ethclient.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+		var msg struct {
+			Input hexutil.Bytes `json:"input"`
+			Data  hexutil.Bytes `json:"data"`
+		}
+		if req.Method == "eth_getTransactionReceipt" {
+			resp["result"] = nil
+		} else if req.Method != "eth_call" || len(req.Params) == 0 || json.Unmarshal(req.Params[0], &msg) != nil {
+			resp["error"] = rpcError{Code: -32601, Message: "method not found"}
+		} else if rpcErr := call(append(msg.Input, msg.Data...)); rpcErr != nil {
+			resp["error"] = rpcErr
+		} else {
+			resp["result"] = "0x"
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := ethclient.Dial(server.URL)
+	if err != nil {
+		t.Fatalf("failed to dial fake node: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+// containsSender reports whether handleOps call data includes a userOp from sender
+func containsSender(data []byte, sender byte) bool {
+	return bytes.Contains(data, common.LeftPadBytes(common.Address{sender}.Bytes(), 32))
+}
+
+// rejectedBundle fills a mempool with userOps from the senders and returns a
+// processor for it with the bundle of those userOps
+func rejectedBundle(t *testing.T, node *ethclient.Client, senders ...byte) (*BasicProcessor, *Bundle, []common.Hash) {
+	t.Helper()
+	pool := mempool.NewMempool(testEntryPoint, big.NewInt(1), mempool.Limits{})
+	for _, sender := range senders {
+		if err := pool.Add(testUserOp(sender)); err != nil {
+			t.Fatalf("failed to add userOp: %v", err)
+		}
+	}
+	processor := NewBasicProcessor(pool, node, BundlingConfig{}, nil, nil, common.Address{}, true, nil, nil, nil, nil)
+
+	bundle := processor.createBundle(pool.GetAll())
+	userOpHashes := make([]common.Hash, 0, len(bundle.UserOps))
+	for _, userOp := range bundle.UserOps {
+		userOpHashes = append(userOpHashes, userOp.Hash(testEntryPoint, pool.ChainID))
+	}
+	return processor, bundle, userOpHashes
+}
+
+func packHandleOps(t *testing.T, bundle *Bundle) []byte {
+	t.Helper()
+	entryPoint, err := types.GetEntryPoint(bundle.EntryPoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	callData, err := entryPoint.PackHandleOps(bundle.UserOps, bundle.Beneficiary)
+	if err != nil {
+		t.Fatalf("failed to pack handleOps: %v", err)
+	}
+	return callData
+}
+
+func TestEvictRejectedOpsIsolatesFailingUserOp(t *testing.T) {
+	// The userOp from sender 0xbb reverts, alone or bundled
+	node := fakeNode(t, func(data []byte) *rpcError {
+		if containsSender(data, 0xbb) {
+			return &rpcError{Code: 3, Message: "execution reverted", Data: testRevertData}
+		}
+		return nil
+	})
+	processor, bundle, userOpHashes := rejectedBundle(t, node, 0xaa, 0xbb, 0xcc)
+
+	ctx := context.Background()
+	err := processor.simulateBundle(ctx, bundle, common.Address{}, packHandleOps(t, bundle))
+	if err == nil {
+		t.Fatalf("bundle simulation passed, want revert")
+	}
+	processor.evictRejectedOps(ctx, bundle, userOpHashes, common.Address{}, err)
+
+	if _, exists := processor.mempool.GetByHash(userOpHashes[1]); exists {
+		t.Fatalf("failing userOp kept in the mempool")
+	}
+	if size := processor.mempool.Size(); size != 2 {
+		t.Fatalf("mempool size = %d, want the 2 other userOps", size)
+	}
+}
+
+func TestEvictRejectedOpsChargesBundle(t *testing.T) {
+	// The userOps only revert when bundled together
+	node := fakeNode(t, func(data []byte) *rpcError {
+		if containsSender(data, 0xaa) && containsSender(data, 0xbb) {
+			return &rpcError{Code: 3, Message: "execution reverted", Data: testRevertData}
+		}
+		return nil
+	})
+	processor, bundle, userOpHashes := rejectedBundle(t, node, 0xaa, 0xbb)
+	callData := packHandleOps(t, bundle)
+
+	ctx := context.Background()
+	for attempt := 1; attempt <= maxUserOpFailures; attempt++ {
+		err := processor.simulateBundle(ctx, bundle, common.Address{}, callData)
+		if err == nil {
+			t.Fatalf("bundle simulation passed, want revert")
+		}
+		processor.evictRejectedOps(ctx, bundle, userOpHashes, common.Address{}, err)
+
+		want := 2
+		if attempt == maxUserOpFailures {
+			want = 0
+		}
+		if size := processor.mempool.Size(); size != want {
+			t.Fatalf("mempool size after %d rejections = %d, want %d", attempt, size, want)
+		}
+	}
+}
+
+func TestEvictRejectedOpsIgnoresNodeErrors(t *testing.T) {
+	node := fakeNode(t, func(data []byte) *rpcError {
+		return &rpcError{Code: -32000, Message: "insufficient funds for gas * price + value"}
+	})
+	processor, bundle, userOpHashes := rejectedBundle(t, node, 0xaa)
+	callData := packHandleOps(t, bundle)
+
+	ctx := context.Background()
+	for range maxUserOpFailures {
+		err := processor.simulateBundle(ctx, bundle, common.Address{}, callData)
+		if err == nil {
+			t.Fatalf("bundle simulation passed, want error")
+		}
+		processor.evictRejectedOps(ctx, bundle, userOpHashes, common.Address{}, err)
+	}
+	if size := processor.mempool.Size(); size != 1 {
+		t.Fatalf("mempool size = %d, want the userOp kept", size)
+	}
+}
+
+func TestDroppedBundleDropsLaterNonces(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	signer := keypool.NewLocalSigner(privateKey)
+	keyPool, err := keypool.NewKeyPool([]keypool.Signer{signer}, nil, big.NewInt(1), keypool.StrategyRoundRobin, 4)
+	if err != nil {
+		t.Fatalf("NewKeyPool: %v", err)
+	}
+	historyStore, err := history.Open("", history.Retention{})
+	if err != nil {
+		t.Fatalf("history.Open: %v", err)
+	}
+	defer historyStore.Close()
+
+	pool := mempool.NewMempool(testEntryPoint, big.NewInt(1), mempool.Limits{})
+	processor := NewBasicProcessor(pool, fakeNode(t, func([]byte) *rpcError { return nil }), BundlingConfig{}, keyPool, nil, common.Address{}, false, nil, historyStore, nil, nil)
+
+	// Nonce 5 is past the timeout, nonce 6 was sent after it and is recent enough to wait on its own
+	sent := time.Now().Add(-pendingBundleTimeout - time.Minute)
+	bundles := []*journal.PendingBundle{
+		{EntryPoint: testEntryPoint, TxHash: common.HexToHash("0x05"), KeyAddress: signer.Address(), Nonce: 5, SubmittedAt: sent},
+		{EntryPoint: testEntryPoint, TxHash: common.HexToHash("0x06"), KeyAddress: signer.Address(), Nonce: 6, SubmittedAt: time.Now().Add(-time.Minute)},
+	}
+	for _, bundle := range bundles {
+		processor.addPendingBundle(bundle)
+	}
+
+	processor.checkPendingBundles(context.Background())
+	if pending := len(processor.getPendingBundles()); pending != 0 {
+		t.Fatalf("%d bundles still pending, want the one behind the dropped nonce dropped too", pending)
+	}
+
+	// Nonce 7 is sent once nonce 5 was found dropped, so it doesn't wait behind it
+	late := &journal.PendingBundle{EntryPoint: testEntryPoint, TxHash: common.HexToHash("0x07"), KeyAddress: signer.Address(), Nonce: 7, SubmittedAt: time.Now().Add(time.Second)}
+	processor.addPendingBundle(late)
+	processor.checkPendingBundles(context.Background())
+	if pending := len(processor.getPendingBundles()); pending != 1 {
+		t.Fatalf("bundle sent after the drop was dropped with it")
+	}
+}
+
+// fakeTicker is a clock the test ticks by hand, recording the intervals it is set to
+type fakeTicker struct {
+	ticks     chan time.Time
+	intervals chan time.Duration
+}
+
+func (ticker *fakeTicker) Chan() <-chan time.Time {
+	return ticker.ticks
+}
+
+func (ticker *fakeTicker) Reset(d time.Duration) {
+	ticker.intervals <- d
+}
+
+func (ticker *fakeTicker) Stop() {}
+
+// startBundling runs a processor on a fake clock. Its bundles fail simulation
+// without evicting anything, and the call data of each is sent on the returned channel.
+func startBundling(t *testing.T, bundling BundlingConfig) (*BasicProcessor, *fakeTicker, <-chan []byte) {
+	t.Helper()
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	keyPool, err := keypool.NewKeyPool([]keypool.Signer{keypool.NewLocalSigner(privateKey)}, nil, big.NewInt(1), keypool.StrategyRoundRobin, 1)
+	if err != nil {
+		t.Fatalf("NewKeyPool: %v", err)
+	}
+
+	bundles := make(chan []byte, 16)
+	node := fakeNode(t, func(data []byte) *rpcError {
+		bundles <- data
+		return &rpcError{Code: -32000, Message: "node unavailable"}
+	})
+	pool := mempool.NewMempool(testEntryPoint, big.NewInt(1), mempool.Limits{})
+	processor := NewBasicProcessor(pool, node, bundling, keyPool, nil, common.Address{}, true, nil, nil, reputation.NewReputation(), nil)
+
+	clock := &fakeTicker{ticks: make(chan time.Time), intervals: make(chan time.Duration, 16)}
+	processor.newTicker = func(d time.Duration) ticker {
+		clock.intervals <- d
+		return clock
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := processor.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() {
+		processor.Stop(ctx)
+		cancel()
+	})
+	return processor, clock, bundles
+}
+
+func receive[T any](t *testing.T, values <-chan T, what string) T {
+	t.Helper()
+	select {
+	case value := <-values:
+		return value
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no %s", what)
+		var zero T
+		return zero
+	}
+}
+
+// settle waits until the processing loop has handled every added userOp, by
+// changing the interval and waiting for the loop to reset the ticker
+func settle(t *testing.T, processor *BasicProcessor, clock *fakeTicker) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(processor.mempool.Added()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("processing loop didn't handle the added userOps")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	bundling := processor.getBundlingConfig()
+	bundling.Interval++
+	processor.Reconfigure(bundling, nil, common.Address{}, true)
+	receive(t, clock.intervals, "ticker reset")
+}
+
+func TestSizeThresholdBundlesBeforeInterval(t *testing.T) {
+	processor, clock, bundles := startBundling(t, BundlingConfig{Mode: types.BundlingModeAuto, Interval: time.Hour, MaxBundleSize: 10, SizeThreshold: 2})
+	receive(t, clock.intervals, "ticker")
+
+	// One userOp is below the threshold
+	if err := processor.mempool.Add(testUserOp(0xaa)); err != nil {
+		t.Fatalf("failed to add userOp: %v", err)
+	}
+	settle(t, processor, clock)
+	select {
+	case <-bundles:
+		t.Fatalf("bundled below the size threshold")
+	default:
+	}
+
+	// The second one reaches it, without waiting for the interval
+	if err := processor.mempool.Add(testUserOp(0xbb)); err != nil {
+		t.Fatalf("failed to add userOp: %v", err)
+	}
+	data := receive(t, bundles, "bundle at the size threshold")
+	if !containsSender(data, 0xaa) || !containsSender(data, 0xbb) {
+		t.Fatalf("bundle at the size threshold is missing userOps")
+	}
+}
+
+func TestIntervalChangeResetsTicker(t *testing.T) {
+	processor, clock, bundles := startBundling(t, BundlingConfig{Mode: types.BundlingModeAuto, Interval: time.Hour, MaxBundleSize: 10})
+	if interval := receive(t, clock.intervals, "ticker"); interval != time.Hour {
+		t.Fatalf("ticker interval = %v, want %v", interval, time.Hour)
+	}
+
+	// Without a threshold, userOps wait for the tick
+	if err := processor.mempool.Add(testUserOp(0xaa)); err != nil {
+		t.Fatalf("failed to add userOp: %v", err)
+	}
+	clock.ticks <- time.Now()
+	if data := receive(t, bundles, "bundle on tick"); !containsSender(data, 0xaa) {
+		t.Fatalf("tick bundled other userOps")
+	}
+
+	// A new interval resets the running ticker, other settings leave it alone
+	processor.Reconfigure(BundlingConfig{Interval: time.Hour, MaxBundleSize: 5}, nil, common.Address{}, true)
+	processor.Reconfigure(BundlingConfig{Interval: time.Minute, MaxBundleSize: 5}, nil, common.Address{}, true)
+	if interval := receive(t, clock.intervals, "ticker reset"); interval != time.Minute {
+		t.Fatalf("ticker reset to %v, want %v", interval, time.Minute)
+	}
+	select {
+	case interval := <-clock.intervals:
+		t.Fatalf("ticker reset again to %v", interval)
+	default:
+	}
+
+	// The loop keeps bundling on the new ticker
+	clock.ticks <- time.Now()
+	receive(t, bundles, "bundle after the interval change")
+}
@@ -2,22 +2,61 @@ package processor
 
 import (
 	"context"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/vorpalengineering/gundler/pkg/types"
 )
 
+// Bundle outcomes
+const (
+	BundleStatusIncluded = "included"
+	BundleStatusFailed   = "failed"
+	BundleStatusDropped  = "dropped"
+)
+
+// Processor states
+const (
+	StateActive   = types.ProcessorStateActive   // accepting and bundling userOps
+	StatePaused   = types.ProcessorStatePaused   // accepting userOps without bundling them
+	StateDraining = types.ProcessorStateDraining // bundling pending userOps without accepting new ones
+)
+
 type Processor interface {
 	Start(ctx context.Context) error
-	Stop() error
+	Stop(ctx context.Context) error
+	WaitPendingBundles(ctx context.Context) int
+	SubmittedUserOp(userOpHash common.Hash) (*types.UserOperation, common.Hash, bool)
 	Pause()
-	Unpause()
+	Resume()
+	Drain()
+	State() string
 	IsPaused() bool
+	IsRunning() bool
+	SendBundleNow(ctx context.Context) (common.Hash, error)
+	SetBundlingMode(mode string) error
+	BundlingMode() string
+	Reconfigure(bundling BundlingConfig, keys []common.Address, beneficiary common.Address, simulate bool)
+}
+
+// BundlingConfig controls when a processor builds bundles. In auto mode a bundle
+// is built every Interval, or as soon as the pending userOps reach SizeThreshold
+// userOps or GasThreshold gas (whichever comes first). In manual mode bundles are
+// only built by SendBundleNow. A bundle holds at most MaxBundleSize userOps and,
+// if MaxBundleGas is set, at most MaxBundleGas gas (but always at least one userOp).
+type BundlingConfig struct {
+	Mode          string
+	Interval      time.Duration
+	MaxBundleSize uint
+	MaxBundleGas  uint64
+	SizeThreshold uint
+	GasThreshold  uint64
 }
 
 type Bundle struct {
-	UserOps    []*types.UserOperation
-	EntryPoint common.Address
+	UserOps     []*types.UserOperation
+	EntryPoint  common.Address
+	Beneficiary common.Address
 }
 
 type SimulationResult struct {
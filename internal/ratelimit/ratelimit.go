@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Idle buckets are swept at most this often
+const sweepInterval = time.Minute
+
+// Limit is a token bucket refilled at Rate tokens per second up to Burst tokens
+type Limit struct {
+	Rate  float64
+	Burst uint
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// refill adds the tokens accrued since the last update
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated).Seconds()
+	b.tokens = min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+	b.updated = now
+}
+
+// Limiter holds token buckets by key. Buckets are created full on first use and
+// dropped once they have refilled, so idle clients don't accumulate.
+type Limiter struct {
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from the bucket for key. If none is available it returns
+// false and how long until one is.
+func (limiter *Limiter) Allow(key string, limit Limit) (bool, time.Duration) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := time.Now()
+	limiter.sweep(now)
+
+	// Get or create bucket, a changed limit applies from now on
+	b, exists := limiter.buckets[key]
+	if !exists {
+		b = &bucket{tokens: float64(limit.Burst), updated: now, limit: limit}
+		limiter.buckets[key] = b
+	}
+	b.refill(now)
+	b.limit = limit
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if limit.Rate <= 0 {
+		return false, 0
+	}
+	return false, time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+}
+
+// sweep drops full buckets. Caller must hold the lock.
+func (limiter *Limiter) sweep(now time.Time) {
+	if now.Sub(limiter.lastSweep) < sweepInterval {
+		return
+	}
+	limiter.lastSweep = now
+
+	for key, b := range limiter.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(limiter.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package reputation
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+// Reputation statuses
+const (
+	StatusOK        = "ok"
+	StatusThrottled = "throttled"
+	StatusBanned    = "banned"
+)
+
+// ERC-7562 reputation parameters for bundlers
+const (
+	minInclusionRateDenominator = 10
+	throttlingSlack             = 10
+	banSlack                    = 50
+	decayInterval               = time.Hour
+)
+
+type Entry struct {
+	Address     common.Address
+	OpsSeen     uint64
+	OpsIncluded uint64
+}
+
+// Status returns the reputation status derived from the entry's counters
+func (entry *Entry) Status() string {
+	maxSeen := entry.OpsSeen / minInclusionRateDenominator
+	switch {
+	case maxSeen <= entry.OpsIncluded+throttlingSlack:
+		return StatusOK
+	case maxSeen <= entry.OpsIncluded+banSlack:
+		return StatusThrottled
+	default:
+		return StatusBanned
+	}
+}
+
+// Reputation tracks how many userOps referencing each entity (sender, factory,
+// paymaster) were seen and included for one entry point
+type Reputation struct {
+	mutex     sync.RWMutex
+	entries   map[common.Address]*Entry
+	lastDecay time.Time
+}
+
+func NewReputation() *Reputation {
+	return &Reputation{
+		entries:   make(map[common.Address]*Entry),
+		lastDecay: time.Now(),
+	}
+}
+
+// UpdateSeen increments opsSeen for each address
+func (rep *Reputation) UpdateSeen(addresses ...common.Address) {
+	rep.mutex.Lock()
+	defer rep.mutex.Unlock()
+
+	for _, address := range addresses {
+		rep.entry(address).OpsSeen++
+	}
+}
+
+// UpdateIncluded increments opsIncluded for each address
+func (rep *Reputation) UpdateIncluded(addresses ...common.Address) {
+	rep.mutex.Lock()
+	defer rep.mutex.Unlock()
+
+	for _, address := range addresses {
+		rep.entry(address).OpsIncluded++
+	}
+}
+
+// Status returns the reputation status of an address
+func (rep *Reputation) Status(address common.Address) string {
+	rep.mutex.RLock()
+	defer rep.mutex.RUnlock()
+
+	entry, exists := rep.entries[address]
+	if !exists {
+		return StatusOK
+	}
+	return entry.Status()
+}
+
+// Set overwrites the counters of the given entries
+func (rep *Reputation) Set(entries ...Entry) {
+	rep.mutex.Lock()
+	defer rep.mutex.Unlock()
+
+	for _, entry := range entries {
+		stored := rep.entry(entry.Address)
+		stored.OpsSeen = entry.OpsSeen
+		stored.OpsIncluded = entry.OpsIncluded
+	}
+}
+
+// Dump returns a copy of every entry
+func (rep *Reputation) Dump() []Entry {
+	rep.mutex.RLock()
+	defer rep.mutex.RUnlock()
+
+	entries := make([]Entry, 0, len(rep.entries))
+	for _, entry := range rep.entries {
+		entries = append(entries, *entry)
+	}
+	return entries
+}
+
+// Clear removes every entry
+func (rep *Reputation) Clear() {
+	rep.mutex.Lock()
+	defer rep.mutex.Unlock()
+
+	rep.entries = make(map[common.Address]*Entry)
+}
+
+// Decay multiplies counters by 23/24 for every hour since the last decay, so
+// entities recover from old failures. Entries that reach zero are removed.
+func (rep *Reputation) Decay() {
+	rep.mutex.Lock()
+	defer rep.mutex.Unlock()
+
+	for time.Since(rep.lastDecay) >= decayInterval {
+		for address, entry := range rep.entries {
+			entry.OpsSeen = entry.OpsSeen * 23 / 24
+			entry.OpsIncluded = entry.OpsIncluded * 23 / 24
+			if entry.OpsSeen == 0 && entry.OpsIncluded == 0 {
+				delete(rep.entries, address)
+			}
+		}
+		rep.lastDecay = rep.lastDecay.Add(decayInterval)
+	}
+}
+
+// entry returns the entry for address, creating it if needed. Caller must hold the write lock.
+func (rep *Reputation) entry(address common.Address) *Entry {
+	entry, exists := rep.entries[address]
+	if !exists {
+		entry = &Entry{Address: address}
+		rep.entries[address] = entry
+	}
+	return entry
+}
+
+// Entities returns the sender and, if set, the factory and paymaster of a userOp
+func Entities(userOp *types.UserOperation) []common.Address {
+	entities := []common.Address{userOp.Sender}
+	if userOp.Factory != (common.Address{}) {
+		entities = append(entities, userOp.Factory)
+	}
+	if userOp.Paymaster != (common.Address{}) {
+		entities = append(entities, userOp.Paymaster)
+	}
+	return entities
+}
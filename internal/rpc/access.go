@@ -0,0 +1,178 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/vorpalengineering/gundler/internal/metrics"
+	"github.com/vorpalengineering/gundler/internal/ratelimit"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+// Rate limit key matching every method without its own limit
+const anyMethod = "*"
+
+// Usage label of requests without an API key
+const anonymousClient = "anonymous"
+
+// URL path prefix of an API key sent in the URL (/key/<key>)
+const apiKeyPathPrefix = "/key/"
+
+// AccessConfig controls API key authentication and rate limits
+type AccessConfig struct {
+	RequireAPIKey     bool
+	APIKeys           []APIKey
+	RateLimits        map[string]ratelimit.Limit // per client IP without an API key, by method
+	TrustForwardedFor bool                       // take the client IP from X-Forwarded-For (behind a proxy)
+}
+
+// APIKey is a client credential with its own rate limits, by method ("*" for
+// every method without its own limit)
+type APIKey struct {
+	Name         string
+	Key          string
+	RateLimits   map[string]ratelimit.Limit // per key
+	IPRateLimits map[string]ratelimit.Limit // per client IP using the key
+}
+
+// client identifies the caller of a request
+type client struct {
+	apiKey *APIKey // nil without an API key
+	ip     string
+}
+
+type clientContextKey struct{}
+
+// authenticate identifies the client from the X-API-Key header or the URL path
+// (/key/<key>). Returns false after writing an error if the request is rejected.
+func (rpc *RPCServer) authenticate(w http.ResponseWriter, r *http.Request) (*client, bool) {
+	rpc.settingsMutex.RLock()
+	access := rpc.access
+	apiKeys := rpc.apiKeys
+	rpc.settingsMutex.RUnlock()
+
+	c := &client{ip: clientIP(r, access.TrustForwardedFor)}
+
+	// Find API key
+	key := r.Header.Get("X-API-Key")
+	if pathKey, found := strings.CutPrefix(r.URL.Path, apiKeyPathPrefix); found && key == "" {
+		key = strings.TrimSuffix(pathKey, "/")
+	}
+	if key != "" {
+		apiKey, exists := apiKeys[key]
+		if !exists {
+			rpc.sendAccessError(w, "Unauthorized: invalid API key")
+			return nil, false
+		}
+		c.apiKey = apiKey
+	} else if access.RequireAPIKey {
+		rpc.sendAccessError(w, "Unauthorized: API key required")
+		return nil, false
+	}
+
+	return c, true
+}
+
+func (rpc *RPCServer) sendAccessError(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	rpc.writeResponse(w, newErrorResponse(nil, -32001, message))
+}
+
+// clientIP returns the IP of the caller, or of the original client from
+// X-Forwarded-For if trusted
+func clientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func withClient(ctx context.Context, c *client) context.Context {
+	return context.WithValue(ctx, clientContextKey{}, c)
+}
+
+// checkRateLimit takes a token for the request from the client's buckets and
+// counts usage per API key. Requests without a client (e.g. internal calls) are
+// not limited.
+func (rpc *RPCServer) checkRateLimit(ctx context.Context, method string) *types.RPCError {
+	c, ok := ctx.Value(clientContextKey{}).(*client)
+	if !ok {
+		return nil
+	}
+
+	rpc.settingsMutex.RLock()
+	anonymousLimits := rpc.access.RateLimits
+	rpc.settingsMutex.RUnlock()
+
+	// Key-wide limit, then the per-IP limit
+	name := anonymousClient
+	ipLimits := anonymousLimits
+	if c.apiKey != nil {
+		name = c.apiKey.Name
+		ipLimits = c.apiKey.IPRateLimits
+		if rpcErr := rpc.takeToken("key:"+name, c.apiKey.RateLimits, method); rpcErr != nil {
+			metrics.APIRequests.Inc(name, "limited")
+			return rpcErr
+		}
+	}
+	if rpcErr := rpc.takeToken("ip:"+name+":"+c.ip, ipLimits, method); rpcErr != nil {
+		metrics.APIRequests.Inc(name, "limited")
+		return rpcErr
+	}
+
+	metrics.APIRequests.Inc(name, "allowed")
+	return nil
+}
+
+// takeToken takes a token from the bucket for the method's limit, if any
+func (rpc *RPCServer) takeToken(bucket string, limits map[string]ratelimit.Limit, method string) *types.RPCError {
+	limitMethod := method
+	limit, exists := limits[method]
+	if !exists {
+		limitMethod = anyMethod
+		limit, exists = limits[anyMethod]
+	}
+	if !exists {
+		return nil
+	}
+
+	allowed, retryAfter := rpc.limiter.Allow(bucket+":"+limitMethod, limit)
+	if allowed {
+		return nil
+	}
+
+	// A limit without a rate never refills, so there is no time to retry after
+	if limit.Rate <= 0 {
+		return &types.RPCError{
+			Code:    -32005,
+			Message: fmt.Sprintf("Rate limit exceeded for %s", method),
+		}
+	}
+	retrySeconds := math.Ceil(retryAfter.Seconds())
+	return &types.RPCError{
+		Code:    -32005,
+		Message: fmt.Sprintf("Rate limit exceeded for %s, retry in %vs", method, retrySeconds),
+		Data:    map[string]any{"retryAfter": retrySeconds},
+	}
+}
+
+// apiKeyIndex maps each key to its API key
+func apiKeyIndex(apiKeys []APIKey) map[string]*APIKey {
+	index := make(map[string]*APIKey, len(apiKeys))
+	for i := range apiKeys {
+		index[apiKeys[i].Key] = &apiKeys[i]
+	}
+	return index
+}
@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vorpalengineering/gundler/internal/ratelimit"
+)
+
+func TestAuthenticate(t *testing.T) {
+	apiKeys := []APIKey{{Name: "internal", Key: "secret"}}
+	server := &RPCServer{
+		access:  AccessConfig{RequireAPIKey: true, APIKeys: apiKeys},
+		apiKeys: apiKeyIndex(apiKeys),
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		ok     bool
+	}{
+		{"header", "/", "secret", true},
+		{"path", "/key/secret", "", true},
+		{"path with trailing slash", "/key/secret/", "", true},
+		{"invalid path key", "/key/wrong", "", false},
+		{"path without prefix", "/secret", "", false},
+		{"no key", "/", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, test.path, nil)
+			if test.header != "" {
+				r.Header.Set("X-API-Key", test.header)
+			}
+			recorder := httptest.NewRecorder()
+			c, ok := server.authenticate(recorder, r)
+			if ok != test.ok {
+				t.Fatalf("authenticated = %v, want %v", ok, test.ok)
+			}
+			if ok && (c.apiKey == nil || c.apiKey.Name != "internal") {
+				t.Fatalf("client = %+v, want API key internal", c)
+			}
+			if !ok && recorder.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want 401", recorder.Code)
+			}
+		})
+	}
+}
+
+func TestRateLimitRetryAfter(t *testing.T) {
+	server := &RPCServer{
+		limiter: ratelimit.NewLimiter(),
+		access: AccessConfig{RateLimits: map[string]ratelimit.Limit{
+			"eth_chainId": {Rate: 1, Burst: 1},
+			anyMethod:     {Rate: 0, Burst: 1},
+		}},
+	}
+	ctx := withClient(context.Background(), &client{ip: "127.0.0.1"})
+
+	for _, test := range []struct {
+		method     string
+		retryAfter bool
+	}{
+		{"eth_chainId", true},
+		{"eth_supportedEntryPoints", false},
+	} {
+		if rpcErr := server.checkRateLimit(ctx, test.method); rpcErr != nil {
+			t.Fatalf("first %s request limited: %v", test.method, rpcErr)
+		}
+		rpcErr := server.checkRateLimit(ctx, test.method)
+		if rpcErr == nil || rpcErr.Code != -32005 {
+			t.Fatalf("second %s request error = %v, want -32005", test.method, rpcErr)
+		}
+		if hasRetryAfter := rpcErr.Data != nil; hasRetryAfter != test.retryAfter {
+			t.Fatalf("%s error data = %v, want retryAfter %v", test.method, rpcErr.Data, test.retryAfter)
+		}
+	}
+}
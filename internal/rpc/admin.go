@@ -0,0 +1,325 @@
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/vorpalengineering/gundler/internal/processor"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+// Prefix of admin listen addresses that are Unix socket paths
+const unixPrefix = "unix:"
+
+// newAdminServer creates the admin API server, served on its own listener in every mode
+func (rpc *RPCServer) newAdminServer(serverConfig ServerConfig) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", rpc.handleAdminRequest)
+
+	return &http.Server{
+		Addr:              serverConfig.AdminListen,
+		Handler:           mux,
+		ReadHeaderTimeout: serverConfig.ReadHeaderTimeout,
+		ReadTimeout:       serverConfig.ReadTimeout,
+		WriteTimeout:      serverConfig.WriteTimeout,
+		IdleTimeout:       serverConfig.IdleTimeout,
+	}
+}
+
+// listenAdmin listens on the admin address, a host:port or unix:<path>. A
+// Unix socket is only accessible to the user running gundler.
+func listenAdmin(address string) (net.Listener, error) {
+	path, isUnix := strings.CutPrefix(address, unixPrefix)
+	if !isUnix {
+		return net.Listen("tcp", address)
+	}
+
+	// Remove a socket left by a previous run
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+func (rpc *RPCServer) handleAdminRequest(w http.ResponseWriter, r *http.Request) {
+	// Check bearer token
+	token, isBearer := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !isBearer || token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(rpc.adminToken)) != 1 {
+		rpc.sendAccessError(w, "Unauthorized: invalid admin token")
+		return
+	}
+
+	rpc.serveJSONRPC(w, r, rpc.handleAdminMethod)
+}
+
+// handleAdminMethod routes a single admin request to its handler
+func (rpc *RPCServer) handleAdminMethod(ctx context.Context, req *types.RPCRequest) *types.RPCResponse {
+	// Route to appropriate handler
+	var result any
+	var err *types.RPCError
+
+	switch req.Method {
+	case "admin_pause":
+		result, err = rpc.handleAdminSetState(req.Params, processor.StatePaused)
+	case "admin_resume":
+		result, err = rpc.handleAdminSetState(req.Params, processor.StateActive)
+	case "admin_drain":
+		result, err = rpc.handleAdminSetState(req.Params, processor.StateDraining)
+	case "admin_processorStates":
+		result, err = rpc.handleAdminProcessorStates(req.Params)
+	case "admin_dumpMempool":
+		result, err = rpc.handleDebugBundlerDumpMempool(req.Params)
+	case "admin_dropUserOps":
+		result, err = rpc.handleAdminDropUserOps(req.Params)
+	case "admin_setReputation":
+		result, err = rpc.handleDebugBundlerSetReputation(req.Params)
+	case "admin_dumpReputation":
+		result, err = rpc.handleDebugBundlerDumpReputation(req.Params)
+	case "admin_keys":
+		result, err = rpc.handleAdminKeys()
+	case "admin_enableKey":
+		result, err = rpc.handleAdminSetKeyEnabled(req.Params, true)
+	case "admin_disableKey":
+		result, err = rpc.handleAdminSetKeyEnabled(req.Params, false)
+	case "admin_sendBundleNow":
+		result, err = rpc.handleAdminSendBundleNow(ctx, req.Params)
+	default:
+		err = &types.RPCError{
+			Code:    -32601,
+			Message: "Method not found",
+		}
+	}
+
+	// Log every operator action
+	if err != nil {
+		log.Printf("Admin %s failed: %s", req.Method, err.Message)
+		return newErrorResponse(req.ID, err.Code, err.Message)
+	}
+	log.Printf("Admin %s %s", req.Method, req.Params)
+
+	return newResultResponse(req.ID, result)
+}
+
+func (rpc *RPCServer) handleAdminSetState(params json.RawMessage, state string) (any, *types.RPCError) {
+	// Parse params: [entryPoints] (optional, all entry points if omitted)
+	entryPoints, rpcErr := rpc.parseEntryPointsParams(params)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	if rpcErr := rpc.checkStateChange(); rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	// Set each processor's state, return the new states
+	states := make(map[string]string, len(entryPoints))
+	for _, entryPoint := range entryPoints {
+		proc := rpc.processors[entryPoint]
+		switch state {
+		case processor.StatePaused:
+			proc.Pause()
+		case processor.StateDraining:
+			proc.Drain()
+		default:
+			proc.Resume()
+		}
+		states[entryPoint] = proc.State()
+	}
+
+	return states, nil
+}
+
+func (rpc *RPCServer) handleAdminProcessorStates(params json.RawMessage) (any, *types.RPCError) {
+	// Parse params: [entryPoints] (optional, all entry points if omitted)
+	entryPoints, rpcErr := rpc.parseEntryPointsParams(params)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	states := make(map[string]types.ProcessorState, len(entryPoints))
+	for _, entryPoint := range entryPoints {
+		states[entryPoint] = types.ProcessorState{
+			State:       rpc.processors[entryPoint].State(),
+			Running:     rpc.processors[entryPoint].IsRunning(),
+			MempoolSize: rpc.mempools[entryPoint].Size(),
+		}
+	}
+
+	return states, nil
+}
+
+func (rpc *RPCServer) handleAdminDropUserOps(params json.RawMessage) (any, *types.RPCError) {
+	// Parse params: [entryPoint, userOpHashes] (all userOps if hashes are omitted)
+	var rawParams []json.RawMessage
+	if err := json.Unmarshal(params, &rawParams); err != nil || len(rawParams) < 1 || len(rawParams) > 2 {
+		return nil, &types.RPCError{
+			Code:    -32602,
+			Message: "Expected parameters: [entryPoint, userOpHashes]",
+		}
+	}
+	normalizedAddress, rpcErr := rpc.parseEntryPointParam(rawParams[0])
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	mempool := rpc.mempools[normalizedAddress]
+
+	// Drop every userOp
+	if len(rawParams) == 1 {
+		dropped := mempool.Size()
+		mempool.Clear()
+		return map[string]int{"dropped": dropped}, nil
+	}
+
+	// Drop the given userOps
+	var userOpHashes []common.Hash
+	if err := json.Unmarshal(rawParams[1], &userOpHashes); err != nil {
+		return nil, &types.RPCError{
+			Code:    -32602,
+			Message: fmt.Sprintf("Error unmarshalling userOpHashes: %v", err),
+		}
+	}
+
+	return map[string]int{"dropped": mempool.Drop(userOpHashes...)}, nil
+}
+
+func (rpc *RPCServer) handleAdminKeys() (any, *types.RPCError) {
+	statuses := rpc.keyPool.Keys()
+	keys := make([]types.BundlerKey, 0, len(statuses))
+	for _, status := range statuses {
+		keys = append(keys, types.BundlerKey{
+			Address:    status.Address,
+			Pending:    status.Pending,
+			Balance:    (*hexutil.Big)(status.Balance),
+			LowBalance: status.LowBalance,
+			Disabled:   status.Disabled,
+		})
+	}
+
+	return keys, nil
+}
+
+func (rpc *RPCServer) handleAdminSetKeyEnabled(params json.RawMessage, enabled bool) (any, *types.RPCError) {
+	// Parse params: [address]
+	var rawParams []string
+	if err := json.Unmarshal(params, &rawParams); err != nil || len(rawParams) != 1 || !common.IsHexAddress(rawParams[0]) {
+		return nil, &types.RPCError{
+			Code:    -32602,
+			Message: "Expected 1 parameter: [address]",
+		}
+	}
+
+	if err := rpc.keyPool.SetKeyEnabled(common.HexToAddress(rawParams[0]), enabled); err != nil {
+		return nil, &types.RPCError{
+			Code:    -32602,
+			Message: err.Error(),
+		}
+	}
+
+	return "ok", nil
+}
+
+func (rpc *RPCServer) handleAdminSendBundleNow(ctx context.Context, params json.RawMessage) (any, *types.RPCError) {
+	// Parse params: [entryPoints] (optional, all entry points if omitted)
+	entryPoints, rpcErr := rpc.parseEntryPointsParams(params)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	// Bundle each mempool, return the bundle transaction hashes (null if nothing was bundled)
+	txHashes := make(map[string]*common.Hash, len(entryPoints))
+	for _, entryPoint := range entryPoints {
+		txHash, err := rpc.processors[entryPoint].SendBundleNow(ctx)
+		if err != nil {
+			return nil, &types.RPCError{
+				Code:    -32603,
+				Message: fmt.Sprintf("Failed to send bundle for entry point %s: %v", entryPoint, err),
+			}
+		}
+		txHashes[entryPoint] = nil
+		if txHash != (common.Hash{}) {
+			txHashes[entryPoint] = &txHash
+		}
+	}
+
+	return txHashes, nil
+}
+
+// parseEntryPointsParams parses optional params [entryPoints] and returns the
+// normalized addresses, or every supported entry point if omitted
+func (rpc *RPCServer) parseEntryPointsParams(params json.RawMessage) ([]string, *types.RPCError) {
+	var rawParams []json.RawMessage
+	if len(params) > 0 && string(params) != "null" {
+		if err := json.Unmarshal(params, &rawParams); err != nil || len(rawParams) > 1 {
+			return nil, &types.RPCError{
+				Code:    -32602,
+				Message: "Expected parameters: [entryPoints]",
+			}
+		}
+	}
+	if len(rawParams) == 0 {
+		return rpc.supportedEntryPoints, nil
+	}
+
+	var rawEntryPoints []json.RawMessage
+	if err := json.Unmarshal(rawParams[0], &rawEntryPoints); err != nil {
+		return nil, &types.RPCError{
+			Code:    -32602,
+			Message: "Error unmarshalling entryPoints",
+		}
+	}
+	entryPoints := make([]string, 0, len(rawEntryPoints))
+	for _, rawEntryPoint := range rawEntryPoints {
+		normalizedAddress, rpcErr := rpc.parseEntryPointParam(rawEntryPoint)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		entryPoints = append(entryPoints, normalizedAddress)
+	}
+
+	return entryPoints, nil
+}
+
+// startAdmin serves the admin API if an admin listen address is configured
+func (rpc *RPCServer) startAdmin() error {
+	if rpc.adminServer == nil {
+		return nil
+	}
+
+	listener, err := listenAdmin(rpc.adminServer.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on admin address %s: %w", rpc.adminServer.Addr, err)
+	}
+	fmt.Printf("Starting Admin API on: %s\n", rpc.adminServer.Addr)
+
+	go func() {
+		if err := rpc.adminServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Admin API error: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// shutdownAdmin stops the admin API, waiting up to the context deadline for active requests
+func (rpc *RPCServer) shutdownAdmin(ctx context.Context) error {
+	if rpc.adminServer == nil {
+		return nil
+	}
+	return rpc.adminServer.Shutdown(ctx)
+}
@@ -0,0 +1,200 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vorpalengineering/gundler/internal/mempool"
+	"github.com/vorpalengineering/gundler/internal/processor"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+const testAdminToken = "admin-secret"
+
+const processorStatesRequest = `{"jsonrpc":"2.0","id":1,"method":"admin_processorStates","params":[]}`
+
+func newAdminTestServer() *RPCServer {
+	server := newDebugTestServer(mempool.Limits{})
+	server.adminToken = testAdminToken
+	return server
+}
+
+func TestAdminAuth(t *testing.T) {
+	server := newAdminTestServer()
+	tests := []struct {
+		name          string
+		authorization string
+		ok            bool
+	}{
+		{"no token", "", false},
+		{"empty bearer", "Bearer ", false},
+		{"wrong token", "Bearer wrong", false},
+		{"token without bearer", testAdminToken, false},
+		{"token", "Bearer " + testAdminToken, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(processorStatesRequest))
+			if test.authorization != "" {
+				r.Header.Set("Authorization", test.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			server.handleAdminRequest(recorder, r)
+
+			var resp types.RPCResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid response %q: %v", recorder.Body.String(), err)
+			}
+			if !test.ok {
+				if recorder.Code != http.StatusUnauthorized || resp.Error == nil {
+					t.Fatalf("status = %d, error = %v, want 401", recorder.Code, resp.Error)
+				}
+				return
+			}
+			if recorder.Code != http.StatusOK || resp.Error != nil {
+				t.Fatalf("status = %d, error = %v, want processor states", recorder.Code, resp.Error)
+			}
+		})
+	}
+}
+
+// Admin methods are only served on the admin listener, even in DEBUG mode
+func TestAdminMethodsNotOnPublicAPI(t *testing.T) {
+	server := newAdminTestServer()
+	server.mode = "DEBUG"
+	for _, method := range []string{"admin_processorStates", "admin_pause", "admin_keys"} {
+		resp := server.handleRequest(context.Background(), &types.RPCRequest{JSONRPC: "2.0", ID: 1, Method: method})
+		if resp.Error == nil || resp.Error.Code != -32601 {
+			t.Fatalf("public %s = %+v, want method not found", method, resp)
+		}
+	}
+}
+
+func TestAdminListenTCP(t *testing.T) {
+	listener, err := listenAdmin("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listenAdmin: %v", err)
+	}
+	defer listener.Close()
+	if addr, ok := listener.Addr().(*net.TCPAddr); !ok || !addr.IP.IsLoopback() {
+		t.Fatalf("admin listener on %s, want loopback", listener.Addr())
+	}
+}
+
+func TestAdminUnixSocket(t *testing.T) {
+	// Unix socket paths are limited to about 100 bytes, shorter than some test temp dirs
+	dir, err := os.MkdirTemp("", "gundler")
+	if err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "admin.sock")
+
+	// A socket left by a previous run is replaced
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatalf("write stale socket: %v", err)
+	}
+
+	server := newAdminTestServer()
+	server.adminServer = server.newAdminServer(ServerConfig{AdminListen: unixPrefix + path})
+	if err := server.startAdmin(); err != nil {
+		t.Fatalf("startAdmin: %v", err)
+	}
+	defer server.shutdownAdmin(context.Background())
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat socket: %v", err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0o600 {
+		t.Fatalf("socket mode = %v, want a socket only the owner can access", info.Mode())
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	for token, want := range map[string]int{testAdminToken: http.StatusOK, "wrong": http.StatusUnauthorized} {
+		req, err := http.NewRequest(http.MethodPost, "http://admin/", strings.NewReader(processorStatesRequest))
+		if err != nil {
+			t.Fatalf("new request: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("admin request over the socket: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("status with token %q = %d, want %d", token, resp.StatusCode, want)
+		}
+	}
+}
+
+func entryPointsParams(t *testing.T, entryPoints ...string) json.RawMessage {
+	t.Helper()
+	params, err := json.Marshal([]any{entryPoints})
+	if err != nil {
+		t.Fatalf("marshal params: %v", err)
+	}
+	return params
+}
+
+func TestProcessorStates(t *testing.T) {
+	v07, v08 := types.EntryPointV07Address.Hex(), types.EntryPointV08Address.Hex()
+	server := newAdminTestServer()
+	server.mempools[v08] = mempool.NewMempool(types.EntryPointV08Address, big.NewInt(1), mempool.Limits{})
+	server.processors[v08] = &stateProcessor{state: processor.StateActive}
+	server.supportedEntryPoints = append(server.supportedEntryPoints, v08)
+
+	expectStates := func(step string, want map[string]string) {
+		t.Helper()
+		result, rpcErr := server.handleAdminProcessorStates(nil)
+		if rpcErr != nil {
+			t.Fatalf("%s: processorStates: %v", step, rpcErr)
+		}
+		states := result.(map[string]types.ProcessorState)
+		for entryPoint, state := range want {
+			if states[entryPoint].State != state {
+				t.Fatalf("%s: %s is %s, want %s", step, entryPoint, states[entryPoint].State, state)
+			}
+		}
+	}
+
+	// Only the listed entry points change
+	if _, rpcErr := server.handleAdminSetState(entryPointsParams(t, v07), processor.StatePaused); rpcErr != nil {
+		t.Fatalf("admin_pause: %v", rpcErr)
+	}
+	expectStates("pause one", map[string]string{v07: processor.StatePaused, v08: processor.StateActive})
+
+	// The debug toggle pauses every processor unless all are paused, whatever the map order
+	server.handleDebugPause(nil)
+	expectStates("toggle mixed", map[string]string{v07: processor.StatePaused, v08: processor.StatePaused})
+	server.handleDebugPause(nil)
+	expectStates("toggle paused", map[string]string{v07: processor.StateActive, v08: processor.StateActive})
+
+	// Without a list every entry point changes
+	if _, rpcErr := server.handleAdminSetState(nil, processor.StateDraining); rpcErr != nil {
+		t.Fatalf("admin_drain: %v", rpcErr)
+	}
+	expectStates("drain all", map[string]string{v07: processor.StateDraining, v08: processor.StateDraining})
+	if _, rpcErr := server.handleAdminSetState(entryPointsParams(t, v08), processor.StateActive); rpcErr != nil {
+		t.Fatalf("admin_resume: %v", rpcErr)
+	}
+	expectStates("resume one", map[string]string{v07: processor.StateDraining, v08: processor.StateActive})
+
+	// An unknown entry point changes nothing
+	if _, rpcErr := server.handleAdminSetState(entryPointsParams(t, v08, types.EntryPointV06Address.Hex()), processor.StatePaused); rpcErr == nil {
+		t.Fatalf("admin_pause accepted an unsupported entry point")
+	}
+	expectStates("unknown entry point", map[string]string{v07: processor.StateDraining, v08: processor.StateActive})
+}
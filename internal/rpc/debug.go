@@ -1,9 +1,17 @@
 package rpc
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"math/big"
+	"slices"
 
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/vorpalengineering/gundler/internal/reputation"
 	"github.com/vorpalengineering/gundler/pkg/types"
 )
 
@@ -23,47 +31,49 @@ func (rpc *RPCServer) handleDebugMempools() (any, *types.RPCError) {
 	}
 
 	// Build response with all mempools
-	type MempoolInfo struct {
-		Label   string                 `json:"label"`
-		Address string                 `json:"address"`
-		Size    int                    `json:"size"`
-		UserOps []*types.UserOperation `json:"userops"`
-	}
-
-	mempools := make([]MempoolInfo, 0, len(rpc.mempools))
+	mempools := make([]types.MempoolInfo, 0, len(rpc.mempools))
 	for address, mempool := range rpc.mempools {
-		mempools = append(mempools, MempoolInfo{
-			Label:   getVersionLabel(address),
-			Address: address,
-			Size:    mempool.Size(),
-			UserOps: mempool.GetAll(),
+		mempools = append(mempools, types.MempoolInfo{
+			Label:     getVersionLabel(address),
+			Address:   address,
+			Size:      mempool.Size(),
+			Evictions: mempool.Evictions(),
+			UserOps:   mempool.GetAll(),
 		})
 	}
 
 	return mempools, nil
 }
 
-func (rpc *RPCServer) handleDebugPause() (any, *types.RPCError) {
-	// Check current pause state (check first processor)
-	var isPaused bool
-	for _, proc := range rpc.processors {
-		isPaused = proc.IsPaused()
-		break
+func (rpc *RPCServer) handleDebugPause(params json.RawMessage) (any, *types.RPCError) {
+	// Parse params: [entryPoints] (optional, all entry points if omitted)
+	entryPoints, rpcErr := rpc.parseEntryPointsParams(params)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	if rpcErr := rpc.checkStateChange(); rpcErr != nil {
+		return nil, rpcErr
 	}
 
-	// Toggle pause state for all processors
-	if isPaused {
-		// Unpause all processors
-		for _, proc := range rpc.processors {
-			proc.Unpause()
+	// Toggle: resume if every processor is paused, otherwise pause them all
+	isPaused := true
+	for _, entryPoint := range entryPoints {
+		if !rpc.processors[entryPoint].IsPaused() {
+			isPaused = false
+			break
 		}
-		log.Println("All processors unpaused")
-	} else {
-		// Pause all processors
-		for _, proc := range rpc.processors {
-			proc.Pause()
+	}
+	for _, entryPoint := range entryPoints {
+		if isPaused {
+			rpc.processors[entryPoint].Resume()
+		} else {
+			rpc.processors[entryPoint].Pause()
 		}
-		log.Println("All processors paused")
+	}
+	if isPaused {
+		log.Printf("Processors resumed: %v", entryPoints)
+	} else {
+		log.Printf("Processors paused: %v", entryPoints)
 	}
 
 	// Return response with new state
@@ -93,3 +103,308 @@ func (rpc *RPCServer) handleDebugClear() (any, *types.RPCError) {
 
 	return response, nil
 }
+
+// Minimum stake requirements for a staked entity (ERC-7562 defaults)
+var (
+	minStakeValue   = new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	minUnstakeDelay = uint32(86400)
+)
+
+func (rpc *RPCServer) handleDebugBundlerClearState() (any, *types.RPCError) {
+	// Clear all mempools and reputations
+	for _, mempool := range rpc.mempools {
+		mempool.Clear()
+	}
+	for _, entityReputation := range rpc.reputations {
+		entityReputation.Clear()
+	}
+
+	log.Println("Bundler state cleared")
+
+	return "ok", nil
+}
+
+func (rpc *RPCServer) handleDebugBundlerDumpMempool(params json.RawMessage) (any, *types.RPCError) {
+	// Parse params: [entryPoint]
+	var rawParams []json.RawMessage
+	if err := json.Unmarshal(params, &rawParams); err != nil || len(rawParams) != 1 {
+		return nil, &types.RPCError{
+			Code:    -32602,
+			Message: "Expected 1 parameter: [entryPoint]",
+		}
+	}
+	normalizedAddress, rpcErr := rpc.parseEntryPointParam(rawParams[0])
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	return rpc.mempools[normalizedAddress].GetAll(), nil
+}
+
+func (rpc *RPCServer) handleDebugBundlerSendBundleNow(ctx context.Context) (any, *types.RPCError) {
+	// Bundle every mempool in address order, return the first bundle transaction
+	// hash (null if nothing was bundled)
+	entryPoints := slices.Clone(rpc.supportedEntryPoints)
+	slices.SortFunc(entryPoints, func(a string, b string) int {
+		return common.HexToAddress(a).Cmp(common.HexToAddress(b))
+	})
+	var result *string
+	for _, address := range entryPoints {
+		txHash, err := rpc.processors[address].SendBundleNow(ctx)
+		if err != nil {
+			return nil, &types.RPCError{
+				Code:    -32603,
+				Message: fmt.Sprintf("Failed to send bundle for entry point %s: %v", address, err),
+			}
+		}
+		if result == nil && txHash != (common.Hash{}) {
+			txHashHex := txHash.Hex()
+			result = &txHashHex
+		}
+	}
+
+	return result, nil
+}
+
+func (rpc *RPCServer) handleDebugBundlerSetBundlingMode(params json.RawMessage) (any, *types.RPCError) {
+	// Parse params: [mode]
+	var rawParams []string
+	if err := json.Unmarshal(params, &rawParams); err != nil || len(rawParams) != 1 {
+		return nil, &types.RPCError{
+			Code:    -32602,
+			Message: "Expected 1 parameter: [mode]",
+		}
+	}
+
+	// Manual mode stops automatic bundling, bundles are sent with debug_bundler_sendBundleNow
+	for _, proc := range rpc.processors {
+		if err := proc.SetBundlingMode(rawParams[0]); err != nil {
+			return nil, &types.RPCError{
+				Code:    -32602,
+				Message: err.Error(),
+			}
+		}
+	}
+
+	log.Printf("Bundling mode set to %s", rawParams[0])
+
+	return "ok", nil
+}
+
+func (rpc *RPCServer) handleDebugBundlerSetReputation(params json.RawMessage) (any, *types.RPCError) {
+	// Parse params: [reputations, entryPoint]
+	var rawParams []json.RawMessage
+	if err := json.Unmarshal(params, &rawParams); err != nil || len(rawParams) != 2 {
+		return nil, &types.RPCError{
+			Code:    -32602,
+			Message: "Expected 2 parameters: [reputations, entryPoint]",
+		}
+	}
+	var reputationEntries []types.ReputationEntry
+	if err := json.Unmarshal(rawParams[0], &reputationEntries); err != nil {
+		return nil, &types.RPCError{
+			Code:    -32602,
+			Message: fmt.Sprintf("Error unmarshalling reputations: %v", err),
+		}
+	}
+	normalizedAddress, rpcErr := rpc.parseEntryPointParam(rawParams[1])
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	// Overwrite reputation counters
+	entries := make([]reputation.Entry, 0, len(reputationEntries))
+	for _, entry := range reputationEntries {
+		entries = append(entries, reputation.Entry{
+			Address:     entry.Address,
+			OpsSeen:     uint64(entry.OpsSeen),
+			OpsIncluded: uint64(entry.OpsIncluded),
+		})
+	}
+	rpc.reputations[normalizedAddress].Set(entries...)
+
+	return "ok", nil
+}
+
+func (rpc *RPCServer) handleDebugBundlerDumpReputation(params json.RawMessage) (any, *types.RPCError) {
+	// Parse params: [entryPoint]
+	var rawParams []json.RawMessage
+	if err := json.Unmarshal(params, &rawParams); err != nil || len(rawParams) != 1 {
+		return nil, &types.RPCError{
+			Code:    -32602,
+			Message: "Expected 1 parameter: [entryPoint]",
+		}
+	}
+	normalizedAddress, rpcErr := rpc.parseEntryPointParam(rawParams[0])
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	entries := rpc.reputations[normalizedAddress].Dump()
+	reputationEntries := make([]types.ReputationEntry, 0, len(entries))
+	for _, entry := range entries {
+		reputationEntries = append(reputationEntries, types.ReputationEntry{
+			Address:     entry.Address,
+			OpsSeen:     hexutil.Uint64(entry.OpsSeen),
+			OpsIncluded: hexutil.Uint64(entry.OpsIncluded),
+			Status:      entry.Status(),
+		})
+	}
+
+	return reputationEntries, nil
+}
+
+func (rpc *RPCServer) handleDebugBundlerAddUserOps(params json.RawMessage) (any, *types.RPCError) {
+	// Parse params: [userOps, entryPoint]
+	var rawParams []json.RawMessage
+	if err := json.Unmarshal(params, &rawParams); err != nil || len(rawParams) != 2 {
+		return nil, &types.RPCError{
+			Code:    -32602,
+			Message: "Expected 2 parameters: [userOps, entryPoint]",
+		}
+	}
+	var userOps []*types.UserOperation
+	if err := json.Unmarshal(rawParams[0], &userOps); err != nil {
+		return nil, &types.RPCError{
+			Code:    -32602,
+			Message: fmt.Sprintf("Error unmarshalling userOps: %v", err),
+		}
+	}
+	normalizedAddress, rpcErr := rpc.parseEntryPointParam(rawParams[1])
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	// Reject userOps while the EntryPoint is draining
+	if rpcErr := rpc.checkAccepting(normalizedAddress); rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	// Check every userOp first so an invalid or duplicate one adds nothing
+	mempool := rpc.mempools[normalizedAddress]
+	seen := make(map[common.Hash]bool, len(userOps))
+	for i, userOp := range userOps {
+		if userOp == nil {
+			return nil, &types.RPCError{
+				Code:    -32602,
+				Message: fmt.Sprintf("Invalid userOp %d: null", i),
+			}
+		}
+		if err := mempool.Validate(userOp); err != nil {
+			return nil, &types.RPCError{
+				Code:    -32602,
+				Message: fmt.Sprintf("Invalid userOp %d: %v", i, err),
+			}
+		}
+		userOpHash := userOp.Hash(mempool.EntryPoint, mempool.ChainID)
+		if _, exists := mempool.GetByHash(userOpHash); exists || seen[userOpHash] {
+			return nil, &types.RPCError{
+				Code:    -32602,
+				Message: fmt.Sprintf("Invalid userOp %d: duplicate userOp: %s", i, userOpHash.Hex()),
+			}
+		}
+		seen[userOpHash] = true
+	}
+
+	// Add userOps directly to the mempool. Mempool limits are only known when
+	// adding, so a userOp over a limit fails after the ones before it were added.
+	for i, userOp := range userOps {
+		if err := mempool.Add(userOp); err != nil {
+			return nil, &types.RPCError{
+				Code:    -32602,
+				Message: fmt.Sprintf("Failed adding userOp %d to mempool (%d of %d userOps added): %v", i, i, len(userOps), err),
+			}
+		}
+		rpc.reputations[normalizedAddress].UpdateSeen(reputation.Entities(userOp)...)
+	}
+
+	return "ok", nil
+}
+
+func (rpc *RPCServer) handleDebugBundlerGetStakeStatus(ctx context.Context, params json.RawMessage) (any, *types.RPCError) {
+	// Parse params: [address, entryPoint]
+	var rawParams []json.RawMessage
+	if err := json.Unmarshal(params, &rawParams); err != nil || len(rawParams) != 2 {
+		return nil, &types.RPCError{
+			Code:    -32602,
+			Message: "Expected 2 parameters: [address, entryPoint]",
+		}
+	}
+	var addressStr string
+	if err := json.Unmarshal(rawParams[0], &addressStr); err != nil || !common.IsHexAddress(addressStr) {
+		return nil, &types.RPCError{
+			Code:    -32602,
+			Message: "Invalid address",
+		}
+	}
+	normalizedAddress, rpcErr := rpc.parseEntryPointParam(rawParams[1])
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	// Call EntryPoint.getDepositInfo(address)
+	entryPoint, err := types.GetEntryPoint(common.HexToAddress(normalizedAddress))
+	if err != nil {
+		return nil, &types.RPCError{
+			Code:    -32602,
+			Message: "Invalid EntryPoint Address",
+		}
+	}
+	address := common.HexToAddress(addressStr)
+	callData, err := entryPoint.PackGetDepositInfo(address)
+	if err != nil {
+		return nil, &types.RPCError{
+			Code:    -32603,
+			Message: fmt.Sprintf("Failed to pack getDepositInfo: %v", err),
+		}
+	}
+	output, err := rpc.ethClient.CallContract(ctx, ethereum.CallMsg{
+		To:   &entryPoint.Address,
+		Data: callData,
+	}, nil)
+	if err != nil {
+		return nil, &types.RPCError{
+			Code:    -32603,
+			Message: fmt.Sprintf("Failed to call getDepositInfo: %v", err),
+		}
+	}
+	depositInfo, err := entryPoint.UnpackGetDepositInfo(output)
+	if err != nil {
+		return nil, &types.RPCError{
+			Code:    -32603,
+			Message: fmt.Sprintf("Failed to unpack getDepositInfo: %v", err),
+		}
+	}
+
+	return &types.StakeStatus{
+		StakeInfo: types.StakeInfo{
+			Addr:            address,
+			Stake:           (*hexutil.Big)(depositInfo.Stake),
+			UnstakeDelaySec: (*hexutil.Big)(new(big.Int).SetUint64(uint64(depositInfo.UnstakeDelaySec))),
+		},
+		IsStaked: depositInfo.Staked &&
+			depositInfo.Stake.Cmp(minStakeValue) >= 0 &&
+			depositInfo.UnstakeDelaySec >= minUnstakeDelay,
+	}, nil
+}
+
+// parseEntryPointParam parses a supported entry point address and returns its normalized form
+func (rpc *RPCServer) parseEntryPointParam(raw json.RawMessage) (string, *types.RPCError) {
+	var entryPointStr string
+	if err := json.Unmarshal(raw, &entryPointStr); err != nil || !common.IsHexAddress(entryPointStr) {
+		return "", &types.RPCError{
+			Code:    -32602,
+			Message: "Error unmarshalling entryPoint",
+		}
+	}
+
+	normalizedAddress := common.HexToAddress(entryPointStr).Hex()
+	if _, exists := rpc.mempools[normalizedAddress]; !exists {
+		return "", &types.RPCError{
+			Code:    -32602,
+			Message: fmt.Sprintf("No mempool found for entry point: %s", normalizedAddress),
+		}
+	}
+
+	return normalizedAddress, nil
+}
@@ -0,0 +1,119 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vorpalengineering/gundler/internal/mempool"
+	"github.com/vorpalengineering/gundler/internal/processor"
+	"github.com/vorpalengineering/gundler/internal/reputation"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+// bundleProcessor is a processor whose SendBundleNow returns txHash and records the call order
+type bundleProcessor struct {
+	processor.Processor
+	txHash common.Hash
+	calls  *[]common.Hash
+}
+
+func (proc *bundleProcessor) SendBundleNow(ctx context.Context) (common.Hash, error) {
+	*proc.calls = append(*proc.calls, proc.txHash)
+	return proc.txHash, nil
+}
+
+func testUserOp(sender byte, nonce int64) *types.UserOperation {
+	return &types.UserOperation{
+		Sender:               common.Address{sender},
+		Nonce:                big.NewInt(nonce),
+		CallData:             []byte{0x01},
+		CallGasLimit:         big.NewInt(100000),
+		VerificationGasLimit: big.NewInt(100000),
+		PreVerificationGas:   big.NewInt(50000),
+		MaxFeePerGas:         big.NewInt(2000000000),
+		MaxPriorityFeePerGas: big.NewInt(1000000000),
+		Signature:            []byte{0x02},
+	}
+}
+
+// newDebugTestServer serves the v0.7 EntryPoint with an empty mempool and an active processor
+func newDebugTestServer(limits mempool.Limits) *RPCServer {
+	entryPoint := types.EntryPointV07Address.Hex()
+	return &RPCServer{
+		mempools:             map[string]*mempool.Mempool{entryPoint: mempool.NewMempool(types.EntryPointV07Address, big.NewInt(1), limits)},
+		processors:           map[string]processor.Processor{entryPoint: &stateProcessor{state: processor.StateActive}},
+		reputations:          map[string]*reputation.Reputation{entryPoint: reputation.NewReputation()},
+		supportedEntryPoints: []string{entryPoint},
+	}
+}
+
+func addUserOpsParams(t *testing.T, userOps ...*types.UserOperation) json.RawMessage {
+	t.Helper()
+	params, err := json.Marshal([]any{userOps, types.EntryPointV07Address.Hex()})
+	if err != nil {
+		t.Fatalf("marshal params: %v", err)
+	}
+	return params
+}
+
+func TestDebugBundlerAddUserOps(t *testing.T) {
+	invalid := testUserOp(0xa3, 0)
+	invalid.Signature = nil
+
+	tests := []struct {
+		name    string
+		limits  mempool.Limits
+		userOps []*types.UserOperation
+		wantErr bool
+		added   int
+	}{
+		{"all valid", mempool.Limits{}, []*types.UserOperation{testUserOp(0xa1, 0), testUserOp(0xa2, 0)}, false, 2},
+		{"invalid last userOp", mempool.Limits{}, []*types.UserOperation{testUserOp(0xa1, 0), testUserOp(0xa2, 0), invalid}, true, 0},
+		{"duplicate in batch", mempool.Limits{}, []*types.UserOperation{testUserOp(0xa1, 0), testUserOp(0xa1, 0)}, true, 0},
+		{"over sender limit", mempool.Limits{MaxOpsPerSender: 1}, []*types.UserOperation{testUserOp(0xa1, 0), testUserOp(0xa1, 1)}, true, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newDebugTestServer(test.limits)
+			_, rpcErr := server.handleDebugBundlerAddUserOps(addUserOpsParams(t, test.userOps...))
+			if (rpcErr != nil) != test.wantErr {
+				t.Fatalf("addUserOps err = %v, want error %v", rpcErr, test.wantErr)
+			}
+			if size := server.mempools[types.EntryPointV07Address.Hex()].Size(); size != test.added {
+				t.Fatalf("mempool size = %d, want %d", size, test.added)
+			}
+		})
+	}
+}
+
+func TestDebugBundlerSendBundleNowOrder(t *testing.T) {
+	low := common.HexToAddress("0x0000000000000000000000000000000000000001").Hex()
+	high := common.HexToAddress("0xFFfFfFffFFfffFFfFFfFFFFFffFFFffffFfFFFfF").Hex()
+	lowTx, highTx := common.HexToHash("0x01"), common.HexToHash("0x02")
+
+	// Whatever order they are configured in, entry points are bundled in address order
+	for _, configured := range [][]string{{low, high}, {high, low}} {
+		var calls []common.Hash
+		server := &RPCServer{
+			processors: map[string]processor.Processor{
+				low:  &bundleProcessor{txHash: lowTx, calls: &calls},
+				high: &bundleProcessor{txHash: highTx, calls: &calls},
+			},
+			supportedEntryPoints: configured,
+		}
+
+		result, rpcErr := server.handleDebugBundlerSendBundleNow(context.Background())
+		if rpcErr != nil {
+			t.Fatalf("sendBundleNow: %v", rpcErr)
+		}
+		if txHash, ok := result.(*string); !ok || txHash == nil || *txHash != lowTx.Hex() {
+			t.Fatalf("sendBundleNow = %v, want the lowest entry point's bundle %s", result, lowTx.Hex())
+		}
+		if len(calls) != 2 || calls[0] != lowTx || calls[1] != highTx {
+			t.Fatalf("bundled %v, want the lowest entry point first", calls)
+		}
+	}
+}
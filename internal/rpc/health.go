@@ -0,0 +1,147 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/vorpalengineering/gundler/internal/processor"
+)
+
+// Time limit for readiness checks that query the node
+const readinessTimeout = 5 * time.Second
+
+// ReadinessConfig holds the thresholds checked by /ready
+type ReadinessConfig struct {
+	MinKeyBalance   *big.Int      // at least one key must hold this much (wei)
+	MaxBlockAge     time.Duration // latest block must be newer than this
+	MaxMempoolUsage uint          // percentage of mempool capacity
+}
+
+type readinessCheck struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+type readinessReport struct {
+	Ready  bool             `json:"ready"`
+	Checks []readinessCheck `json:"checks"`
+}
+
+// handleHealth reports liveness: the process is up and serving HTTP
+func (rpc *RPCServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// handleReady reports whether gundler can accept and bundle userOps. Every
+// check is run and failing checks explain why.
+func (rpc *RPCServer) handleReady(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	// Run all checks
+	checks := []readinessCheck{rpc.checkNode(ctx)}
+	checks = append(checks, rpc.checkProcessors()...)
+	checks = append(checks, rpc.checkKeys())
+	checks = append(checks, rpc.checkMempools()...)
+
+	report := readinessReport{Ready: true, Checks: checks}
+	for _, check := range checks {
+		if !check.OK {
+			report.Ready = false
+		}
+	}
+
+	// Send report
+	w.Header().Set("Content-Type", "application/json")
+	if !report.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// checkNode checks that the node is reachable and its latest block is fresh
+func (rpc *RPCServer) checkNode(ctx context.Context) readinessCheck {
+	check := readinessCheck{Name: "node"}
+	readiness := rpc.getReadiness()
+
+	header, err := rpc.ethClient.HeaderByNumber(ctx, nil)
+	if err != nil {
+		check.Message = fmt.Sprintf("node unreachable: %v", err)
+		return check
+	}
+
+	blockAge := time.Since(time.Unix(int64(header.Time), 0)).Truncate(time.Second)
+	if blockAge > readiness.MaxBlockAge {
+		check.Message = fmt.Sprintf("latest block %v is %v old (max: %v)", header.Number, blockAge, readiness.MaxBlockAge)
+		return check
+	}
+
+	check.OK = true
+	return check
+}
+
+// checkProcessors checks that every processor is running and neither paused nor draining
+func (rpc *RPCServer) checkProcessors() []readinessCheck {
+	checks := make([]readinessCheck, 0, len(rpc.processors))
+	for entryPoint, proc := range rpc.processors {
+		check := readinessCheck{Name: "processor:" + entryPoint}
+		switch {
+		case !proc.IsRunning():
+			check.Message = "processor is not running"
+		case proc.State() != processor.StateActive:
+			check.Message = "processor is " + proc.State()
+		default:
+			check.OK = true
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// checkKeys checks that at least one enabled key can pay for bundles, using the
+// balances last seen by the key pool's balance monitor
+func (rpc *RPCServer) checkKeys() readinessCheck {
+	check := readinessCheck{Name: "keys"}
+	readiness := rpc.getReadiness()
+
+	funded := 0
+	keys := rpc.keyPool.Keys()
+	for _, key := range keys {
+		if !key.Disabled && key.Balance != nil && key.Balance.Cmp(readiness.MinKeyBalance) >= 0 {
+			funded++
+		}
+	}
+
+	if funded == 0 {
+		check.Message = fmt.Sprintf("no enabled key has a balance of at least %v wei (keys: %d)", readiness.MinKeyBalance, len(keys))
+		return check
+	}
+
+	check.OK = true
+	return check
+}
+
+// checkMempools checks that no bounded mempool is close to capacity
+func (rpc *RPCServer) checkMempools() []readinessCheck {
+	readiness := rpc.getReadiness()
+	checks := make([]readinessCheck, 0, len(rpc.mempools))
+	for entryPoint, mempool := range rpc.mempools {
+		check := readinessCheck{Name: "mempool:" + entryPoint, OK: true}
+		capacity := mempool.Capacity()
+		if capacity > 0 {
+			size := mempool.Size()
+			if uint(size*100) >= uint(capacity)*readiness.MaxMempoolUsage {
+				check.OK = false
+				check.Message = fmt.Sprintf("mempool is %d%% full (%d/%d, max: %d%%)", size*100/capacity, size, capacity, readiness.MaxMempoolUsage)
+			}
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
@@ -0,0 +1,164 @@
+package rpc
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/vorpalengineering/gundler/internal/keypool"
+	"github.com/vorpalengineering/gundler/internal/mempool"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+// healthNode is a fake node whose latest block time and reachability the test changes
+type healthNode struct {
+	mutex     sync.Mutex
+	blockTime time.Time
+	down      bool
+}
+
+func (node *healthNode) set(blockTime time.Time, down bool) {
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+	node.blockTime = blockTime
+	node.down = down
+}
+
+func (node *healthNode) serve(t *testing.T) *ethclient.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		node.mutex.Lock()
+		blockTime, down := node.blockTime, node.down
+		node.mutex.Unlock()
+		if down {
+			http.Error(w, "node down", http.StatusBadGateway)
+			return
+		}
+
+		resp := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "eth_getBlockByNumber":
+			resp["result"] = &ethtypes.Header{Number: big.NewInt(100), Time: uint64(blockTime.Unix()), Difficulty: new(big.Int)}
+		case "eth_blockNumber":
+			resp["result"] = "0x64"
+		case "eth_getBalance":
+			resp["result"] = hexutil.EncodeBig(big.NewInt(1000))
+		default:
+			resp["error"] = map[string]any{"code": -32601, "message": "method not found"}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := ethclient.Dial(server.URL)
+	if err != nil {
+		t.Fatalf("failed to dial fake node: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+// ready returns the /ready status and the names of the failing checks
+func ready(t *testing.T, server *RPCServer) (int, []string) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	server.handleReady(recorder, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+	var report readinessReport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatalf("invalid readiness report %q: %v", recorder.Body.String(), err)
+	}
+	var failing []string
+	for _, check := range report.Checks {
+		if !check.OK {
+			failing = append(failing, check.Name)
+		}
+	}
+	if report.Ready != (recorder.Code == http.StatusOK) {
+		t.Fatalf("ready = %v with status %d", report.Ready, recorder.Code)
+	}
+	return recorder.Code, failing
+}
+
+func TestReadinessTransitions(t *testing.T) {
+	node := &healthNode{blockTime: time.Now()}
+	client := node.serve(t)
+
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	signer := keypool.NewLocalSigner(privateKey)
+	keyPool, err := keypool.NewKeyPool([]keypool.Signer{signer}, client, big.NewInt(1), keypool.StrategyRoundRobin, 1)
+	if err != nil {
+		t.Fatalf("NewKeyPool: %v", err)
+	}
+
+	entryPoint := types.EntryPointV07Address.Hex()
+	server := newDebugTestServer(mempool.Limits{MaxSize: 2})
+	server.ethClient = client
+	server.keyPool = keyPool
+	server.readiness = ReadinessConfig{MinKeyBalance: big.NewInt(1000), MaxBlockAge: time.Minute, MaxMempoolUsage: 50}
+
+	// Keys have no known balance until the balance monitor checked them
+	if status, failing := ready(t, server); status != http.StatusServiceUnavailable || len(failing) != 1 || failing[0] != "keys" {
+		t.Fatalf("before balance check: status %d, failing %v, want keys failing", status, failing)
+	}
+	keyPool.StartBalanceMonitor(keypool.BalanceMonitorConfig{MinBalance: big.NewInt(1000)})
+	defer keyPool.Stop()
+	deadline := time.Now().Add(5 * time.Second)
+	for keyPool.Keys()[0].Balance == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("balance monitor didn't check the key")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	tests := []struct {
+		name    string
+		change  func()
+		failing string // failing check, none if ready
+	}{
+		{"ready", func() {}, ""},
+		{"paused", func() { server.processors[entryPoint].Pause() }, "processor:" + entryPoint},
+		{"resumed", func() { server.processors[entryPoint].Resume() }, ""},
+		{"draining", func() { server.processors[entryPoint].Drain() }, "processor:" + entryPoint},
+		{"drained and resumed", func() { server.processors[entryPoint].Resume() }, ""},
+		{"stale block", func() { node.set(time.Now().Add(-2*time.Minute), false) }, "node"},
+		{"node down", func() { node.set(time.Now(), true) }, "node"},
+		{"node back", func() { node.set(time.Now(), false) }, ""},
+		{"key disabled", func() { keyPool.SetKeyEnabled(signer.Address(), false) }, "keys"},
+		{"key enabled", func() { keyPool.SetKeyEnabled(signer.Address(), true) }, ""},
+		{"mempool at max usage", func() { server.mempools[entryPoint].Add(testUserOp(0xa1, 0)) }, "mempool:" + entryPoint},
+		{"mempool below max usage", func() { server.mempools[entryPoint].Clear() }, ""},
+	}
+	for _, test := range tests {
+		test.change()
+		status, failing := ready(t, server)
+		if test.failing == "" {
+			if status != http.StatusOK || len(failing) != 0 {
+				t.Fatalf("%s: status %d, failing %v, want ready", test.name, status, failing)
+			}
+			continue
+		}
+		if status != http.StatusServiceUnavailable || len(failing) != 1 || failing[0] != test.failing {
+			t.Fatalf("%s: status %d, failing %v, want %s failing", test.name, status, failing, test.failing)
+		}
+	}
+}
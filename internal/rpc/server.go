@@ -1,68 +1,163 @@
 package rpc
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math/big"
+	"net"
 	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gorilla/websocket"
+	"github.com/vorpalengineering/gundler/internal/events"
+	"github.com/vorpalengineering/gundler/internal/history"
+	"github.com/vorpalengineering/gundler/internal/journal"
 	"github.com/vorpalengineering/gundler/internal/keypool"
+	"github.com/vorpalengineering/gundler/internal/logging"
 	"github.com/vorpalengineering/gundler/internal/mempool"
+	"github.com/vorpalengineering/gundler/internal/metrics"
 	"github.com/vorpalengineering/gundler/internal/processor"
+	"github.com/vorpalengineering/gundler/internal/ratelimit"
+	"github.com/vorpalengineering/gundler/internal/reputation"
 	"github.com/vorpalengineering/gundler/pkg/types"
 )
 
+// Time limit for active requests to complete once shutdown stops serving
+const httpShutdownTimeout = 5 * time.Second
+
+// ServerConfig controls the HTTP listener. TLS is enabled when TLSCertFile and
+// TLSKeyFile are set. Zero timeouts are disabled.
+type ServerConfig struct {
+	Host              string
+	Port              uint
+	TLSCertFile       string
+	TLSKeyFile        string
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxBodySize       int64    // maximum HTTP request body and WebSocket message size in bytes
+	AdminListen       string   // admin API address, host:port or unix:<path>, disabled if empty
+	AdminToken        string   // bearer token required by the admin API
+	WSAllowedOrigins  []string // browser origins allowed to open WebSocket connections, same origin only if empty
+}
+
+// EntryPointConfig holds the settings of one supported EntryPoint
+type EntryPointConfig struct {
+	Address         common.Address
+	Bundling        processor.BundlingConfig
+	Beneficiary     common.Address
+	Keys            []common.Address // bundler keys used for this EntryPoint, all keys if empty
+	CheckReputation bool             // reject userOps referencing banned entities
+	Simulate        bool             // simulate bundles before submitting them
+}
+
 type RPCServer struct {
 	server               *http.Server
+	adminServer          *http.Server // nil if the admin API is disabled
+	adminToken           string
 	ethClient            *ethclient.Client
 	mempools             map[string]*mempool.Mempool // entryPointAddress => Mempool
 	processors           map[string]processor.Processor
+	reputations          map[string]*reputation.Reputation // entryPointAddress => Reputation
+	checkReputation      map[string]bool                   // entryPointAddress => reputation stage enabled
+	keyPool              *keypool.KeyPool
+	history              *history.Store
+	events               *events.Feed
+	upgrader             *websocket.Upgrader
+	wsConnections        wsRegistry
 	chainID              *big.Int
 	supportedEntryPoints []string
 	mode                 string
+	maxBatchSize         uint
+	maxBodySize          int64
+	readiness            ReadinessConfig
+	access               AccessConfig
+	apiKeys              map[string]*APIKey // key => API key
+	limiter              *ratelimit.Limiter
+	settingsMutex        sync.RWMutex // guards checkReputation, maxBatchSize, readiness, access and apiKeys
+	shuttingDown         atomic.Bool  // processors stay draining once set
 }
 
 func NewRPCServer(
-	port uint,
+	serverConfig ServerConfig,
 	ethRPC string,
-	supportedEntryPoints []string,
+	entryPoints []EntryPointConfig,
 	mode string,
-	maxBundleSize uint,
+	mempoolLimits mempool.Limits,
 	ethClient *ethclient.Client,
 	chainID *big.Int,
 	keyPool *keypool.KeyPool,
+	mempoolJournal *journal.Journal,
+	historyStore *history.Store,
+	maxBatchSize uint,
+	readiness ReadinessConfig,
+	access AccessConfig,
 ) (*RPCServer, error) {
 
 	// Initialize mux handler
 	mux := http.NewServeMux()
 
-	// Register healthcheck route
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
+	// Userop and bundle events for WebSocket subscriptions
+	eventFeed := events.NewFeed()
 
 	// Initialize mempool and processor for each supported entrypoint
-	mempools := make(map[string]*mempool.Mempool, len(supportedEntryPoints))
-	processors := make(map[string]processor.Processor, len(supportedEntryPoints))
-	for _, epStr := range supportedEntryPoints {
+	mempools := make(map[string]*mempool.Mempool, len(entryPoints))
+	processors := make(map[string]processor.Processor, len(entryPoints))
+	reputations := make(map[string]*reputation.Reputation, len(entryPoints))
+	checkReputation := make(map[string]bool, len(entryPoints))
+	supportedEntryPoints := make([]string, 0, len(entryPoints))
+	for _, epConfig := range entryPoints {
+		// Check the EntryPoint's keys are bundler keys
+		for _, key := range epConfig.Keys {
+			if _, exists := keyPool.Signer(key); !exists {
+				return nil, fmt.Errorf("key %s of entry point %s is not a bundler key", key.Hex(), epConfig.Address.Hex())
+			}
+		}
+
 		// Create mempool
-		entryPoint := common.HexToAddress(epStr)
+		entryPoint := epConfig.Address
 		normalizedAddress := entryPoint.Hex()
-		mempools[normalizedAddress] = mempool.NewMempool(entryPoint, chainID)
+		supportedEntryPoints = append(supportedEntryPoints, normalizedAddress)
+		mempools[normalizedAddress] = mempool.NewMempool(entryPoint, chainID, mempoolLimits)
+
+		// Restore persisted userOps (re-validated) and journal further changes
+		mempools[normalizedAddress].SetJournal(mempoolJournal)
+		mempools[normalizedAddress].SetEventFeed(eventFeed)
+		if persisted := mempoolJournal.UserOps(entryPoint); len(persisted) > 0 {
+			restored := mempools[normalizedAddress].Restore(persisted)
+			log.Printf("Restored %d of %d persisted userOps for entry point: %s", restored, len(persisted), normalizedAddress)
+		}
+
+		// Create entity reputation
+		reputations[normalizedAddress] = reputation.NewReputation()
+		checkReputation[normalizedAddress] = epConfig.CheckReputation
 
 		// Create processor
 		processors[normalizedAddress] = processor.NewBasicProcessor(
 			mempools[normalizedAddress],
 			ethClient,
-			1*time.Second,
-			maxBundleSize,
+			epConfig.Bundling,
 			keyPool,
+			epConfig.Keys,
+			epConfig.Beneficiary,
+			epConfig.Simulate,
+			mempoolJournal,
+			historyStore,
+			reputations[normalizedAddress],
+			eventFeed,
 		)
 		if err := processors[normalizedAddress].Start(context.Background()); err != nil {
 			log.Fatalf("Failed to start processor: %v", err)
@@ -73,33 +168,152 @@ func NewRPCServer(
 
 	rpc := &RPCServer{
 		server: &http.Server{
-			Addr:    fmt.Sprintf("localhost:%v", port),
-			Handler: mux,
+			Addr:              net.JoinHostPort(serverConfig.Host, strconv.FormatUint(uint64(serverConfig.Port), 10)),
+			Handler:           mux,
+			ReadHeaderTimeout: serverConfig.ReadHeaderTimeout,
+			ReadTimeout:       serverConfig.ReadTimeout,
+			WriteTimeout:      serverConfig.WriteTimeout,
+			IdleTimeout:       serverConfig.IdleTimeout,
 		},
 		ethClient:            ethClient,
 		mempools:             mempools,
 		processors:           processors,
+		reputations:          reputations,
+		checkReputation:      checkReputation,
+		keyPool:              keyPool,
+		history:              historyStore,
+		events:               eventFeed,
+		upgrader:             newUpgrader(serverConfig.WSAllowedOrigins),
 		chainID:              chainID,
 		supportedEntryPoints: supportedEntryPoints,
 		mode:                 mode,
+		maxBatchSize:         maxBatchSize,
+		maxBodySize:          serverConfig.MaxBodySize,
+		readiness:            readiness,
+		access:               access,
+		apiKeys:              apiKeyIndex(access.APIKeys),
+		limiter:              ratelimit.NewLimiter(),
+	}
+
+	// Serve TLS with certificates reloaded when the files are rotated
+	if serverConfig.TLSCertFile != "" {
+		certs, err := newCertReloader(serverConfig.TLSCertFile, serverConfig.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		rpc.server.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.GetCertificate,
+		}
+	}
+
+	// Serve the admin API on its own listener
+	if serverConfig.AdminListen != "" {
+		if serverConfig.AdminToken == "" {
+			return nil, fmt.Errorf("admin API requires a token")
+		}
+		rpc.adminServer = rpc.newAdminServer(serverConfig)
+		rpc.adminToken = serverConfig.AdminToken
 	}
 
+	// Register liveness and readiness routes
+	mux.HandleFunc("/health", rpc.handleHealth)
+	mux.HandleFunc("/ready", rpc.handleReady)
+
+	// Register metrics route
+	metrics.OnCollect(rpc.collectMetrics)
+	mux.HandleFunc("/metrics", metrics.Handler)
+
 	// Register base route
 	mux.HandleFunc("/", rpc.handleRPCRequest)
 
 	// Log debug methods availability
 	if mode == "DEBUG" {
-		log.Println("Debug RPC methods enabled: debug_mempools, debug_pause, debug_clear")
+		log.Println("Debug RPC methods enabled: debug_mempools, debug_pause, debug_clear, debug_bundler_*")
 	}
 
 	return rpc, nil
 }
 
+// CheckReconfigure checks that reloaded EntryPoint settings can be applied with
+// Reconfigure: the set of EntryPoints cannot change without a restart
+func (rpc *RPCServer) CheckReconfigure(entryPoints []EntryPointConfig) error {
+	if len(entryPoints) != len(rpc.processors) {
+		return fmt.Errorf("supported entry points cannot change without a restart")
+	}
+	for _, epConfig := range entryPoints {
+		if _, exists := rpc.processors[epConfig.Address.Hex()]; !exists {
+			return fmt.Errorf("supported entry points cannot change without a restart")
+		}
+	}
+	return nil
+}
+
+// Reconfigure applies reloaded settings, checked with CheckReconfigure, to every
+// EntryPoint's processor and mempool. The EntryPoints' keys must already be in the key pool.
+func (rpc *RPCServer) Reconfigure(entryPoints []EntryPointConfig, mempoolLimits mempool.Limits, maxBatchSize uint, readiness ReadinessConfig, access AccessConfig) {
+	rpc.settingsMutex.Lock()
+	defer rpc.settingsMutex.Unlock()
+
+	for _, epConfig := range entryPoints {
+		normalizedAddress := epConfig.Address.Hex()
+		rpc.processors[normalizedAddress].Reconfigure(epConfig.Bundling, epConfig.Keys, epConfig.Beneficiary, epConfig.Simulate)
+		rpc.mempools[normalizedAddress].SetLimits(mempoolLimits)
+		rpc.checkReputation[normalizedAddress] = epConfig.CheckReputation
+	}
+	rpc.maxBatchSize = maxBatchSize
+	rpc.readiness = readiness
+	rpc.access = access
+	rpc.apiKeys = apiKeyIndex(access.APIKeys)
+}
+
+func (rpc *RPCServer) getMaxBatchSize() uint {
+	rpc.settingsMutex.RLock()
+	defer rpc.settingsMutex.RUnlock()
+
+	return rpc.maxBatchSize
+}
+
+func (rpc *RPCServer) getReadiness() ReadinessConfig {
+	rpc.settingsMutex.RLock()
+	defer rpc.settingsMutex.RUnlock()
+
+	return rpc.readiness
+}
+
+func (rpc *RPCServer) isCheckingReputation(entryPoint string) bool {
+	rpc.settingsMutex.RLock()
+	defer rpc.settingsMutex.RUnlock()
+
+	return rpc.checkReputation[entryPoint]
+}
+
 func (rpc *RPCServer) Start() error {
-	fmt.Printf("Starting RPC Server on: %s\n", rpc.server.Addr)
+	// Listen before returning so bind errors are reported to the caller
+	listener, err := net.Listen("tcp", rpc.server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", rpc.server.Addr, err)
+	}
+
+	if err := rpc.startAdmin(); err != nil {
+		listener.Close()
+		return err
+	}
+
+	if rpc.server.TLSConfig != nil {
+		fmt.Printf("Starting RPC Server on: %s (TLS)\n", rpc.server.Addr)
+	} else {
+		fmt.Printf("Starting RPC Server on: %s\n", rpc.server.Addr)
+	}
 
 	go func() {
-		if err := rpc.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if rpc.server.TLSConfig != nil {
+			err = rpc.server.ServeTLS(listener, "", "")
+		} else {
+			err = rpc.server.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("RPC Server error: %v", err)
 		}
 	}()
@@ -107,38 +321,168 @@ func (rpc *RPCServer) Start() error {
 	return nil
 }
 
+// Shutdown stops accepting userOps, stops the processors and waits until ctx is
+// done for submitted bundles to confirm, then stops serving. Reads are served
+// until the end so clients can follow their userOps. The node connection is left
+// open for the caller to close once pending state is persisted.
 func (rpc *RPCServer) Shutdown(ctx context.Context) error {
 	fmt.Println("Shutting down RPC Server...")
 
-	// Close ethereum client connection
-	if rpc.ethClient != nil {
-		rpc.ethClient.Close()
+	// Stop accepting userOps, processor states can't be changed from here on
+	rpc.shuttingDown.Store(true)
+	for _, proc := range rpc.processors {
+		proc.Drain()
 	}
 
-	// Stop processors
+	// Stop processors, abandoning bundles still in progress at the deadline
 	for _, proc := range rpc.processors {
-		if err := proc.Stop(); err != nil {
+		if err := proc.Stop(ctx); err != nil {
 			log.Printf("Failed to stop processor: %v", err)
 		}
 	}
 
-	return rpc.server.Shutdown(ctx)
+	// Wait for every processor's submitted bundles at the same time
+	var wg sync.WaitGroup
+	for entryPoint, proc := range rpc.processors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if pending := proc.WaitPendingBundles(ctx); pending > 0 {
+				log.Printf("%d bundles for entry point %s still pending, they are checked again after a restart", pending, entryPoint)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Stop serving, giving active requests a short time to complete
+	httpCtx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+	defer cancel()
+
+	if err := rpc.shutdownAdmin(httpCtx); err != nil {
+		log.Printf("Failed to stop admin API: %v", err)
+	}
+
+	err := rpc.server.Shutdown(httpCtx)
+
+	// WebSocket connections are hijacked, so the HTTP server leaves them open
+	if closed := rpc.wsConnections.closeAll(); closed > 0 {
+		log.Printf("Closed %d WebSocket connections", closed)
+	}
+
+	return err
 }
 
 func (rpc *RPCServer) handleRPCRequest(w http.ResponseWriter, r *http.Request) {
+	// Identify the client for rate limits
+	client, ok := rpc.authenticate(w, r)
+	if !ok {
+		return
+	}
+	r = r.WithContext(withClient(r.Context(), client))
+
+	// WebSocket clients connect on the same route
+	if websocket.IsWebSocketUpgrade(r) {
+		rpc.handleWebSocket(w, r)
+		return
+	}
+
+	rpc.serveJSONRPC(w, r, rpc.handleRequest)
+}
+
+// serveJSONRPC reads a single or batch JSON-RPC request from an HTTP POST body
+// and writes the responses of handle
+func (rpc *RPCServer) serveJSONRPC(w http.ResponseWriter, r *http.Request, handle func(context.Context, *types.RPCRequest) *types.RPCResponse) {
 	// Restrict to POST requests
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	// Read request body, up to the max body size
+	if rpc.maxBodySize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, rpc.maxBodySize)
+	}
+	body, readErr := io.ReadAll(r.Body)
+	if readErr != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(readErr, &maxBytesErr) {
+			http.Error(w, fmt.Sprintf("Request body exceeds %d bytes", maxBytesErr.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		rpc.sendError(w, nil, -32700, "Parse error")
+		return
+	}
+
+	// JSON arrays are batch requests
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		resp := rpc.handleBatchRequest(r.Context(), body, handle)
+		if resp == nil {
+			// Nothing to return if the batch only contained notifications
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		rpc.writeResponse(w, resp)
+		return
+	}
+
 	// Decode request as JSON
 	var req types.RPCRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.Unmarshal(body, &req); err != nil {
 		rpc.sendError(w, nil, -32700, "Parse error")
 		return
 	}
 
+	// Send response
+	rpc.writeResponse(w, handle(r.Context(), &req))
+}
+
+// handleBatchRequest dispatches each request of a batch with handle and returns
+// the batch response, or nil if the batch only contained notifications
+func (rpc *RPCServer) handleBatchRequest(ctx context.Context, body []byte, handle func(context.Context, *types.RPCRequest) *types.RPCResponse) any {
+	// Decode batch as array of raw requests
+	var rawRequests []json.RawMessage
+	if err := json.Unmarshal(body, &rawRequests); err != nil {
+		return newErrorResponse(nil, -32700, "Parse error")
+	}
+	if len(rawRequests) == 0 {
+		return newErrorResponse(nil, -32600, "Invalid Request: empty batch")
+	}
+	if maxBatchSize := rpc.getMaxBatchSize(); len(rawRequests) > int(maxBatchSize) {
+		return newErrorResponse(nil, -32600, fmt.Sprintf("Invalid Request: batch of %d exceeds max batch size %d", len(rawRequests), maxBatchSize))
+	}
+
+	// Dispatch each request independently, notifications get no response
+	responses := make([]*types.RPCResponse, 0, len(rawRequests))
+	for _, rawRequest := range rawRequests {
+		var req types.RPCRequest
+		if err := json.Unmarshal(rawRequest, &req); err != nil {
+			responses = append(responses, newErrorResponse(nil, -32600, "Invalid Request"))
+			continue
+		}
+		resp := handle(ctx, &req)
+		if isNotification(rawRequest) {
+			continue
+		}
+		responses = append(responses, resp)
+	}
+
+	if len(responses) == 0 {
+		return nil
+	}
+
+	return responses
+}
+
+// handleRequest routes a single request to its handler and builds the response
+func (rpc *RPCServer) handleRequest(ctx context.Context, req *types.RPCRequest) *types.RPCResponse {
+	start := time.Now()
+
+	// Check the client's rate limits
+	if limitErr := rpc.checkRateLimit(ctx, req.Method); limitErr != nil {
+		return &types.RPCResponse{JSONRPC: "2.0", Error: limitErr, ID: req.ID}
+	}
+
 	// Route to appropriate handler
 	var result any
 	var err *types.RPCError
@@ -151,6 +495,15 @@ func (rpc *RPCServer) handleRPCRequest(w http.ResponseWriter, r *http.Request) {
 		result, err = rpc.handleSupportedEntryPoints()
 	case "eth_sendUserOperation":
 		result, err = rpc.handleSendUserOperation(req.Params)
+	case "eth_getUserOperationReceipt":
+		result, err = rpc.handleGetUserOperationReceipt(req.Params)
+	case "eth_getUserOperationByHash":
+		result, err = rpc.handleGetUserOperationByHash(req.Params)
+	case "eth_subscribe", "eth_unsubscribe":
+		err = &types.RPCError{
+			Code:    -32601,
+			Message: "Subscriptions are only available over WebSocket",
+		}
 	default:
 		// Check for debug methods if in DEBUG mode
 		if rpc.mode == "DEBUG" {
@@ -158,9 +511,25 @@ func (rpc *RPCServer) handleRPCRequest(w http.ResponseWriter, r *http.Request) {
 			case "debug_mempools":
 				result, err = rpc.handleDebugMempools()
 			case "debug_pause":
-				result, err = rpc.handleDebugPause()
+				result, err = rpc.handleDebugPause(req.Params)
 			case "debug_clear":
 				result, err = rpc.handleDebugClear()
+			case "debug_bundler_clearState":
+				result, err = rpc.handleDebugBundlerClearState()
+			case "debug_bundler_dumpMempool":
+				result, err = rpc.handleDebugBundlerDumpMempool(req.Params)
+			case "debug_bundler_sendBundleNow":
+				result, err = rpc.handleDebugBundlerSendBundleNow(ctx)
+			case "debug_bundler_setBundlingMode":
+				result, err = rpc.handleDebugBundlerSetBundlingMode(req.Params)
+			case "debug_bundler_setReputation":
+				result, err = rpc.handleDebugBundlerSetReputation(req.Params)
+			case "debug_bundler_dumpReputation":
+				result, err = rpc.handleDebugBundlerDumpReputation(req.Params)
+			case "debug_bundler_addUserOps":
+				result, err = rpc.handleDebugBundlerAddUserOps(req.Params)
+			case "debug_bundler_getStakeStatus":
+				result, err = rpc.handleDebugBundlerGetStakeStatus(ctx, req.Params)
 			default:
 				err = &types.RPCError{
 					Code:    -32601,
@@ -175,27 +544,53 @@ func (rpc *RPCServer) handleRPCRequest(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Send response
+	// Record latency and errors, unknown methods share one label
+	method := req.Method
+	if err != nil && err.Code == -32601 {
+		method = "unknown"
+	}
+	metrics.RPCDuration.ObserveDuration(start, method)
+
 	if err != nil {
-		rpc.sendError(w, req.ID, err.Code, err.Message)
-		return
+		metrics.RPCErrors.Inc(method, strconv.Itoa(err.Code))
+		return newErrorResponse(req.ID, err.Code, err.Message)
 	}
 
-	rpc.sendResult(w, req.ID, result)
+	return newResultResponse(req.ID, result)
 }
 
-func (rpc *RPCServer) sendResult(w http.ResponseWriter, id any, result any) {
-	resp := &types.RPCResponse{
+// collectMetrics refreshes mempool and key gauges before a scrape. Key balances
+// are updated by the key pool's balance monitor.
+func (rpc *RPCServer) collectMetrics(ctx context.Context) {
+	for entryPoint, mempool := range rpc.mempools {
+		metrics.MempoolSize.Set(float64(mempool.Size()), entryPoint)
+	}
+
+	for _, key := range rpc.keyPool.Keys() {
+		metrics.KeyInFlight.Set(float64(key.Pending), key.Address.Hex())
+	}
+}
+
+// isNotification reports whether a raw request has no id member
+func isNotification(rawRequest json.RawMessage) bool {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(rawRequest, &members); err != nil {
+		return false
+	}
+	_, hasID := members["id"]
+	return !hasID
+}
+
+func newResultResponse(id any, result any) *types.RPCResponse {
+	return &types.RPCResponse{
 		JSONRPC: "2.0",
 		Result:  result,
 		ID:      id,
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
 }
 
-func (rpc *RPCServer) sendError(w http.ResponseWriter, id any, code int, message string) {
-	resp := &types.RPCResponse{
+func newErrorResponse(id any, code int, message string) *types.RPCResponse {
+	return &types.RPCResponse{
 		JSONRPC: "2.0",
 		Error: &types.RPCError{
 			Code:    code,
@@ -203,10 +598,21 @@ func (rpc *RPCServer) sendError(w http.ResponseWriter, id any, code int, message
 		},
 		ID: id,
 	}
+}
+
+func (rpc *RPCServer) writeResponse(w http.ResponseWriter, resp any) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
+func (rpc *RPCServer) sendResult(w http.ResponseWriter, id any, result any) {
+	rpc.writeResponse(w, newResultResponse(id, result))
+}
+
+func (rpc *RPCServer) sendError(w http.ResponseWriter, id any, code int, message string) {
+	rpc.writeResponse(w, newErrorResponse(id, code, message))
+}
+
 func (rpc *RPCServer) handleChainId() (string, *types.RPCError) {
 	return fmt.Sprintf("0x%x", rpc.chainID), nil
 }
@@ -279,17 +685,141 @@ func (rpc *RPCServer) handleSendUserOperation(params json.RawMessage) (string, *
 		}
 	}
 
+	// Reject userOps while the EntryPoint is draining
+	if rpcErr := rpc.checkAccepting(normalizedAddress); rpcErr != nil {
+		return "", rpcErr
+	}
+
+	// Reject userOps referencing banned entities
+	entityReputation := rpc.reputations[normalizedAddress]
+	entities := reputation.Entities(&userOp)
+	checkReputation := rpc.isCheckingReputation(normalizedAddress)
+	for _, entity := range entities {
+		if checkReputation && entityReputation.Status(entity) == reputation.StatusBanned {
+			metrics.UserOpsRejected.Inc(normalizedAddress, "banned")
+			return "", &types.RPCError{
+				Code:    -32504,
+				Message: fmt.Sprintf("Entity %s is banned", entity.Hex()),
+			}
+		}
+	}
+
 	if err := mempool.Add(&userOp); err != nil {
 		return "", &types.RPCError{
 			Code:    -32602,
 			Message: fmt.Sprintf("Failed adding userOp to mempool: %v", err),
 		}
 	}
+	entityReputation.UpdateSeen(entities...)
 
 	// Calculate userOp hash
 	userOpHash := userOp.Hash(entryPoint, rpc.chainID)
 
-	log.Printf("UserOp %s validated and added to mempool. Mempool size: %v", userOpHash.Hex(), mempool.Size())
+	logging.Debugf("UserOp %s validated and added to mempool. Mempool size: %v", userOpHash.Hex(), mempool.Size())
 
 	return userOpHash.Hex(), nil
 }
+
+// checkAccepting returns an error if the EntryPoint is draining or gundler is
+// shutting down, for every path that adds userOps to a mempool
+func (rpc *RPCServer) checkAccepting(normalizedAddress string) *types.RPCError {
+	if rpc.shuttingDown.Load() || rpc.processors[normalizedAddress].State() == processor.StateDraining {
+		metrics.UserOpsRejected.Inc(normalizedAddress, "draining")
+		return &types.RPCError{
+			Code:    -32603,
+			Message: fmt.Sprintf("Entry point %s is draining and not accepting new userOps", normalizedAddress),
+		}
+	}
+	return nil
+}
+
+// checkStateChange returns an error once shutdown has started, so processors
+// can't be resumed or paused while they drain
+func (rpc *RPCServer) checkStateChange() *types.RPCError {
+	if rpc.shuttingDown.Load() {
+		return &types.RPCError{
+			Code:    -32603,
+			Message: "Shutting down, processor states can't be changed",
+		}
+	}
+	return nil
+}
+
+func (rpc *RPCServer) handleGetUserOperationReceipt(params json.RawMessage) (*types.UserOperationReceipt, *types.RPCError) {
+	// Parse userOpHash from params
+	userOpHash, rpcErr := parseUserOpHashParam(params)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	// Receipts only exist for userOps included on chain
+	entry, exists := rpc.history.Get(userOpHash)
+	if !exists || !entry.Included() {
+		return nil, nil
+	}
+
+	// The bundle transaction receipt was stored when the bundle confirmed
+	return entry.Receipt(), nil
+}
+
+func (rpc *RPCServer) handleGetUserOperationByHash(params json.RawMessage) (*types.UserOperationByHash, *types.RPCError) {
+	// Parse userOpHash from params
+	userOpHash, rpcErr := parseUserOpHashParam(params)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	// Check pending userOps first
+	for _, mempool := range rpc.mempools {
+		if userOp, exists := mempool.GetByHash(userOpHash); exists {
+			return &types.UserOperationByHash{
+				UserOperation: userOp,
+				EntryPoint:    mempool.EntryPoint,
+			}, nil
+		}
+	}
+
+	// Then userOps in submitted bundles
+	for entryPoint, proc := range rpc.processors {
+		if userOp, txHash, exists := proc.SubmittedUserOp(userOpHash); exists {
+			return &types.UserOperationByHash{
+				UserOperation:   userOp,
+				EntryPoint:      common.HexToAddress(entryPoint),
+				TransactionHash: &txHash,
+			}, nil
+		}
+	}
+
+	// Then finalized userOps, those that failed or were dropped will never be included
+	entry, exists := rpc.history.Get(userOpHash)
+	if !exists || entry.UserOp == nil || !entry.Included() {
+		return nil, nil
+	}
+	return &types.UserOperationByHash{
+		UserOperation:   entry.UserOp,
+		EntryPoint:      entry.EntryPoint,
+		TransactionHash: &entry.TxHash,
+		BlockNumber:     (*hexutil.Big)(new(big.Int).SetUint64(entry.BlockNumber)),
+		BlockHash:       &entry.BlockHash,
+	}, nil
+}
+
+func parseUserOpHashParam(params json.RawMessage) (common.Hash, *types.RPCError) {
+	var rawParams []string
+	if err := json.Unmarshal(params, &rawParams); err != nil || len(rawParams) != 1 {
+		return common.Hash{}, &types.RPCError{
+			Code:    -32602,
+			Message: "Expected 1 parameter: [userOpHash]",
+		}
+	}
+
+	userOpHash, err := hexutil.Decode(rawParams[0])
+	if err != nil || len(userOpHash) != common.HashLength {
+		return common.Hash{}, &types.RPCError{
+			Code:    -32602,
+			Message: "Invalid userOpHash",
+		}
+	}
+
+	return common.BytesToHash(userOpHash), nil
+}
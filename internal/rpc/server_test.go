@@ -0,0 +1,90 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+// echoHandler answers every request with its method, or an error for "fail"
+func echoHandler(_ context.Context, req *types.RPCRequest) *types.RPCResponse {
+	if req.Method == "fail" {
+		return newErrorResponse(req.ID, -32601, "Method not found")
+	}
+	return newResultResponse(req.ID, req.Method)
+}
+
+func serveBatch(t *testing.T, maxBatchSize uint, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	server := &RPCServer{maxBatchSize: maxBatchSize}
+	recorder := httptest.NewRecorder()
+	server.serveJSONRPC(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)), echoHandler)
+	return recorder
+}
+
+func TestBatchRequest(t *testing.T) {
+	recorder := serveBatch(t, 10, `[
+		{"jsonrpc":"2.0","method":"eth_chainId","id":1},
+		{"jsonrpc":"2.0","method":"eth_chainId"},
+		{"jsonrpc":"2.0","method":"fail","id":"b"},
+		1
+	]`)
+
+	// One response per request with an id, in order, invalid members get an error
+	var responses []struct {
+		Result any             `json:"result"`
+		Error  *types.RPCError `json:"error"`
+		ID     any             `json:"id"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &responses); err != nil {
+		t.Fatalf("decode batch response %q: %v", recorder.Body.String(), err)
+	}
+	if len(responses) != 3 {
+		t.Fatalf("got %d responses, want 3: %s", len(responses), recorder.Body.String())
+	}
+	if responses[0].ID != float64(1) || responses[0].Result != "eth_chainId" {
+		t.Errorf("response 0 = %+v, want eth_chainId result for id 1", responses[0])
+	}
+	if responses[1].ID != "b" || responses[1].Error == nil || responses[1].Error.Code != -32601 {
+		t.Errorf("response 1 = %+v, want -32601 error for id b", responses[1])
+	}
+	if responses[2].ID != nil || responses[2].Error == nil || responses[2].Error.Code != -32600 {
+		t.Errorf("response 2 = %+v, want -32600 invalid request", responses[2])
+	}
+}
+
+func TestBatchRequestLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"empty batch", `[]`, -32600},
+		{"over max batch size", `[{"jsonrpc":"2.0","method":"a","id":1},{"jsonrpc":"2.0","method":"b","id":2},{"jsonrpc":"2.0","method":"c","id":3}]`, -32600},
+		{"malformed batch", `[{"jsonrpc":"2.0",`, -32700},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := serveBatch(t, 2, test.body)
+			var resp types.RPCResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response %q: %v", recorder.Body.String(), err)
+			}
+			if resp.Error == nil || resp.Error.Code != test.code {
+				t.Fatalf("error = %+v, want code %d", resp.Error, test.code)
+			}
+		})
+	}
+}
+
+func TestBatchOfNotifications(t *testing.T) {
+	recorder := serveBatch(t, 10, `[{"jsonrpc":"2.0","method":"eth_chainId"},{"jsonrpc":"2.0","method":"fail"}]`)
+	if recorder.Code != http.StatusNoContent || recorder.Body.Len() != 0 {
+		t.Fatalf("got %d %q, want 204 with no body", recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,122 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vorpalengineering/gundler/internal/mempool"
+	"github.com/vorpalengineering/gundler/internal/processor"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+// stateProcessor is a processor that only keeps its state. WaitPendingBundles
+// waits for every processor in waiting to be waiting too, or for ctx to be done.
+type stateProcessor struct {
+	processor.Processor
+	mutex   sync.Mutex
+	state   string
+	waiting *sync.WaitGroup
+}
+
+func (proc *stateProcessor) Pause()  { proc.setState(processor.StatePaused) }
+func (proc *stateProcessor) Resume() { proc.setState(processor.StateActive) }
+func (proc *stateProcessor) Drain()  { proc.setState(processor.StateDraining) }
+
+func (proc *stateProcessor) setState(state string) {
+	proc.mutex.Lock()
+	defer proc.mutex.Unlock()
+	proc.state = state
+}
+
+func (proc *stateProcessor) State() string {
+	proc.mutex.Lock()
+	defer proc.mutex.Unlock()
+	return proc.state
+}
+
+func (proc *stateProcessor) IsPaused() bool { return proc.State() == processor.StatePaused }
+
+func (proc *stateProcessor) IsRunning() bool { return true }
+
+func (proc *stateProcessor) Stop(ctx context.Context) error { return nil }
+
+func (proc *stateProcessor) WaitPendingBundles(ctx context.Context) int {
+	if proc.waiting == nil {
+		return 0
+	}
+	proc.waiting.Done()
+	done := make(chan struct{})
+	go func() {
+		proc.waiting.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return 0
+	case <-ctx.Done():
+		return 1
+	}
+}
+
+func TestDrainingRejectsAddUserOps(t *testing.T) {
+	server := newDebugTestServer(mempool.Limits{})
+	entryPoint := types.EntryPointV07Address.Hex()
+	server.processors[entryPoint].Drain()
+
+	if _, rpcErr := server.handleDebugBundlerAddUserOps(addUserOpsParams(t, testUserOp(0xa1, 0))); rpcErr == nil {
+		t.Fatalf("addUserOps accepted a userOp while draining")
+	}
+	if size := server.mempools[entryPoint].Size(); size != 0 {
+		t.Fatalf("mempool size = %d after draining add, want 0", size)
+	}
+}
+
+func TestShutdownKeepsProcessorsDraining(t *testing.T) {
+	server := newDebugTestServer(mempool.Limits{})
+	server.server = &http.Server{}
+	entryPoint := types.EntryPointV07Address.Hex()
+
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	// Neither the admin API nor the debug API can resume a processor
+	if _, rpcErr := server.handleAdminSetState(nil, processor.StateActive); rpcErr == nil {
+		t.Fatalf("admin_resume succeeded during shutdown")
+	}
+	if _, rpcErr := server.handleDebugPause(nil); rpcErr == nil {
+		t.Fatalf("debug_bundler_pause succeeded during shutdown")
+	}
+	if state := server.processors[entryPoint].State(); state != processor.StateDraining {
+		t.Fatalf("processor state = %s during shutdown, want draining", state)
+	}
+
+	// The shutdown itself rejects userOps, even if a processor isn't draining
+	server.processors[entryPoint].Resume()
+	if _, rpcErr := server.handleDebugBundlerAddUserOps(addUserOpsParams(t, testUserOp(0xa1, 0))); rpcErr == nil {
+		t.Fatalf("addUserOps accepted a userOp during shutdown")
+	}
+}
+
+func TestShutdownWaitsForBundlesInParallel(t *testing.T) {
+	// Each processor only finishes waiting once every processor is waiting
+	var waiting sync.WaitGroup
+	entryPoints := []string{types.EntryPointV06Address.Hex(), types.EntryPointV07Address.Hex(), types.EntryPointV08Address.Hex()}
+	server := &RPCServer{server: &http.Server{}, processors: make(map[string]processor.Processor)}
+	for _, entryPoint := range entryPoints {
+		waiting.Add(1)
+		server.processors[entryPoint] = &stateProcessor{state: processor.StateActive, waiting: &waiting}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("Shutdown waited for processors one at a time")
+	}
+}
@@ -0,0 +1,95 @@
+package rpc
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// How often the certificate files are checked for rotation
+const certCheckInterval = 10 * time.Second
+
+// certReloader serves a TLS certificate from files, loading it again when the
+// files change (e.g. after renewal) so rotated certificates apply without a restart
+type certReloader struct {
+	certFile  string
+	keyFile   string
+	mutex     sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time // latest modification time of the loaded files
+	lastCheck time.Time
+}
+
+func newCertReloader(certFile string, keyFile string) (*certReloader, error) {
+	reloader := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// load reads the certificate and key files. Caller must hold the lock (or own the reloader).
+func (reloader *certReloader) load() error {
+	modTime, err := reloader.filesModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(reloader.certFile, reloader.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	reloader.cert = &cert
+	reloader.modTime = modTime
+	reloader.lastCheck = time.Now()
+	return nil
+}
+
+// filesModTime returns the latest modification time of the certificate and key files
+func (reloader *certReloader) filesModTime() (time.Time, error) {
+	var modTime time.Time
+	for _, file := range []string{reloader.certFile, reloader.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to read TLS file: %w", err)
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	return modTime, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate. A failed reload keeps
+// serving the previous certificate.
+func (reloader *certReloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	reloader.mutex.Lock()
+	defer reloader.mutex.Unlock()
+
+	if time.Since(reloader.lastCheck) < certCheckInterval {
+		return reloader.cert, nil
+	}
+	reloader.lastCheck = time.Now()
+
+	modTime, err := reloader.filesModTime()
+	if err != nil {
+		log.Printf("WARNING: %v, serving previous certificate", err)
+		return reloader.cert, nil
+	}
+	if modTime.Equal(reloader.modTime) {
+		return reloader.cert, nil
+	}
+	if err := reloader.load(); err != nil {
+		log.Printf("WARNING: %v, serving previous certificate", err)
+		return reloader.cert, nil
+	}
+
+	log.Printf("TLS certificate reloaded from %s", reloader.certFile)
+	return reloader.cert, nil
+}
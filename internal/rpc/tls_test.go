@@ -0,0 +1,122 @@
+package rpc
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a new self-signed certificate and its key, with the files'
+// modification time set to modTime
+func writeCert(t *testing.T, certFile string, keyFile string, modTime time.Time) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(modTime.UnixNano()),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), modTime)
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), modTime)
+	return der
+}
+
+func writeFile(t *testing.T, file string, data []byte, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		t.Fatalf("write %s: %v", file, err)
+	}
+	if err := os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatalf("set modification time of %s: %v", file, err)
+	}
+}
+
+// servedCert returns the certificate the reloader serves once the check interval passed
+func servedCert(t *testing.T, reloader *certReloader) []byte {
+	t.Helper()
+	reloader.mutex.Lock()
+	reloader.lastCheck = time.Now().Add(-certCheckInterval)
+	reloader.mutex.Unlock()
+
+	cert, err := reloader.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatalf("GetCertificate: %v", err)
+	}
+	return cert.Certificate[0]
+}
+
+func TestCertReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	start := time.Now().Add(-time.Hour)
+	first := writeCert(t, certFile, keyFile, start)
+
+	reloader, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("newCertReloader: %v", err)
+	}
+
+	// Rotated files are only checked once the interval passed
+	second := writeCert(t, certFile, keyFile, start.Add(time.Minute))
+	cert, err := reloader.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil || !bytes.Equal(cert.Certificate[0], first) {
+		t.Fatalf("certificate changed before the check interval (err: %v)", err)
+	}
+	if served := servedCert(t, reloader); !bytes.Equal(served, second) {
+		t.Fatalf("rotated certificate was not reloaded")
+	}
+
+	// A broken or missing file keeps the previous certificate
+	writeFile(t, keyFile, []byte("not a key"), start.Add(2*time.Minute))
+	if served := servedCert(t, reloader); !bytes.Equal(served, second) {
+		t.Fatalf("broken key file replaced the certificate")
+	}
+	if err := os.Remove(certFile); err != nil {
+		t.Fatalf("remove certificate: %v", err)
+	}
+	if served := servedCert(t, reloader); !bytes.Equal(served, second) {
+		t.Fatalf("missing certificate file replaced the certificate")
+	}
+
+	// Fixed files are picked up again
+	third := writeCert(t, certFile, keyFile, start.Add(3*time.Minute))
+	if served := servedCert(t, reloader); !bytes.Equal(served, third) {
+		t.Fatalf("fixed certificate was not reloaded")
+	}
+}
+
+func TestCertReloaderRequiresValidFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if _, err := newCertReloader(certFile, keyFile); err == nil {
+		t.Fatalf("newCertReloader accepted missing files")
+	}
+
+	writeCert(t, certFile, keyFile, time.Now())
+	writeFile(t, keyFile, []byte("not a key"), time.Now())
+	if _, err := newCertReloader(certFile, keyFile); err == nil {
+		t.Fatalf("newCertReloader accepted an invalid key")
+	}
+}
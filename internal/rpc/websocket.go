@@ -0,0 +1,543 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gorilla/websocket"
+	"github.com/vorpalengineering/gundler/internal/events"
+	"github.com/vorpalengineering/gundler/internal/history"
+	"github.com/vorpalengineering/gundler/internal/logging"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+const (
+	wsWriteTimeout       = 10 * time.Second
+	wsPongTimeout        = 60 * time.Second
+	wsPingInterval       = wsPongTimeout * 9 / 10
+	wsSubscriptionBuffer = 256
+	wsMaxSubscriptions   = 100 // per connection
+)
+
+// newUpgrader accepts WebSocket connections from browsers on allowedOrigins ("*"
+// allows any origin), or only from the server's own origin if none are set.
+// Requests without an Origin header come from non-browser clients and are accepted.
+func newUpgrader(allowedOrigins []string) *websocket.Upgrader {
+	upgrader := &websocket.Upgrader{
+		ReadBufferSize:  4096,
+		WriteBufferSize: 4096,
+	}
+	if len(allowedOrigins) == 0 {
+		// The upgrader checks for the same origin by default
+		return upgrader
+	}
+
+	upgrader.CheckOrigin = func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, allowed := range allowedOrigins {
+			if allowed == "*" || strings.EqualFold(allowed, origin) {
+				return true
+			}
+		}
+		return false
+	}
+	return upgrader
+}
+
+// wsRegistry tracks open WebSocket connections so they can be closed on shutdown,
+// as the HTTP server does not close hijacked connections
+type wsRegistry struct {
+	mutex       sync.Mutex
+	connections map[*wsConnection]struct{}
+	closed      bool
+}
+
+// add registers a connection, returning false once the registry is closed
+func (registry *wsRegistry) add(c *wsConnection) bool {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if registry.closed {
+		return false
+	}
+	if registry.connections == nil {
+		registry.connections = make(map[*wsConnection]struct{})
+	}
+	registry.connections[c] = struct{}{}
+	return true
+}
+
+func (registry *wsRegistry) remove(c *wsConnection) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	delete(registry.connections, c)
+}
+
+// closeAll closes every open connection and rejects new ones. Each connection's
+// read loop then ends its subscriptions.
+func (registry *wsRegistry) closeAll() int {
+	registry.mutex.Lock()
+	registry.closed = true
+	connections := make([]*wsConnection, 0, len(registry.connections))
+	for c := range registry.connections {
+		connections = append(connections, c)
+	}
+	registry.mutex.Unlock()
+
+	for _, c := range connections {
+		c.shutdown()
+	}
+	return len(connections)
+}
+
+// wsConnection serves JSON-RPC requests and eth_subscribe notifications over
+// a single WebSocket connection
+type wsConnection struct {
+	rpc           *RPCServer
+	conn          *websocket.Conn
+	writeMutex    sync.Mutex
+	subsMutex     sync.Mutex
+	subscriptions map[string]chan events.Event // subscriptionID => event channel
+	pending       []func()                     // subscription loops to start once the response is written
+}
+
+// subscription matches feed events and converts them to notification results
+type subscription struct {
+	filter  func(event events.Event) bool
+	result  func(event events.Event) any
+	initial func() any // current state, read once subscribed so no change is missed
+}
+
+func (rpc *RPCServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := rpc.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+
+	wsConn := &wsConnection{
+		rpc:           rpc,
+		conn:          conn,
+		subscriptions: make(map[string]chan events.Event),
+	}
+	if !rpc.wsConnections.add(wsConn) {
+		// Shutting down
+		wsConn.shutdown()
+		return
+	}
+	defer rpc.wsConnections.remove(wsConn)
+
+	wsConn.serve(r.Context())
+}
+
+func (c *wsConnection) serve(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer c.close()
+
+	// Drop connections that stop answering pings
+	if c.rpc.maxBodySize > 0 {
+		c.conn.SetReadLimit(c.rpc.maxBodySize)
+	}
+	c.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+	go c.pingLoop(ctx)
+
+	for {
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("WebSocket read error: %v", err)
+			}
+			return
+		}
+		c.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+
+		// JSON arrays are batch requests
+		var resp any
+		message = bytes.TrimSpace(message)
+		if len(message) > 0 && message[0] == '[' {
+			resp = c.rpc.handleBatchRequest(ctx, message, c.handleRequest)
+		} else {
+			var req types.RPCRequest
+			if err := json.Unmarshal(message, &req); err != nil {
+				resp = newErrorResponse(nil, -32700, "Parse error")
+			} else {
+				resp = c.handleRequest(ctx, &req)
+			}
+		}
+
+		if resp != nil {
+			if err := c.write(resp); err != nil {
+				return
+			}
+		}
+
+		// Start notifying new subscriptions only after their id was sent
+		c.startPending()
+	}
+}
+
+// handleRequest handles subscription methods and delegates everything else to the RPC server
+func (c *wsConnection) handleRequest(ctx context.Context, req *types.RPCRequest) *types.RPCResponse {
+	var result any
+	var err *types.RPCError
+
+	// Check the client's rate limits, other methods are checked by the RPC server
+	if req.Method == "eth_subscribe" || req.Method == "eth_unsubscribe" {
+		if limitErr := c.rpc.checkRateLimit(ctx, req.Method); limitErr != nil {
+			return &types.RPCResponse{JSONRPC: "2.0", Error: limitErr, ID: req.ID}
+		}
+	}
+
+	switch req.Method {
+	case "eth_subscribe":
+		result, err = c.handleSubscribe(req.Params)
+	case "eth_unsubscribe":
+		result, err = c.handleUnsubscribe(req.Params)
+	default:
+		return c.rpc.handleRequest(ctx, req)
+	}
+
+	if err != nil {
+		return newErrorResponse(req.ID, err.Code, err.Message)
+	}
+
+	return newResultResponse(req.ID, result)
+}
+
+func (c *wsConnection) handleSubscribe(params json.RawMessage) (string, *types.RPCError) {
+	// Parse topic and topic parameter
+	var rawParams []json.RawMessage
+	if err := json.Unmarshal(params, &rawParams); err != nil || len(rawParams) < 1 || len(rawParams) > 2 {
+		return "", &types.RPCError{
+			Code:    -32602,
+			Message: "Expected parameters: [topic, param?]",
+		}
+	}
+	var topic string
+	if err := json.Unmarshal(rawParams[0], &topic); err != nil {
+		return "", &types.RPCError{
+			Code:    -32602,
+			Message: "Invalid subscription topic",
+		}
+	}
+	var topicParam json.RawMessage
+	if len(rawParams) == 2 {
+		topicParam = rawParams[1]
+	}
+
+	// Cap subscriptions per connection, each holds a feed buffer and a goroutine
+	c.subsMutex.Lock()
+	subscriptionCount := len(c.subscriptions)
+	c.subsMutex.Unlock()
+	if subscriptionCount >= wsMaxSubscriptions {
+		return "", &types.RPCError{
+			Code:    types.ErrCodeLimitExceeded,
+			Message: fmt.Sprintf("Too many subscriptions: at most %d per connection", wsMaxSubscriptions),
+		}
+	}
+
+	// Build subscription for topic
+	var sub *subscription
+	var rpcErr *types.RPCError
+	switch topic {
+	case types.SubscriptionNewUserOperations:
+		sub, rpcErr = c.rpc.newUserOperationsSubscription(topicParam)
+	case types.SubscriptionUserOperationStatus:
+		sub, rpcErr = c.rpc.userOperationStatusSubscription(topicParam)
+	case types.SubscriptionNewBundles:
+		sub, rpcErr = c.rpc.newBundlesSubscription(topicParam)
+	default:
+		return "", &types.RPCError{
+			Code:    -32602,
+			Message: "Unsupported subscription topic: " + topic,
+		}
+	}
+	if rpcErr != nil {
+		return "", rpcErr
+	}
+
+	// Register subscription
+	id := newSubscriptionID()
+	ch := c.rpc.events.Subscribe(wsSubscriptionBuffer)
+	var initial any
+	if sub.initial != nil {
+		initial = sub.initial()
+	}
+	c.subsMutex.Lock()
+	c.subscriptions[id] = ch
+	c.pending = append(c.pending, func() { go c.notifyLoop(id, ch, sub, initial) })
+	c.subsMutex.Unlock()
+
+	return id, nil
+}
+
+func (c *wsConnection) handleUnsubscribe(params json.RawMessage) (bool, *types.RPCError) {
+	var rawParams []string
+	if err := json.Unmarshal(params, &rawParams); err != nil || len(rawParams) != 1 {
+		return false, &types.RPCError{
+			Code:    -32602,
+			Message: "Expected 1 parameter: [subscriptionId]",
+		}
+	}
+
+	c.subsMutex.Lock()
+	ch, exists := c.subscriptions[rawParams[0]]
+	delete(c.subscriptions, rawParams[0])
+	c.subsMutex.Unlock()
+
+	if exists {
+		c.rpc.events.Unsubscribe(ch)
+	}
+
+	return exists, nil
+}
+
+func (c *wsConnection) notifyLoop(id string, ch chan events.Event, sub *subscription, initial any) {
+	if initial != nil {
+		c.notify(id, initial)
+	}
+	for event := range ch {
+		if sub.filter(event) {
+			if !c.notify(id, sub.result(event)) {
+				return
+			}
+		}
+	}
+}
+
+// notify sends a subscription notification. A client too slow to receive it
+// would otherwise keep its subscriptions while the read loop waits, so write
+// errors close the connection and the read loop then ends its subscriptions.
+func (c *wsConnection) notify(id string, result any) bool {
+	err := c.write(&types.RPCNotification{
+		JSONRPC: "2.0",
+		Method:  "eth_subscription",
+		Params: types.RPCSubscriptionResult{
+			Subscription: id,
+			Result:       result,
+		},
+	})
+	if err != nil {
+		logging.Debugf("WebSocket notification failed, closing connection: %v", err)
+		c.conn.Close()
+		return false
+	}
+	return true
+}
+
+func (c *wsConnection) startPending() {
+	c.subsMutex.Lock()
+	pending := c.pending
+	c.pending = nil
+	c.subsMutex.Unlock()
+
+	for _, start := range pending {
+		start()
+	}
+}
+
+func (c *wsConnection) write(message any) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return c.conn.WriteJSON(message)
+}
+
+func (c *wsConnection) pingLoop(ctx context.Context) {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.writeMutex.Lock()
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+			c.writeMutex.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// shutdown tells the client the server is going away and closes the connection,
+// ending the read loop
+func (c *wsConnection) shutdown() {
+	message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	c.writeMutex.Lock()
+	c.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(wsWriteTimeout))
+	c.writeMutex.Unlock()
+
+	c.conn.Close()
+}
+
+// close ends all subscriptions and closes the connection
+func (c *wsConnection) close() {
+	c.subsMutex.Lock()
+	for id, ch := range c.subscriptions {
+		c.rpc.events.Unsubscribe(ch)
+		delete(c.subscriptions, id)
+	}
+	c.pending = nil
+	c.subsMutex.Unlock()
+
+	c.conn.Close()
+}
+
+func (rpc *RPCServer) newUserOperationsSubscription(param json.RawMessage) (*subscription, *types.RPCError) {
+	if param == nil {
+		return nil, &types.RPCError{
+			Code:    -32602,
+			Message: "Expected parameters: [\"newUserOperations\", entryPoint]",
+		}
+	}
+	entryPoint, rpcErr := rpc.parseSubscriptionEntryPoint(param)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	return &subscription{
+		filter: func(event events.Event) bool {
+			return event.Type == events.TypeUserOpAdded && event.EntryPoint == entryPoint
+		},
+		result: func(event events.Event) any {
+			return &types.NewUserOperation{
+				EntryPoint:    event.EntryPoint,
+				UserOpHash:    event.UserOpHash,
+				UserOperation: event.UserOp,
+			}
+		},
+	}, nil
+}
+
+func (rpc *RPCServer) userOperationStatusSubscription(param json.RawMessage) (*subscription, *types.RPCError) {
+	userOpHash, rpcErr := parseUserOpHashParam(append(append([]byte{'['}, param...), ']'))
+	if param == nil || rpcErr != nil {
+		return nil, &types.RPCError{
+			Code:    -32602,
+			Message: "Expected parameters: [\"userOperationStatus\", userOpHash]",
+		}
+	}
+
+	return &subscription{
+		filter: func(event events.Event) bool {
+			return event.Type == events.TypeUserOpStatus && event.UserOpHash == userOpHash
+		},
+		result: func(event events.Event) any {
+			return newUserOperationStatus(event.UserOpHash, event.EntryPoint, event.Status, event.TxHash)
+		},
+		initial: func() any {
+			// Return an untyped nil so unknown userOps send no initial status
+			if status := rpc.currentUserOperationStatus(userOpHash); status != nil {
+				return status
+			}
+			return nil
+		},
+	}, nil
+}
+
+func (rpc *RPCServer) newBundlesSubscription(param json.RawMessage) (*subscription, *types.RPCError) {
+	// Optionally restrict to one entry point
+	var entryPoint *common.Address
+	if param != nil {
+		address, rpcErr := rpc.parseSubscriptionEntryPoint(param)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		entryPoint = &address
+	}
+
+	return &subscription{
+		filter: func(event events.Event) bool {
+			return event.Type == events.TypeBundle && (entryPoint == nil || event.EntryPoint == *entryPoint)
+		},
+		result: func(event events.Event) any {
+			return &types.NewBundle{
+				EntryPoint:      event.EntryPoint,
+				TransactionHash: event.TxHash,
+				UserOpHashes:    event.UserOpHashes,
+			}
+		},
+	}, nil
+}
+
+func (rpc *RPCServer) parseSubscriptionEntryPoint(param json.RawMessage) (common.Address, *types.RPCError) {
+	normalizedAddress, rpcErr := rpc.parseEntryPointParam(param)
+	if rpcErr != nil {
+		return common.Address{}, rpcErr
+	}
+
+	return common.HexToAddress(normalizedAddress), nil
+}
+
+// currentUserOperationStatus returns the known status of a userOp, or nil if
+// the bundler has not seen it
+func (rpc *RPCServer) currentUserOperationStatus(userOpHash common.Hash) *types.UserOperationStatus {
+	// Pending userOps
+	for _, mempool := range rpc.mempools {
+		if _, exists := mempool.GetByHash(userOpHash); exists {
+			return newUserOperationStatus(userOpHash, mempool.EntryPoint, events.StatusPending, common.Hash{})
+		}
+	}
+
+	// Submitted userOps
+	for entryPoint, proc := range rpc.processors {
+		if _, txHash, exists := proc.SubmittedUserOp(userOpHash); exists {
+			return newUserOperationStatus(userOpHash, common.HexToAddress(entryPoint), events.StatusSubmitted, txHash)
+		}
+	}
+
+	// Finalized userOps
+	entry, exists := rpc.history.Get(userOpHash)
+	if !exists {
+		return nil
+	}
+	status := events.StatusFailed
+	switch {
+	case entry.Status == history.StatusSuccess:
+		status = events.StatusIncluded
+	case entry.Status == history.StatusDropped:
+		status = events.StatusDropped
+	}
+
+	return newUserOperationStatus(userOpHash, entry.EntryPoint, status, entry.TxHash)
+}
+
+func newUserOperationStatus(userOpHash common.Hash, entryPoint common.Address, status string, txHash common.Hash) *types.UserOperationStatus {
+	result := &types.UserOperationStatus{
+		UserOpHash: userOpHash,
+		EntryPoint: entryPoint,
+		Status:     status,
+	}
+	if txHash != (common.Hash{}) {
+		result.TransactionHash = &txHash
+	}
+	return result
+}
+
+func newSubscriptionID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hexutil.Encode(id)
+}
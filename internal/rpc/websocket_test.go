@@ -0,0 +1,171 @@
+package rpc
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/vorpalengineering/gundler/internal/events"
+)
+
+// newWebSocketServer serves rpc's WebSocket endpoint and returns its ws:// URL
+func newWebSocketServer(t *testing.T, rpc *RPCServer) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(rpc.handleWebSocket))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func newWebSocketTestServer(allowedOrigins []string) *RPCServer {
+	return &RPCServer{
+		upgrader: newUpgrader(allowedOrigins),
+		events:   events.NewFeed(),
+	}
+}
+
+// waitForConnections waits until the registry holds count connections
+func waitForConnections(t *testing.T, rpc *RPCServer, count int) *wsConnection {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rpc.wsConnections.mutex.Lock()
+		var last *wsConnection
+		for c := range rpc.wsConnections.connections {
+			last = c
+		}
+		size := len(rpc.wsConnections.connections)
+		rpc.wsConnections.mutex.Unlock()
+
+		if size == count {
+			return last
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("registry has %d connections, want %d", size, count)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebSocketOrigins(t *testing.T) {
+	tests := []struct {
+		name           string
+		allowedOrigins []string
+		origin         string // "self" is replaced with the server's own origin
+		ok             bool
+	}{
+		{"no origin", nil, "", true},
+		{"same origin", nil, "self", true},
+		{"cross origin by default", nil, "https://evil.example.com", false},
+		{"allowed origin", []string{"https://app.example.com"}, "https://app.example.com", true},
+		{"allowed origin is case-insensitive", []string{"https://app.example.com"}, "https://APP.example.com", true},
+		{"other origin", []string{"https://app.example.com"}, "https://evil.example.com", false},
+		{"any origin", []string{"*"}, "https://evil.example.com", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			url := newWebSocketServer(t, newWebSocketTestServer(test.allowedOrigins))
+
+			header := http.Header{}
+			switch test.origin {
+			case "":
+			case "self":
+				header.Set("Origin", "http"+strings.TrimPrefix(url, "ws"))
+			default:
+				header.Set("Origin", test.origin)
+			}
+			conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+			if conn != nil {
+				conn.Close()
+			}
+			if (err == nil) != test.ok {
+				t.Fatalf("dial err = %v, want connected %v", err, test.ok)
+			}
+			if !test.ok && (resp == nil || resp.StatusCode != http.StatusForbidden) {
+				t.Fatalf("rejected handshake response = %+v, want 403", resp)
+			}
+		})
+	}
+}
+
+func TestShutdownClosesWebSockets(t *testing.T) {
+	rpc := newWebSocketTestServer(nil)
+	url := newWebSocketServer(t, rpc)
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	waitForConnections(t, rpc, 1)
+
+	if closed := rpc.wsConnections.closeAll(); closed != 1 {
+		t.Fatalf("closeAll closed %d connections, want 1", closed)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("read err = %v, want going away close", err)
+	}
+	waitForConnections(t, rpc, 0)
+
+	// Connections opened after shutdown are closed right away
+	late, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial after shutdown: %v", err)
+	}
+	defer late.Close()
+	late.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := late.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("read err after shutdown = %v, want going away close", err)
+	}
+}
+
+func TestNotifyWriteErrorClosesConnection(t *testing.T) {
+	rpc := newWebSocketTestServer(nil)
+	url := newWebSocketServer(t, rpc)
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	if err := conn.WriteJSON(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "eth_subscribe", "params": []string{"newBundles"}}); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("read subscription id: %v", err)
+	}
+	serverConn := waitForConnections(t, rpc, 1)
+
+	// Writes to the client fail while its requests can still be read
+	tcpConn, ok := serverConn.conn.UnderlyingConn().(*net.TCPConn)
+	if !ok {
+		t.Fatalf("server connection is not TCP")
+	}
+	if err := tcpConn.CloseWrite(); err != nil {
+		t.Fatalf("close write: %v", err)
+	}
+
+	// The failed notification closes the connection and ends the read loop
+	rpc.events.Publish(events.Event{Type: events.TypeBundle})
+	waitForConnections(t, rpc, 0)
+
+	serverConn.subsMutex.Lock()
+	subscriptions := len(serverConn.subscriptions)
+	serverConn.subsMutex.Unlock()
+	if subscriptions != 0 {
+		t.Fatalf("connection kept %d subscriptions after closing", subscriptions)
+	}
+
+	// The client sees the connection closed rather than waiting for a message
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = conn.ReadMessage()
+	var netErr net.Error
+	if err == nil || errors.As(err, &netErr) && netErr.Timeout() {
+		t.Fatalf("read err = %v, want closed connection", err)
+	}
+}
@@ -0,0 +1,153 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+type AdminClientConfig struct {
+	URL   string // admin API address, an http(s) URL or unix:<socket path>
+	Token string // admin API bearer token
+}
+
+// AdminClient calls the admin API served on gundler's admin listener
+type AdminClient struct {
+	client *GundlerClient
+}
+
+func NewAdminClient(config AdminClientConfig) *AdminClient {
+	// Dial the Unix socket for every request
+	path, isUnix := strings.CutPrefix(config.URL, "unix:")
+	serverURL := config.URL
+	if isUnix {
+		serverURL = "http://localhost/"
+	}
+
+	client := NewGundlerClient(GundlerClientConfig{ServerURL: serverURL})
+	client.header.Set("Authorization", "Bearer "+config.Token)
+	if isUnix {
+		client.httpClient.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _ string, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", path)
+			},
+		}
+	}
+
+	return &AdminClient{client: client}
+}
+
+// Pause stops bundling for the given entry points (all if none), returns the new states
+func (ac *AdminClient) Pause(ctx context.Context, entryPoints ...common.Address) (map[common.Address]string, error) {
+	return ac.setState(ctx, "admin_pause", entryPoints)
+}
+
+// Resume bundles and accepts userOps again for the given entry points (all if none)
+func (ac *AdminClient) Resume(ctx context.Context, entryPoints ...common.Address) (map[common.Address]string, error) {
+	return ac.setState(ctx, "admin_resume", entryPoints)
+}
+
+// Drain stops accepting userOps for the given entry points (all if none) while bundling the pending ones
+func (ac *AdminClient) Drain(ctx context.Context, entryPoints ...common.Address) (map[common.Address]string, error) {
+	return ac.setState(ctx, "admin_drain", entryPoints)
+}
+
+func (ac *AdminClient) setState(ctx context.Context, method string, entryPoints []common.Address) (map[common.Address]string, error) {
+	var states map[common.Address]string
+	if err := ac.client.call(ctx, method, entryPointsParams(entryPoints), &states); err != nil {
+		return nil, err
+	}
+
+	return states, nil
+}
+
+// ProcessorStates returns the state of the given entry points' processors (all if none)
+func (ac *AdminClient) ProcessorStates(ctx context.Context, entryPoints ...common.Address) (map[common.Address]types.ProcessorState, error) {
+	var states map[common.Address]types.ProcessorState
+	if err := ac.client.call(ctx, "admin_processorStates", entryPointsParams(entryPoints), &states); err != nil {
+		return nil, err
+	}
+
+	return states, nil
+}
+
+func (ac *AdminClient) DumpMempool(ctx context.Context, entryPoint common.Address) ([]*types.UserOperation, error) {
+	var userOps []*types.UserOperation
+	if err := ac.client.call(ctx, "admin_dumpMempool", []any{entryPoint}, &userOps); err != nil {
+		return nil, err
+	}
+
+	return userOps, nil
+}
+
+// DropUserOps drops the given userOps from the entry point's mempool and
+// returns the number dropped
+func (ac *AdminClient) DropUserOps(ctx context.Context, entryPoint common.Address, userOpHashes ...common.Hash) (int, error) {
+	if len(userOpHashes) == 0 {
+		return 0, nil
+	}
+	return ac.dropUserOps(ctx, []any{entryPoint, userOpHashes})
+}
+
+// ClearMempool drops every userOp from the entry point's mempool and returns the number dropped
+func (ac *AdminClient) ClearMempool(ctx context.Context, entryPoint common.Address) (int, error) {
+	return ac.dropUserOps(ctx, []any{entryPoint})
+}
+
+func (ac *AdminClient) dropUserOps(ctx context.Context, params []any) (int, error) {
+	var result struct {
+		Dropped int `json:"dropped"`
+	}
+	if err := ac.client.call(ctx, "admin_dropUserOps", params, &result); err != nil {
+		return 0, err
+	}
+
+	return result.Dropped, nil
+}
+
+func (ac *AdminClient) SetReputation(ctx context.Context, entries []types.ReputationEntry, entryPoint common.Address) error {
+	return ac.client.call(ctx, "admin_setReputation", []any{entries, entryPoint}, nil)
+}
+
+func (ac *AdminClient) DumpReputation(ctx context.Context, entryPoint common.Address) ([]types.ReputationEntry, error) {
+	var entries []types.ReputationEntry
+	if err := ac.client.call(ctx, "admin_dumpReputation", []any{entryPoint}, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (ac *AdminClient) Keys(ctx context.Context) ([]types.BundlerKey, error) {
+	var keys []types.BundlerKey
+	if err := ac.client.call(ctx, "admin_keys", []any{}, &keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (ac *AdminClient) EnableKey(ctx context.Context, address common.Address) error {
+	return ac.client.call(ctx, "admin_enableKey", []any{address}, nil)
+}
+
+// DisableKey stops using a bundler key for new bundles, its pending transactions still confirm
+func (ac *AdminClient) DisableKey(ctx context.Context, address common.Address) error {
+	return ac.client.call(ctx, "admin_disableKey", []any{address}, nil)
+}
+
+// SendBundleNow bundles the given entry points (all if none) and returns the
+// transaction hash by entry point, nil if nothing was bundled
+func (ac *AdminClient) SendBundleNow(ctx context.Context, entryPoints ...common.Address) (map[common.Address]*common.Hash, error) {
+	var txHashes map[common.Address]*common.Hash
+	if err := ac.client.call(ctx, "admin_sendBundleNow", entryPointsParams(entryPoints), &txHashes); err != nil {
+		return nil, err
+	}
+
+	return txHashes, nil
+}
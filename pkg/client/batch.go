@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+// BatchElem is one call of a batch. Result is decoded into if not nil, Error is
+// set if the call failed, as a *types.RPCError for RPC errors.
+type BatchElem struct {
+	Method string
+	Params []any
+	Result any
+	Error  error
+}
+
+// BatchCall sends the calls as one JSON-RPC batch. It returns an error only if
+// the batch as a whole failed, each call's error is set on its element. The
+// batch is retried only if every method is idempotent.
+func (gc *GundlerClient) BatchCall(ctx context.Context, batch []BatchElem) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	// Create RPC requests
+	reqs := make([]types.RPCRequest, 0, len(batch))
+	retry := true
+	for _, elem := range batch {
+		params := elem.Params
+		if params == nil {
+			params = []any{}
+		}
+		paramsJSON, err := json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s params: %w", elem.Method, err)
+		}
+		reqs = append(reqs, types.RPCRequest{
+			JSONRPC: "2.0",
+			Method:  elem.Method,
+			Params:  paramsJSON,
+			ID:      gc.nextID.Add(1),
+		})
+		retry = retry && idempotentMethods[elem.Method]
+	}
+
+	// Marshal batch to JSON
+	reqJSON, err := json.Marshal(reqs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch request: %w", err)
+	}
+
+	// Send batch
+	status, body, err := gc.post(ctx, "batch", reqJSON, retry)
+	if err != nil {
+		return err
+	}
+
+	// Decode responses, a single response is an error for the whole batch (such as an oversized batch)
+	var responses []rpcResponse
+	if err := json.Unmarshal(body, &responses); err != nil {
+		var rpcResp rpcResponse
+		if json.Unmarshal(body, &rpcResp) == nil && rpcResp.Error != nil {
+			return rpcResp.Error
+		}
+		if status != http.StatusOK {
+			return fmt.Errorf("unexpected HTTP status: %d, body: %s", status, string(body))
+		}
+		return fmt.Errorf("failed to decode batch response: %w", err)
+	}
+
+	// Match responses to calls by ID, the server may answer in any order
+	responsesByID := make(map[string]*rpcResponse, len(responses))
+	for i := range responses {
+		responsesByID[string(responses[i].ID)] = &responses[i]
+	}
+	for i := range batch {
+		id, _ := json.Marshal(reqs[i].ID)
+		resp, exists := responsesByID[string(id)]
+		if !exists {
+			batch[i].Error = fmt.Errorf("no response for %s in batch", batch[i].Method)
+			continue
+		}
+		batch[i].Error = resp.decode(batch[i].Method, batch[i].Result)
+	}
+
+	return nil
+}
@@ -1,69 +1,166 @@
 package client
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"math/big"
 	"net/http"
-	"strings"
 	"sync/atomic"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/vorpalengineering/gundler/pkg/types"
 )
 
 type GundlerClientConfig struct {
-	ServerURL string
+	ServerURL       string
+	FailoverURLs    []string      // servers used in order while ServerURL is unhealthy
+	APIKey          string        // sent in the X-API-Key header if set
+	Timeout         time.Duration // HTTP request timeout, per attempt (default 30s)
+	MaxRetries      int           // retries of idempotent methods, negative for none (default 2)
+	RetryBackoff    time.Duration // first retry delay, jittered and doubled for each retry (default 250ms)
+	UnhealthyFor    time.Duration // how long a failing server is avoided (default 30s)
+	WebSocketURL    string        // subscription URL for WaitForReceipt, derived from ServerURL if empty
+	PollInterval    time.Duration // first WaitForReceipt poll interval, doubled after each poll (default 1s)
+	MaxPollInterval time.Duration // longest WaitForReceipt poll interval (default 10s)
+	ReceiptTimeout  time.Duration // longest WaitForReceipt wait, none if zero (the context deadline still applies)
 }
 
+// GundlerClient calls a Gundler (or any ERC-4337 bundler) JSON-RPC server. RPC
+// errors are returned as *types.RPCError, use errors.As to read the code and data.
+// Idempotent methods are retried, on a failover server if one is healthy.
 type GundlerClient struct {
-	httpClient *http.Client
-	serverURL  string
-	nextID     atomic.Uint64
+	httpClient      *http.Client
+	servers         []*server
+	header          http.Header
+	nextID          atomic.Uint64
+	maxRetries      int
+	retryBackoff    time.Duration
+	unhealthyFor    time.Duration
+	webSocketURL    string
+	pollInterval    time.Duration
+	maxPollInterval time.Duration
+	receiptTimeout  time.Duration
 }
 
 func NewGundlerClient(config GundlerClientConfig) *GundlerClient {
+	header := make(http.Header)
+	if config.APIKey != "" {
+		header.Set("X-API-Key", config.APIKey)
+	}
+
+	// Apply request defaults
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = defaultMaxRetries
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaultRetryBackoff
+	}
+	if config.UnhealthyFor <= 0 {
+		config.UnhealthyFor = defaultUnhealthyFor
+	}
+
+	// Apply receipt polling defaults
+	if config.WebSocketURL == "" {
+		config.WebSocketURL = webSocketURL(config.ServerURL)
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+	if config.MaxPollInterval < config.PollInterval {
+		config.MaxPollInterval = max(defaultMaxPollInterval, config.PollInterval)
+	}
+
+	servers := make([]*server, 0, 1+len(config.FailoverURLs))
+	for _, serverURL := range append([]string{config.ServerURL}, config.FailoverURLs...) {
+		servers = append(servers, &server{url: serverURL})
+	}
+
 	return &GundlerClient{
-		httpClient: &http.Client{},
-		serverURL:  config.ServerURL,
+		httpClient:      &http.Client{Timeout: config.Timeout},
+		servers:         servers,
+		header:          header,
+		maxRetries:      max(config.MaxRetries, 0),
+		retryBackoff:    config.RetryBackoff,
+		unhealthyFor:    config.UnhealthyFor,
+		webSocketURL:    config.WebSocketURL,
+		pollInterval:    config.PollInterval,
+		maxPollInterval: config.MaxPollInterval,
+		receiptTimeout:  config.ReceiptTimeout,
 	}
 }
 
 func (gc *GundlerClient) ChainID(ctx context.Context) (*big.Int, error) {
-	resp, err := gc.call(ctx, "eth_chainId", []interface{}{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to call eth_chainId: %w", err)
+	var chainID hexutil.Big
+	if err := gc.call(ctx, "eth_chainId", []any{}, &chainID); err != nil {
+		return nil, err
 	}
 
-	if resp.Error != nil {
-		return nil, fmt.Errorf("RPC error: code=%d, message=%s", resp.Error.Code, resp.Error.Message)
+	return chainID.ToInt(), nil
+}
+
+func (gc *GundlerClient) SupportedEntryPoints(ctx context.Context) ([]common.Address, error) {
+	var entryPoints []common.Address
+	if err := gc.call(ctx, "eth_supportedEntryPoints", []any{}, &entryPoints); err != nil {
+		return nil, err
 	}
 
-	// Parse hex string result
-	hexStr, ok := resp.Result.(string)
-	if !ok {
-		return nil, fmt.Errorf("unexpected result type: %T", resp.Result)
+	return entryPoints, nil
+}
+
+// SendUserOperation submits a userOp and returns its hash
+func (gc *GundlerClient) SendUserOperation(ctx context.Context, userOp *types.UserOperation, entryPoint common.Address) (common.Hash, error) {
+	var userOpHash common.Hash
+	if err := gc.call(ctx, "eth_sendUserOperation", []any{userOp, entryPoint}, &userOpHash); err != nil {
+		return common.Hash{}, err
 	}
 
-	// Remove 0x prefix if present
-	hexStr = strings.TrimPrefix(hexStr, "0x")
+	return userOpHash, nil
+}
 
-	// Parse hex string to big.Int
-	chainID := new(big.Int)
-	if _, ok := chainID.SetString(hexStr, 16); !ok {
-		return nil, fmt.Errorf("failed to parse chain ID hex string: %s", hexStr)
+// EstimateUserOperationGas estimates the gas limits of a userOp. Gundler does
+// not serve this method yet, it is available on bundlers that do.
+func (gc *GundlerClient) EstimateUserOperationGas(ctx context.Context, userOp *types.UserOperation, entryPoint common.Address) (*types.UserOperationGasEstimate, error) {
+	var estimate types.UserOperationGasEstimate
+	if err := gc.call(ctx, "eth_estimateUserOperationGas", []any{userOp, entryPoint}, &estimate); err != nil {
+		return nil, err
 	}
 
-	return chainID, nil
+	return &estimate, nil
 }
 
-func (gc *GundlerClient) call(ctx context.Context, method string, params interface{}) (*types.RPCResponse, error) {
+// GetUserOperationByHash returns a pending or bundled userOp, or nil if it is unknown
+func (gc *GundlerClient) GetUserOperationByHash(ctx context.Context, userOpHash common.Hash) (*types.UserOperationByHash, error) {
+	var userOp *types.UserOperationByHash
+	if err := gc.call(ctx, "eth_getUserOperationByHash", []any{userOpHash}, &userOp); err != nil {
+		return nil, err
+	}
+
+	return userOp, nil
+}
+
+// GetUserOperationReceipt returns the receipt of a bundled userOp, or nil if it
+// is pending or unknown
+func (gc *GundlerClient) GetUserOperationReceipt(ctx context.Context, userOpHash common.Hash) (*types.UserOperationReceipt, error) {
+	var receipt *types.UserOperationReceipt
+	if err := gc.call(ctx, "eth_getUserOperationReceipt", []any{userOpHash}, &receipt); err != nil {
+		return nil, err
+	}
+
+	return receipt, nil
+}
+
+// call sends a JSON-RPC request and decodes its result into result (if not nil)
+func (gc *GundlerClient) call(ctx context.Context, method string, params any, result any) error {
 	// Marshal params to JSON
 	paramsJSON, err := json.Marshal(params)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal params: %w", err)
+		return fmt.Errorf("failed to marshal %s params: %w", method, err)
 	}
 
 	// Create RPC request
@@ -77,34 +174,48 @@ func (gc *GundlerClient) call(ctx context.Context, method string, params interfa
 	// Marshal request to JSON
 	reqJSON, err := json.Marshal(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return fmt.Errorf("failed to marshal %s request: %w", method, err)
 	}
 
-	// Create HTTP request
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", gc.serverURL, bytes.NewReader(reqJSON))
+	// Send request, retrying idempotent methods
+	status, body, err := gc.post(ctx, method, reqJSON, idempotentMethods[method])
 	if err != nil {
-		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+		return err
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
 
-	// Send request
-	httpResp, err := gc.httpClient.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send HTTP request: %w", err)
+	// Decode response, the result is decoded once it is known to be no error
+	var rpcResp rpcResponse
+	if err := json.Unmarshal(body, &rpcResp); err != nil {
+		if status != http.StatusOK {
+			return fmt.Errorf("unexpected HTTP status: %d, body: %s", status, string(body))
+		}
+		return fmt.Errorf("failed to decode %s response: %w", method, err)
 	}
-	defer httpResp.Body.Close()
 
-	// Check HTTP status
-	if httpResp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(httpResp.Body)
-		return nil, fmt.Errorf("unexpected HTTP status: %d, body: %s", httpResp.StatusCode, string(body))
+	// Check RPC error (also sent with HTTP errors such as 401)
+	if rpcResp.Error == nil && status != http.StatusOK {
+		return fmt.Errorf("unexpected HTTP status: %d, body: %s", status, string(body))
 	}
+	return rpcResp.decode(method, result)
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *types.RPCError `json:"error"`
+	ID     json.RawMessage `json:"id"`
+}
 
-	// Decode response
-	var rpcResp types.RPCResponse
-	if err := json.NewDecoder(httpResp.Body).Decode(&rpcResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+// decode returns the response's RPC error or decodes its result into result (if not nil)
+func (resp *rpcResponse) decode(method string, result any) error {
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result == nil || len(resp.Result) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", method, err)
 	}
 
-	return &rpcResp, nil
+	return nil
 }
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+// recordedRequest is a request received by a methodServer
+type recordedRequest struct {
+	Method        string
+	Params        json.RawMessage
+	Authorization string
+}
+
+// methodServer answers each method with a canned JSON-RPC response body
+// (without jsonrpc and id) and HTTP status, and records the last request
+func methodServer(t *testing.T, status int, responses map[string]string) (*httptest.Server, *recordedRequest) {
+	t.Helper()
+	last := &recordedRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*last = recordedRequest{Method: req.Method, Params: req.Params, Authorization: r.Header.Get("Authorization")}
+
+		response, exists := responses[req.Method]
+		if !exists {
+			t.Errorf("unexpected method %s", req.Method)
+			response = `"error":{"code":-32601,"message":"Method not found"}`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(req.ID) + `,` + response + `}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, last
+}
+
+func TestTypedMethods(t *testing.T) {
+	userOpHash := common.HexToHash("0xabcd")
+	server, last := methodServer(t, http.StatusOK, map[string]string{
+		"eth_supportedEntryPoints":     `"result":["0x0000000071727De22E5E9d8BAf0edAc6f37da032"]`,
+		"eth_sendUserOperation":        `"result":"` + userOpHash.Hex() + `"`,
+		"eth_getUserOperationReceipt":  `"result":null`,
+		"debug_bundler_sendBundleNow":  `"result":null`,
+		"debug_bundler_dumpReputation": `"result":[{"address":"0x00000000000000000000000000000000000000aa","opsSeen":"0x3","opsIncluded":"0x1"}]`,
+	})
+	gc := NewGundlerClient(GundlerClientConfig{ServerURL: server.URL})
+	ctx := context.Background()
+
+	entryPoints, err := gc.SupportedEntryPoints(ctx)
+	if err != nil || len(entryPoints) != 1 || entryPoints[0] != types.EntryPointV07Address {
+		t.Fatalf("SupportedEntryPoints = %v (%v), want v0.7", entryPoints, err)
+	}
+
+	userOp := &types.UserOperation{Sender: common.Address{0xaa}, Nonce: big.NewInt(1)}
+	hash, err := gc.SendUserOperation(ctx, userOp, types.EntryPointV07Address)
+	if err != nil || hash != userOpHash {
+		t.Fatalf("SendUserOperation = %s (%v), want %s", hash.Hex(), err, userOpHash.Hex())
+	}
+	var params []json.RawMessage
+	if err := json.Unmarshal(last.Params, &params); err != nil || len(params) != 2 {
+		t.Fatalf("eth_sendUserOperation params = %s, want [userOp, entryPoint]", last.Params)
+	}
+	var entryPoint common.Address
+	if err := json.Unmarshal(params[1], &entryPoint); err != nil || entryPoint != types.EntryPointV07Address {
+		t.Fatalf("eth_sendUserOperation entry point = %s, want v0.7", params[1])
+	}
+
+	// Null results decode to nil
+	if receipt, err := gc.GetUserOperationReceipt(ctx, userOpHash); err != nil || receipt != nil {
+		t.Fatalf("GetUserOperationReceipt = %+v (%v), want nil for a pending userOp", receipt, err)
+	}
+	if txHash, err := gc.DebugBundlerSendBundleNow(ctx); err != nil || txHash != nil {
+		t.Fatalf("DebugBundlerSendBundleNow = %v (%v), want nil for an empty mempool", txHash, err)
+	}
+
+	entries, err := gc.DebugBundlerDumpReputation(ctx, types.EntryPointV07Address)
+	if err != nil || len(entries) != 1 || entries[0].Address != common.HexToAddress("0xaa") {
+		t.Fatalf("DebugBundlerDumpReputation = %+v (%v), want one entry", entries, err)
+	}
+}
+
+func TestRPCErrors(t *testing.T) {
+	server, _ := methodServer(t, http.StatusOK, map[string]string{
+		"eth_sendUserOperation": `"error":{"code":-32504,"message":"Entity 0xaa is banned","data":{"entity":"0xaa"}}`,
+	})
+	gc := NewGundlerClient(GundlerClientConfig{ServerURL: server.URL})
+
+	_, err := gc.SendUserOperation(context.Background(), &types.UserOperation{}, types.EntryPointV07Address)
+	var rpcErr *types.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != types.ErrCodeBannedOrThrottled {
+		t.Fatalf("SendUserOperation err = %v, want banned RPC error", err)
+	}
+	var data struct {
+		Entity string `json:"entity"`
+	}
+	if err := rpcErr.DecodeData(&data); err != nil || data.Entity != "0xaa" {
+		t.Fatalf("error data = %+v (%v), want the banned entity", data, err)
+	}
+}
+
+func TestAdminClient(t *testing.T) {
+	server, last := methodServer(t, http.StatusOK, map[string]string{
+		"admin_pause": `"result":{"0x0000000071727De22E5E9d8BAf0edAc6f37da032":"paused"}`,
+	})
+	ac := NewAdminClient(AdminClientConfig{URL: server.URL, Token: "admin-secret"})
+
+	states, err := ac.Pause(context.Background(), types.EntryPointV07Address)
+	if err != nil || states[types.EntryPointV07Address] != types.ProcessorStatePaused {
+		t.Fatalf("Pause = %v (%v), want v0.7 paused", states, err)
+	}
+	if last.Authorization != "Bearer admin-secret" {
+		t.Fatalf("Authorization = %q, want the bearer token", last.Authorization)
+	}
+	var params [][]common.Address
+	if err := json.Unmarshal(last.Params, &params); err != nil || len(params) != 1 || len(params[0]) != 1 || params[0][0] != types.EntryPointV07Address {
+		t.Fatalf("admin_pause params = %s, want [[entryPoint]]", last.Params)
+	}
+
+	// An invalid token is an RPC error sent with HTTP 401
+	unauthorized, _ := methodServer(t, http.StatusUnauthorized, map[string]string{
+		"admin_pause": `"error":{"code":-32001,"message":"Unauthorized: invalid admin token"}`,
+	})
+	ac = NewAdminClient(AdminClientConfig{URL: unauthorized.URL, Token: "wrong"})
+	_, err = ac.Pause(context.Background())
+	var rpcErr *types.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != types.ErrCodeUnauthorized {
+		t.Fatalf("Pause err = %v, want unauthorized RPC error", err)
+	}
+}
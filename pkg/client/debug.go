@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+// Debug methods, only served in DEBUG mode
+
+func (gc *GundlerClient) DebugMempools(ctx context.Context) ([]types.MempoolInfo, error) {
+	var mempools []types.MempoolInfo
+	if err := gc.call(ctx, "debug_mempools", []any{}, &mempools); err != nil {
+		return nil, err
+	}
+
+	return mempools, nil
+}
+
+// DebugPause pauses the processors of the given entry points (all if none), or
+// resumes them if all are paused. Returns whether they are now paused.
+func (gc *GundlerClient) DebugPause(ctx context.Context, entryPoints ...common.Address) (bool, error) {
+	var result struct {
+		Paused bool `json:"paused"`
+	}
+	if err := gc.call(ctx, "debug_pause", entryPointsParams(entryPoints), &result); err != nil {
+		return false, err
+	}
+
+	return result.Paused, nil
+}
+
+// DebugClear clears every mempool and returns the number of mempools cleared
+func (gc *GundlerClient) DebugClear(ctx context.Context) (int, error) {
+	var result struct {
+		Cleared int `json:"cleared"`
+	}
+	if err := gc.call(ctx, "debug_clear", []any{}, &result); err != nil {
+		return 0, err
+	}
+
+	return result.Cleared, nil
+}
+
+func (gc *GundlerClient) DebugBundlerClearState(ctx context.Context) error {
+	return gc.call(ctx, "debug_bundler_clearState", []any{}, nil)
+}
+
+func (gc *GundlerClient) DebugBundlerDumpMempool(ctx context.Context, entryPoint common.Address) ([]*types.UserOperation, error) {
+	var userOps []*types.UserOperation
+	if err := gc.call(ctx, "debug_bundler_dumpMempool", []any{entryPoint}, &userOps); err != nil {
+		return nil, err
+	}
+
+	return userOps, nil
+}
+
+// DebugBundlerSendBundleNow bundles every mempool and returns the first bundle
+// transaction hash, or nil if nothing was bundled
+func (gc *GundlerClient) DebugBundlerSendBundleNow(ctx context.Context) (*common.Hash, error) {
+	var txHash *common.Hash
+	if err := gc.call(ctx, "debug_bundler_sendBundleNow", []any{}, &txHash); err != nil {
+		return nil, err
+	}
+
+	return txHash, nil
+}
+
+// DebugBundlerSetBundlingMode sets the bundling mode (types.BundlingModeAuto or types.BundlingModeManual)
+func (gc *GundlerClient) DebugBundlerSetBundlingMode(ctx context.Context, mode string) error {
+	return gc.call(ctx, "debug_bundler_setBundlingMode", []any{mode}, nil)
+}
+
+func (gc *GundlerClient) DebugBundlerSetReputation(ctx context.Context, entries []types.ReputationEntry, entryPoint common.Address) error {
+	return gc.call(ctx, "debug_bundler_setReputation", []any{entries, entryPoint}, nil)
+}
+
+func (gc *GundlerClient) DebugBundlerDumpReputation(ctx context.Context, entryPoint common.Address) ([]types.ReputationEntry, error) {
+	var entries []types.ReputationEntry
+	if err := gc.call(ctx, "debug_bundler_dumpReputation", []any{entryPoint}, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// DebugBundlerAddUserOps adds userOps to the mempool without validation
+func (gc *GundlerClient) DebugBundlerAddUserOps(ctx context.Context, userOps []*types.UserOperation, entryPoint common.Address) error {
+	return gc.call(ctx, "debug_bundler_addUserOps", []any{userOps, entryPoint}, nil)
+}
+
+func (gc *GundlerClient) DebugBundlerGetStakeStatus(ctx context.Context, address common.Address, entryPoint common.Address) (*types.StakeStatus, error) {
+	var status types.StakeStatus
+	if err := gc.call(ctx, "debug_bundler_getStakeStatus", []any{address, entryPoint}, &status); err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// entryPointsParams builds the optional [entryPoints] params, empty for all entry points
+func entryPointsParams(entryPoints []common.Address) []any {
+	if len(entryPoints) == 0 {
+		return []any{}
+	}
+	return []any{entryPoints}
+}
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+// Default request settings
+const (
+	defaultTimeout      = 30 * time.Second
+	defaultMaxRetries   = 2
+	defaultRetryBackoff = 250 * time.Millisecond
+	defaultUnhealthyFor = 30 * time.Second
+)
+
+// Methods safe to send more than once, the only ones retried. Sending a userOp
+// or bundle twice is not, nor is debug_pause, which toggles.
+var idempotentMethods = map[string]bool{
+	"eth_chainId":                   true,
+	"eth_supportedEntryPoints":      true,
+	"eth_estimateUserOperationGas":  true,
+	"eth_getUserOperationByHash":    true,
+	"eth_getUserOperationReceipt":   true,
+	"debug_mempools":                true,
+	"debug_bundler_dumpMempool":     true,
+	"debug_bundler_setBundlingMode": true,
+	"debug_bundler_setReputation":   true,
+	"debug_bundler_dumpReputation":  true,
+	"debug_bundler_getStakeStatus":  true,
+	"admin_pause":                   true,
+	"admin_resume":                  true,
+	"admin_drain":                   true,
+	"admin_processorStates":         true,
+	"admin_dumpMempool":             true,
+	"admin_setReputation":           true,
+	"admin_dumpReputation":          true,
+	"admin_keys":                    true,
+	"admin_enableKey":               true,
+	"admin_disableKey":              true,
+}
+
+// server is a bundler URL, avoided until unhealthyUntil after a failure
+type server struct {
+	url            string
+	unhealthyUntil atomic.Int64 // unix nanoseconds
+}
+
+// pickServer returns the first healthy server in configured order, or the one
+// that has been unhealthy the longest if none is healthy
+func (gc *GundlerClient) pickServer() *server {
+	now := time.Now().UnixNano()
+	best := gc.servers[0]
+	for _, server := range gc.servers {
+		unhealthyUntil := server.unhealthyUntil.Load()
+		if unhealthyUntil <= now {
+			return server
+		}
+		if unhealthyUntil < best.unhealthyUntil.Load() {
+			best = server
+		}
+	}
+	return best
+}
+
+// post sends a JSON-RPC payload and returns the HTTP status and body. A server
+// that fails or rate limits is marked unhealthy and, if retry is set, the
+// payload is sent again after a jittered backoff to the next healthy server.
+// The last response is returned even if it failed so its RPC error is decoded.
+func (gc *GundlerClient) post(ctx context.Context, method string, payload []byte, retry bool) (int, []byte, error) {
+	attempts := 1
+	if retry {
+		attempts += gc.maxRetries
+	}
+
+	var status int
+	var body []byte
+	var err error
+	for attempt := range attempts {
+		// Back off before retrying
+		if attempt > 0 {
+			if waitErr := gc.backoff(ctx, attempt); waitErr != nil {
+				return 0, nil, waitErr
+			}
+		}
+
+		server := gc.pickServer()
+		status, body, err = gc.postOnce(ctx, server.url, method, payload)
+		if err == nil && !retryableResponse(status, body) {
+			server.unhealthyUntil.Store(0)
+			return status, body, nil
+		}
+		if ctx.Err() != nil {
+			if err == nil {
+				err = ctx.Err()
+			}
+			return 0, nil, err
+		}
+		server.unhealthyUntil.Store(time.Now().Add(gc.unhealthyFor).UnixNano())
+	}
+
+	return status, body, err
+}
+
+func (gc *GundlerClient) postOnce(ctx context.Context, serverURL string, method string, payload []byte) (int, []byte, error) {
+	// Create HTTP request
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", serverURL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	for name, values := range gc.header {
+		httpReq.Header[name] = values
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	// Send request
+	httpResp, err := gc.httpClient.Do(httpReq)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to send %s request: %w", method, err)
+	}
+	defer httpResp.Body.Close()
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read %s response: %w", method, err)
+	}
+
+	return httpResp.StatusCode, body, nil
+}
+
+// backoff waits before a retry: the retry backoff doubled for each earlier
+// retry, with half of it jittered so clients don't retry in step
+func (gc *GundlerClient) backoff(ctx context.Context, attempt int) error {
+	delay := gc.retryBackoff << (attempt - 1)
+	delay = delay/2 + rand.N(delay/2+1)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retryableResponse reports whether a response is a server failure or rate limit worth retrying
+func retryableResponse(status int, body []byte) bool {
+	if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests {
+		return true
+	}
+
+	// Rate limits are sent as RPC errors, batch responses are never retried for them
+	var rpcResp rpcResponse
+	if len(body) == 0 || body[0] != '{' || json.Unmarshal(body, &rpcResp) != nil {
+		return false
+	}
+	return rpcResp.Error != nil && rpcResp.Error.Code == types.ErrCodeLimitExceeded
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+// countingServer answers every request with respond and counts the requests
+func countingServer(t *testing.T, respond func(count int64, w http.ResponseWriter)) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var count atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respond(count.Add(1), w)
+	}))
+	t.Cleanup(server.Close)
+	return server, &count
+}
+
+func respondChainID(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+}
+
+func respondLimited(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32005,"message":"rate limit exceeded"}}`))
+}
+
+func respondUnavailable(w http.ResponseWriter) {
+	http.Error(w, "unavailable", http.StatusServiceUnavailable)
+}
+
+func TestFailover(t *testing.T) {
+	primary, primaryCount := countingServer(t, func(count int64, w http.ResponseWriter) { respondUnavailable(w) })
+	failover, failoverCount := countingServer(t, func(count int64, w http.ResponseWriter) { respondChainID(w) })
+	gc := NewGundlerClient(GundlerClientConfig{
+		ServerURL:    primary.URL,
+		FailoverURLs: []string{failover.URL},
+		RetryBackoff: time.Millisecond,
+	})
+
+	chainID, err := gc.ChainID(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get chain ID: %v", err)
+	}
+	if chainID.Int64() != 1 {
+		t.Fatalf("chain ID = %v, want 1", chainID)
+	}
+	if primaryCount.Load() != 1 || failoverCount.Load() != 1 {
+		t.Fatalf("requests = %d primary, %d failover, want 1 each", primaryCount.Load(), failoverCount.Load())
+	}
+
+	// The failed primary is avoided while it is unhealthy
+	if _, err := gc.ChainID(context.Background()); err != nil {
+		t.Fatalf("failed to get chain ID: %v", err)
+	}
+	if primaryCount.Load() != 1 || failoverCount.Load() != 2 {
+		t.Fatalf("requests = %d primary, %d failover, want 1 and 2", primaryCount.Load(), failoverCount.Load())
+	}
+}
+
+func TestFailoverOnConnectionError(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	failover, failoverCount := countingServer(t, func(count int64, w http.ResponseWriter) { respondChainID(w) })
+	gc := NewGundlerClient(GundlerClientConfig{
+		ServerURL:    closed.URL,
+		FailoverURLs: []string{failover.URL},
+		RetryBackoff: time.Millisecond,
+	})
+
+	if _, err := gc.ChainID(context.Background()); err != nil {
+		t.Fatalf("failed to get chain ID: %v", err)
+	}
+	if failoverCount.Load() != 1 {
+		t.Fatalf("failover requests = %d, want 1", failoverCount.Load())
+	}
+}
+
+func TestNoRetryForNonIdempotentMethods(t *testing.T) {
+	primary, primaryCount := countingServer(t, func(count int64, w http.ResponseWriter) { respondUnavailable(w) })
+	failover, failoverCount := countingServer(t, func(count int64, w http.ResponseWriter) { respondChainID(w) })
+	gc := NewGundlerClient(GundlerClientConfig{
+		ServerURL:    primary.URL,
+		FailoverURLs: []string{failover.URL},
+		RetryBackoff: time.Millisecond,
+	})
+
+	// A userOp is sent once, even to a failing server
+	_, err := gc.SendUserOperation(context.Background(), &types.UserOperation{}, types.EntryPointV07Address)
+	if err == nil {
+		t.Fatalf("sent userOp to an unavailable server")
+	}
+	if primaryCount.Load() != 1 || failoverCount.Load() != 0 {
+		t.Fatalf("requests = %d primary, %d failover, want 1 and 0", primaryCount.Load(), failoverCount.Load())
+	}
+}
+
+func TestRetryRateLimit(t *testing.T) {
+	server, count := countingServer(t, func(count int64, w http.ResponseWriter) {
+		if count == 1 {
+			respondLimited(w)
+			return
+		}
+		respondChainID(w)
+	})
+	gc := NewGundlerClient(GundlerClientConfig{
+		ServerURL:    server.URL,
+		RetryBackoff: time.Millisecond,
+	})
+
+	if _, err := gc.ChainID(context.Background()); err != nil {
+		t.Fatalf("failed to get chain ID: %v", err)
+	}
+	if count.Load() != 2 {
+		t.Fatalf("requests = %d, want 2", count.Load())
+	}
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	server, count := countingServer(t, func(count int64, w http.ResponseWriter) { respondLimited(w) })
+	gc := NewGundlerClient(GundlerClientConfig{
+		ServerURL:    server.URL,
+		MaxRetries:   3,
+		RetryBackoff: time.Millisecond,
+	})
+
+	// The last response's RPC error is returned
+	_, err := gc.GetUserOperationReceipt(context.Background(), common.Hash{})
+	var rpcErr *types.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != types.ErrCodeLimitExceeded {
+		t.Fatalf("error = %v, want rate limit RPC error", err)
+	}
+	if count.Load() != 4 {
+		t.Fatalf("requests = %d, want 4", count.Load())
+	}
+}
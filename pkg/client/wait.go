@@ -0,0 +1,212 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/websocket"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+// Default receipt polling intervals
+const (
+	defaultPollInterval    = time.Second
+	defaultMaxPollInterval = 10 * time.Second
+)
+
+// Checks, a poll interval apart, a userOp must be unknown on before it is reported dropped
+const droppedChecks = 2
+
+var (
+	// ErrUserOperationDropped is returned when a userOp will never be included:
+	// it was dropped, replaced, failed simulation or its bundle failed
+	ErrUserOperationDropped = errors.New("user operation dropped")
+
+	// ErrReceiptTimeout is returned when the receipt timeout or the context
+	// deadline passes before the userOp is included
+	ErrReceiptTimeout = errors.New("timed out waiting for user operation receipt")
+
+	errSubscriptionUnavailable = errors.New("userOperationStatus subscription unavailable")
+)
+
+// WaitForReceipt waits until a userOp is included and returns its receipt. It
+// follows a userOperationStatus subscription when the server accepts WebSocket
+// connections and polls for the receipt otherwise. Returns ErrUserOperationDropped
+// if the userOp will never be included and ErrReceiptTimeout on timeout.
+func (gc *GundlerClient) WaitForReceipt(ctx context.Context, userOpHash common.Hash) (*types.UserOperationReceipt, error) {
+	if gc.receiptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, gc.receiptTimeout)
+		defer cancel()
+	}
+
+	// Prefer the subscription, poll if the server does not serve one
+	receipt, err := gc.waitForReceiptSubscription(ctx, userOpHash)
+	if errors.Is(err, errSubscriptionUnavailable) {
+		receipt, err = gc.pollForReceipt(ctx, userOpHash)
+	}
+	if err != nil && ctx.Err() != nil {
+		return nil, contextError(ctx)
+	}
+
+	return receipt, err
+}
+
+// pollForReceipt checks for the receipt with an interval doubling up to the maximum poll interval
+func (gc *GundlerClient) pollForReceipt(ctx context.Context, userOpHash common.Hash) (*types.UserOperationReceipt, error) {
+	interval := gc.pollInterval
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, contextError(ctx)
+		case <-timer.C:
+		}
+
+		receipt, done, err := gc.checkReceipt(ctx, userOpHash)
+		if done || err != nil {
+			return receipt, err
+		}
+
+		timer.Reset(interval)
+		interval = min(interval*2, gc.maxPollInterval)
+	}
+}
+
+func (gc *GundlerClient) waitForReceiptSubscription(ctx context.Context, userOpHash common.Hash) (*types.UserOperationReceipt, error) {
+	if gc.webSocketURL == "" {
+		return nil, errSubscriptionUnavailable
+	}
+
+	// Connect, closing the connection once ctx is done to unblock reads
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, gc.webSocketURL, gc.header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errSubscriptionUnavailable, err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	// Subscribe to the userOp's status changes
+	params, err := json.Marshal([]any{types.SubscriptionUserOperationStatus, userOpHash})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal eth_subscribe params: %w", err)
+	}
+	req := types.RPCRequest{
+		JSONRPC: "2.0",
+		Method:  "eth_subscribe",
+		Params:  params,
+		ID:      gc.nextID.Add(1),
+	}
+	if err := conn.WriteJSON(req); err != nil {
+		return nil, fmt.Errorf("%w: %w", errSubscriptionUnavailable, err)
+	}
+
+	var resp struct {
+		Error *types.RPCError `json:"error"`
+	}
+	if err := conn.ReadJSON(&resp); err != nil {
+		return nil, fmt.Errorf("%w: %w", errSubscriptionUnavailable, err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("%w: %w", errSubscriptionUnavailable, resp.Error)
+	}
+
+	// Check once subscribed, an unknown userOp gets no status notification
+	receipt, done, err := gc.checkReceipt(ctx, userOpHash)
+	if done || err != nil {
+		return receipt, err
+	}
+
+	// Follow status notifications until a final status
+	for {
+		var notification struct {
+			Params struct {
+				Result *types.UserOperationStatus `json:"result"`
+			} `json:"params"`
+		}
+		if err := conn.ReadJSON(&notification); err != nil {
+			if ctx.Err() != nil {
+				return nil, contextError(ctx)
+			}
+			// Fall back to polling if the connection is lost
+			return nil, fmt.Errorf("%w: %w", errSubscriptionUnavailable, err)
+		}
+
+		status := notification.Params.Result
+		if status == nil || status.Status == types.UserOperationStatusPending || status.Status == types.UserOperationStatusSubmitted {
+			continue
+		}
+		receipt, done, err := gc.checkReceipt(ctx, userOpHash)
+		if done || err != nil {
+			return receipt, err
+		}
+	}
+}
+
+// checkReceipt returns the receipt of an included userOp, ErrUserOperationDropped
+// if the userOp is no longer known, or done false while it is still pending
+func (gc *GundlerClient) checkReceipt(ctx context.Context, userOpHash common.Hash) (*types.UserOperationReceipt, bool, error) {
+	for check := 1; ; check++ {
+		receipt, err := gc.GetUserOperationReceipt(ctx, userOpHash)
+		if err != nil {
+			return nil, true, err
+		}
+		if receipt != nil {
+			return receipt, true, nil
+		}
+
+		// Pending and submitted userOps are known, dropped and failed ones are not
+		userOp, err := gc.GetUserOperationByHash(ctx, userOpHash)
+		if err != nil {
+			return nil, true, err
+		}
+		if userOp != nil {
+			return nil, false, nil
+		}
+		if check >= droppedChecks {
+			return nil, true, fmt.Errorf("%w: %s", ErrUserOperationDropped, userOpHash.Hex())
+		}
+
+		// Check again before reporting the userOp dropped, in case it was unknown
+		// while moving between the mempool, a submitted bundle and the history
+		timer := time.NewTimer(gc.pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, true, contextError(ctx)
+		case <-timer.C:
+		}
+	}
+}
+
+func contextError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrReceiptTimeout, ctx.Err())
+	}
+	return ctx.Err()
+}
+
+// webSocketURL derives the WebSocket URL of an http(s) server URL, empty for other schemes
+func webSocketURL(serverURL string) string {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return ""
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	default:
+		return ""
+	}
+	return u.String()
+}
@@ -0,0 +1,195 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/websocket"
+)
+
+var testUserOpHash = common.HexToHash("0x1234")
+
+// receiptServer is a fake bundler that knows one pending userOp. Its receipt is
+// returned from the includedAfter-th receipt request, never if 0; the userOp is
+// unknown if dropped is set. With subscribe set, WebSocket clients are subscribed
+// and never notified.
+type receiptServer struct {
+	includedAfter int64
+	dropped       bool
+	subscribe     bool
+	receiptCalls  atomic.Int64
+	subscribed    chan struct{}
+}
+
+func (rs *receiptServer) start(t *testing.T) *GundlerClient {
+	t.Helper()
+	rs.subscribed = make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) {
+			rs.serveWebSocket(w, r)
+			return
+		}
+
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var result json.RawMessage
+		switch req.Method {
+		case "eth_getUserOperationReceipt":
+			result = json.RawMessage("null")
+			if calls := rs.receiptCalls.Add(1); rs.includedAfter > 0 && calls >= rs.includedAfter {
+				result = json.RawMessage(`{"userOpHash":"` + testUserOpHash.Hex() + `","success":true,"logs":[]}`)
+			}
+		case "eth_getUserOperationByHash":
+			result = json.RawMessage(`{"userOperation":null,"entryPoint":"0x0000000071727De22E5E9d8BAf0edAc6f37da032"}`)
+			if rs.dropped {
+				result = json.RawMessage("null")
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	t.Cleanup(server.Close)
+
+	return NewGundlerClient(GundlerClientConfig{
+		ServerURL:       server.URL,
+		PollInterval:    10 * time.Millisecond,
+		MaxPollInterval: 20 * time.Millisecond,
+	})
+}
+
+// serveWebSocket rejects the upgrade unless subscribe is set, then confirms the
+// subscription and waits for the client to close the connection
+func (rs *receiptServer) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !rs.subscribe {
+		http.Error(w, "WebSocket not supported", http.StatusBadRequest)
+		return
+	}
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var req struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if err := conn.ReadJSON(&req); err != nil {
+		return
+	}
+	conn.WriteJSON(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": "0x1"})
+	rs.subscribed <- struct{}{}
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func TestWaitForReceiptPolls(t *testing.T) {
+	rs := &receiptServer{includedAfter: 3}
+	gc := rs.start(t)
+
+	receipt, err := gc.WaitForReceipt(context.Background(), testUserOpHash)
+	if err != nil {
+		t.Fatalf("WaitForReceipt: %v", err)
+	}
+	if receipt.UserOpHash != testUserOpHash || !receipt.Success {
+		t.Fatalf("receipt = %+v, want the userOp's receipt", receipt)
+	}
+	if calls := rs.receiptCalls.Load(); calls != 3 {
+		t.Fatalf("polled %d times, want 3", calls)
+	}
+}
+
+func TestWaitForReceiptDropped(t *testing.T) {
+	gc := (&receiptServer{dropped: true}).start(t)
+	if _, err := gc.WaitForReceipt(context.Background(), testUserOpHash); !errors.Is(err, ErrUserOperationDropped) {
+		t.Fatalf("WaitForReceipt err = %v, want dropped", err)
+	}
+}
+
+func TestWaitForReceiptTimeout(t *testing.T) {
+	tests := []struct {
+		name           string
+		receiptTimeout time.Duration
+		ctxTimeout     time.Duration
+		subscribe      bool
+	}{
+		{"receipt timeout while polling", 50 * time.Millisecond, 0, false},
+		{"context deadline while polling", 0, 50 * time.Millisecond, false},
+		{"receipt timeout while subscribed", 50 * time.Millisecond, 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rs := &receiptServer{subscribe: test.subscribe}
+			gc := rs.start(t)
+			gc.receiptTimeout = test.receiptTimeout
+
+			ctx := context.Background()
+			if test.ctxTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, test.ctxTimeout)
+				defer cancel()
+			}
+
+			start := time.Now()
+			_, err := gc.WaitForReceipt(ctx, testUserOpHash)
+			if !errors.Is(err, ErrReceiptTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("WaitForReceipt err = %v, want receipt timeout", err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Fatalf("WaitForReceipt returned %v after the timeout", elapsed)
+			}
+			if test.subscribe && len(rs.subscribed) == 0 {
+				t.Fatalf("WaitForReceipt polled instead of subscribing")
+			}
+		})
+	}
+}
+
+func TestWaitForReceiptCancel(t *testing.T) {
+	for _, subscribe := range []bool{false, true} {
+		rs := &receiptServer{subscribe: subscribe}
+		gc := rs.start(t)
+		gc.receiptTimeout = time.Minute
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errs := make(chan error, 1)
+		go func() {
+			_, err := gc.WaitForReceipt(ctx, testUserOpHash)
+			errs <- err
+		}()
+
+		// Cancel once the client is waiting on the subscription or has polled
+		if subscribe {
+			<-rs.subscribed
+		} else {
+			for rs.receiptCalls.Load() == 0 {
+				time.Sleep(time.Millisecond)
+			}
+		}
+		cancel()
+
+		select {
+		case err := <-errs:
+			if !errors.Is(err, context.Canceled) || errors.Is(err, ErrReceiptTimeout) {
+				t.Fatalf("WaitForReceipt err = %v (subscribed: %v), want canceled", err, subscribe)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("WaitForReceipt didn't return after cancel (subscribed: %v)", subscribe)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package types
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Shared admin_* types for Gundler client and server
+
+// Processor states reported by admin_processorStates
+const (
+	ProcessorStateActive   = "active"
+	ProcessorStatePaused   = "paused"
+	ProcessorStateDraining = "draining"
+)
+
+// ProcessorState is an EntryPoint's entry in the admin_processorStates result.
+// A draining processor is done once its mempool is empty.
+type ProcessorState struct {
+	State       string `json:"state"`
+	Running     bool   `json:"running"`
+	MempoolSize int    `json:"mempoolSize"`
+}
+
+// BundlerKey is an element of the admin_keys result
+type BundlerKey struct {
+	Address    common.Address `json:"address"`
+	Pending    int            `json:"pending"`
+	Balance    *hexutil.Big   `json:"balance"`
+	LowBalance bool           `json:"lowBalance"`
+	Disabled   bool           `json:"disabled"`
+}
@@ -0,0 +1,94 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Shared debug_bundler_* (ERC-7769) types for Gundler client and server
+
+// Bundling modes
+const (
+	BundlingModeAuto   = "auto"
+	BundlingModeManual = "manual"
+)
+
+// MempoolInfo is an element of the debug_mempools result
+type MempoolInfo struct {
+	Label     string            `json:"label"`
+	Address   string            `json:"address"`
+	Size      int               `json:"size"`
+	Evictions map[string]uint64 `json:"evictions"`
+	UserOps   []*UserOperation  `json:"userops"`
+}
+
+type ReputationEntry struct {
+	Address     common.Address `json:"address"`
+	OpsSeen     hexutil.Uint64 `json:"opsSeen"`
+	OpsIncluded hexutil.Uint64 `json:"opsIncluded"`
+	Status      string         `json:"status,omitempty"`
+}
+
+func (entry *ReputationEntry) UnmarshalJSON(data []byte) error {
+	// Counters may be sent as hex strings or plain JSON numbers
+	type IntermediateReputationEntry struct {
+		Address     common.Address  `json:"address"`
+		OpsSeen     json.RawMessage `json:"opsSeen"`
+		OpsIncluded json.RawMessage `json:"opsIncluded"`
+		Status      string          `json:"status"`
+	}
+
+	var imd IntermediateReputationEntry
+	if err := json.Unmarshal(data, &imd); err != nil {
+		return err
+	}
+
+	opsSeen, err := parseQuantity(imd.OpsSeen)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling opsSeen: %w", err)
+	}
+	opsIncluded, err := parseQuantity(imd.OpsIncluded)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling opsIncluded: %w", err)
+	}
+
+	entry.Address = imd.Address
+	entry.OpsSeen = hexutil.Uint64(opsSeen)
+	entry.OpsIncluded = hexutil.Uint64(opsIncluded)
+	entry.Status = imd.Status
+
+	return nil
+}
+
+type StakeInfo struct {
+	Addr            common.Address `json:"addr"`
+	Stake           *hexutil.Big   `json:"stake"`
+	UnstakeDelaySec *hexutil.Big   `json:"unstakeDelaySec"`
+}
+
+// StakeStatus is the result of debug_bundler_getStakeStatus
+type StakeStatus struct {
+	StakeInfo StakeInfo `json:"stakeInfo"`
+	IsStaked  bool      `json:"isStaked"`
+}
+
+// parseQuantity parses a JSON number or hex string quantity (missing values are zero)
+func parseQuantity(raw json.RawMessage) (uint64, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return 0, nil
+	}
+
+	var str string
+	if err := json.Unmarshal(raw, &str); err != nil {
+		return strconv.ParseUint(string(raw), 10, 64)
+	}
+	if strings.HasPrefix(str, "0x") {
+		return strconv.ParseUint(strings.TrimPrefix(str, "0x"), 16, 64)
+	}
+	return strconv.ParseUint(str, 10, 64)
+}
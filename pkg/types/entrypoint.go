@@ -1,8 +1,12 @@
 package types
 
 import (
+	"bytes"
 	"fmt"
+	"math/big"
+	"strings"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 )
 
@@ -30,3 +34,250 @@ func ValidateEntryPointAddress(entryPointAddress common.Address) error {
 		return fmt.Errorf("invalid entryPoint address: %s", entryPointAddress.Hex())
 	}
 }
+
+// EntryPoint versions
+const (
+	EntryPointVersionV06 = "v0.6"
+	EntryPointVersionV07 = "v0.7"
+	EntryPointVersionV08 = "v0.8"
+)
+
+func GetEntryPoint(entryPointAddress common.Address) (*EntryPoint, error) {
+	switch entryPointAddress {
+	case EntryPointV06Address:
+		return &EntryPoint{Address: entryPointAddress, Version: EntryPointVersionV06}, nil
+	case EntryPointV07Address:
+		return &EntryPoint{Address: entryPointAddress, Version: EntryPointVersionV07}, nil
+	case EntryPointV08Address:
+		return &EntryPoint{Address: entryPointAddress, Version: EntryPointVersionV08}, nil
+	default:
+		return nil, fmt.Errorf("invalid entryPoint address: %s", entryPointAddress.Hex())
+	}
+}
+
+// Minimal EntryPoint ABIs (only the methods and events used by gundler)
+const entryPointV06ABIJSON = `[
+	{"type":"function","name":"getNonce","stateMutability":"view","inputs":[
+		{"name":"sender","type":"address"},
+		{"name":"key","type":"uint192"}
+	],"outputs":[
+		{"name":"nonce","type":"uint256"}
+	]},
+	{"type":"function","name":"getDepositInfo","stateMutability":"view","inputs":[
+		{"name":"account","type":"address"}
+	],"outputs":[
+		{"name":"info","type":"tuple","components":[
+			{"name":"deposit","type":"uint112"},
+			{"name":"staked","type":"bool"},
+			{"name":"stake","type":"uint112"},
+			{"name":"unstakeDelaySec","type":"uint32"},
+			{"name":"withdrawTime","type":"uint48"}
+		]}
+	]},
+	{"type":"function","name":"handleOps","stateMutability":"nonpayable","inputs":[
+		{"name":"ops","type":"tuple[]","components":[
+			{"name":"sender","type":"address"},
+			{"name":"nonce","type":"uint256"},
+			{"name":"initCode","type":"bytes"},
+			{"name":"callData","type":"bytes"},
+			{"name":"callGasLimit","type":"uint256"},
+			{"name":"verificationGasLimit","type":"uint256"},
+			{"name":"preVerificationGas","type":"uint256"},
+			{"name":"maxFeePerGas","type":"uint256"},
+			{"name":"maxPriorityFeePerGas","type":"uint256"},
+			{"name":"paymasterAndData","type":"bytes"},
+			{"name":"signature","type":"bytes"}
+		]},
+		{"name":"beneficiary","type":"address"}
+	],"outputs":[]}
+]`
+
+const entryPointV07ABIJSON = `[
+	{"type":"function","name":"getNonce","stateMutability":"view","inputs":[
+		{"name":"sender","type":"address"},
+		{"name":"key","type":"uint192"}
+	],"outputs":[
+		{"name":"nonce","type":"uint256"}
+	]},
+	{"type":"function","name":"getDepositInfo","stateMutability":"view","inputs":[
+		{"name":"account","type":"address"}
+	],"outputs":[
+		{"name":"info","type":"tuple","components":[
+			{"name":"deposit","type":"uint256"},
+			{"name":"staked","type":"bool"},
+			{"name":"stake","type":"uint112"},
+			{"name":"unstakeDelaySec","type":"uint32"},
+			{"name":"withdrawTime","type":"uint48"}
+		]}
+	]},
+	{"type":"function","name":"handleOps","stateMutability":"nonpayable","inputs":[
+		{"name":"ops","type":"tuple[]","components":[
+			{"name":"sender","type":"address"},
+			{"name":"nonce","type":"uint256"},
+			{"name":"initCode","type":"bytes"},
+			{"name":"callData","type":"bytes"},
+			{"name":"accountGasLimits","type":"bytes32"},
+			{"name":"preVerificationGas","type":"uint256"},
+			{"name":"gasFees","type":"bytes32"},
+			{"name":"paymasterAndData","type":"bytes"},
+			{"name":"signature","type":"bytes"}
+		]},
+		{"name":"beneficiary","type":"address"}
+	],"outputs":[]}
+]`
+
+// Events emitted by every EntryPoint version
+const entryPointEventsABIJSON = `[
+	{"type":"event","name":"UserOperationEvent","anonymous":false,"inputs":[
+		{"name":"userOpHash","type":"bytes32","indexed":true},
+		{"name":"sender","type":"address","indexed":true},
+		{"name":"paymaster","type":"address","indexed":true},
+		{"name":"nonce","type":"uint256","indexed":false},
+		{"name":"success","type":"bool","indexed":false},
+		{"name":"actualGasCost","type":"uint256","indexed":false},
+		{"name":"actualGasUsed","type":"uint256","indexed":false}
+	]},
+	{"type":"event","name":"UserOperationRevertReason","anonymous":false,"inputs":[
+		{"name":"userOpHash","type":"bytes32","indexed":true},
+		{"name":"sender","type":"address","indexed":true},
+		{"name":"nonce","type":"uint256","indexed":false},
+		{"name":"revertReason","type":"bytes","indexed":false}
+	]},
+	{"type":"event","name":"BeforeExecution","anonymous":false,"inputs":[]}
+]`
+
+// Errors handleOps reverts with when a userOp fails validation (FailedOpWithRevert is v0.7+)
+const entryPointErrorsABIJSON = `[
+	{"type":"error","name":"FailedOp","inputs":[
+		{"name":"opIndex","type":"uint256"},
+		{"name":"reason","type":"string"}
+	]},
+	{"type":"error","name":"FailedOpWithRevert","inputs":[
+		{"name":"opIndex","type":"uint256"},
+		{"name":"reason","type":"string"},
+		{"name":"inner","type":"bytes"}
+	]}
+]`
+
+var (
+	EntryPointV06ABI    = mustParseABI(entryPointV06ABIJSON)
+	EntryPointV07ABI    = mustParseABI(entryPointV07ABIJSON)
+	EntryPointEventsABI = mustParseABI(entryPointEventsABIJSON)
+	EntryPointErrorsABI = mustParseABI(entryPointErrorsABIJSON)
+)
+
+// ABI returns the EntryPoint ABI for the version (v0.8 shares the v0.7 interface)
+func (entryPoint *EntryPoint) ABI() abi.ABI {
+	if entryPoint.Version == EntryPointVersionV06 {
+		return EntryPointV06ABI
+	}
+	return EntryPointV07ABI
+}
+
+// PackHandleOps encodes an EntryPoint.handleOps() call for the userOps
+func (entryPoint *EntryPoint) PackHandleOps(userOps []*UserOperation, beneficiary common.Address) ([]byte, error) {
+	entryPointABI := entryPoint.ABI()
+
+	if entryPoint.Version == EntryPointVersionV06 {
+		ops := make([]UserOperationV06, 0, len(userOps))
+		for _, userOp := range userOps {
+			ops = append(ops, *userOp.PackV06())
+		}
+		return entryPointABI.Pack("handleOps", ops, beneficiary)
+	}
+
+	ops := make([]PackedUserOperation, 0, len(userOps))
+	for _, userOp := range userOps {
+		ops = append(ops, *userOp.Pack())
+	}
+	return entryPointABI.Pack("handleOps", ops, beneficiary)
+}
+
+// DepositInfo is the result of EntryPoint.getDepositInfo()
+type DepositInfo struct {
+	Deposit         *big.Int
+	Staked          bool
+	Stake           *big.Int
+	UnstakeDelaySec uint32
+	WithdrawTime    *big.Int
+}
+
+// PackGetDepositInfo encodes an EntryPoint.getDepositInfo() call for the account
+func (entryPoint *EntryPoint) PackGetDepositInfo(account common.Address) ([]byte, error) {
+	entryPointABI := entryPoint.ABI()
+	return entryPointABI.Pack("getDepositInfo", account)
+}
+
+// UnpackGetDepositInfo decodes the result of an EntryPoint.getDepositInfo() call
+func (entryPoint *EntryPoint) UnpackGetDepositInfo(data []byte) (*DepositInfo, error) {
+	entryPointABI := entryPoint.ABI()
+	values, err := entryPointABI.Unpack("getDepositInfo", data)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 1 {
+		return nil, fmt.Errorf("unexpected getDepositInfo result length: %d", len(values))
+	}
+
+	info := abi.ConvertType(values[0], new(DepositInfo)).(*DepositInfo)
+	return info, nil
+}
+
+// PackGetNonce encodes an EntryPoint.getNonce() call for the sender's nonce sequence key
+func (entryPoint *EntryPoint) PackGetNonce(sender common.Address, key *big.Int) ([]byte, error) {
+	entryPointABI := entryPoint.ABI()
+	return entryPointABI.Pack("getNonce", sender, key)
+}
+
+// UnpackGetNonce decodes the result of an EntryPoint.getNonce() call
+func (entryPoint *EntryPoint) UnpackGetNonce(data []byte) (*big.Int, error) {
+	entryPointABI := entryPoint.ABI()
+	values, err := entryPointABI.Unpack("getNonce", data)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 1 {
+		return nil, fmt.Errorf("unexpected getNonce result length: %d", len(values))
+	}
+
+	return abi.ConvertType(values[0], new(big.Int)).(*big.Int), nil
+}
+
+// FailedOp is a FailedOp or FailedOpWithRevert revert, naming the userOp in the bundle that failed
+type FailedOp struct {
+	OpIndex int
+	Reason  string
+}
+
+// UnpackFailedOp decodes handleOps revert data as a FailedOp or FailedOpWithRevert error
+func UnpackFailedOp(data []byte) (*FailedOp, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("revert data too short: %d bytes", len(data))
+	}
+
+	for _, name := range []string{"FailedOp", "FailedOpWithRevert"} {
+		abiError := EntryPointErrorsABI.Errors[name]
+		if !bytes.Equal(data[:4], abiError.ID[:4]) {
+			continue
+		}
+		values, err := abiError.Inputs.Unpack(data[4:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+		}
+		opIndex := values[0].(*big.Int)
+		if !opIndex.IsInt64() {
+			return nil, fmt.Errorf("invalid %s op index: %s", name, opIndex)
+		}
+		return &FailedOp{OpIndex: int(opIndex.Int64()), Reason: values[1].(string)}, nil
+	}
+
+	return nil, fmt.Errorf("revert data is not a FailedOp error: %x", data[:4])
+}
+
+func mustParseABI(abiJSON string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		panic(fmt.Sprintf("invalid EntryPoint ABI: %v", err))
+	}
+	return parsed
+}
@@ -0,0 +1,42 @@
+package types
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// UserOperationReceipt is the result of eth_getUserOperationReceipt
+type UserOperationReceipt struct {
+	UserOpHash    common.Hash       `json:"userOpHash"`
+	EntryPoint    common.Address    `json:"entryPoint"`
+	Sender        common.Address    `json:"sender"`
+	Nonce         *hexutil.Big      `json:"nonce"`
+	Paymaster     common.Address    `json:"paymaster"`
+	ActualGasCost *hexutil.Big      `json:"actualGasCost"`
+	ActualGasUsed *hexutil.Big      `json:"actualGasUsed"`
+	Success       bool              `json:"success"`
+	Reason        hexutil.Bytes     `json:"reason"`
+	Logs          []*ethtypes.Log   `json:"logs"`
+	Receipt       *ethtypes.Receipt `json:"receipt"`
+}
+
+// UserOperationByHash is the result of eth_getUserOperationByHash.
+// Block and transaction fields are null while the userOp is pending.
+type UserOperationByHash struct {
+	UserOperation   *UserOperation `json:"userOperation"`
+	EntryPoint      common.Address `json:"entryPoint"`
+	BlockNumber     *hexutil.Big   `json:"blockNumber"`
+	BlockHash       *common.Hash   `json:"blockHash"`
+	TransactionHash *common.Hash   `json:"transactionHash"`
+}
+
+// UserOperationGasEstimate is the result of eth_estimateUserOperationGas.
+// Paymaster gas limits are only returned for v0.7+ userOps with a paymaster.
+type UserOperationGasEstimate struct {
+	PreVerificationGas            *hexutil.Big `json:"preVerificationGas"`
+	VerificationGasLimit          *hexutil.Big `json:"verificationGasLimit"`
+	CallGasLimit                  *hexutil.Big `json:"callGasLimit"`
+	PaymasterVerificationGasLimit *hexutil.Big `json:"paymasterVerificationGasLimit,omitempty"`
+	PaymasterPostOpGasLimit       *hexutil.Big `json:"paymasterPostOpGasLimit,omitempty"`
+}
@@ -1,6 +1,9 @@
 package types
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+)
 
 // Shared JSON-RPC types for Gundler client and server
 
@@ -18,7 +21,59 @@ type RPCResponse struct {
 	ID      any       `json:"id"`
 }
 
+// ERC-4337 (ERC-7769) JSON-RPC error codes
+const (
+	ErrCodeInvalidParams          = -32602
+	ErrCodeRejectedByAccount      = -32500 // rejected by the EntryPoint's simulateValidation, account or factory
+	ErrCodeRejectedByPaymaster    = -32501
+	ErrCodeBannedOpcode           = -32502
+	ErrCodeExpiresShortly         = -32503 // validUntil/validAfter out of range
+	ErrCodeBannedOrThrottled      = -32504 // reputation of an entity
+	ErrCodeInsufficientStake      = -32505
+	ErrCodeUnsupportedAggregator  = -32506
+	ErrCodeInvalidSignature       = -32507
+	ErrCodePaymasterDepositTooLow = -32508
+	ErrCodeExecutionReverted      = -32521 // userOp call reverted during gas estimation
+	ErrCodeUnauthorized           = -32001 // missing or invalid API key or admin token
+	ErrCodeLimitExceeded          = -32005 // rate limited, retryAfter seconds in data
+	ErrCodeMethodNotFound         = -32601
+	ErrCodeInternal               = -32603
+	ErrCodeParse                  = -32700
+	ErrCodeInvalidRequest         = -32600
+)
+
+// RPCError is a JSON-RPC error. It implements error so clients can inspect the
+// code and data with errors.As.
 type RPCError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (err *RPCError) Error() string {
+	return fmt.Sprintf("RPC error %d: %s", err.Code, err.Message)
+}
+
+// DecodeData decodes the error data into v
+func (err *RPCError) DecodeData(v any) error {
+	if err.Data == nil {
+		return fmt.Errorf("RPC error %d has no data", err.Code)
+	}
+	data, marshalErr := json.Marshal(err.Data)
+	if marshalErr != nil {
+		return marshalErr
+	}
+	return json.Unmarshal(data, v)
+}
+
+// RPCNotification is a server-initiated message for an eth_subscribe subscription
+type RPCNotification struct {
+	JSONRPC string                `json:"jsonrpc"`
+	Method  string                `json:"method"`
+	Params  RPCSubscriptionResult `json:"params"`
+}
+
+type RPCSubscriptionResult struct {
+	Subscription string `json:"subscription"`
+	Result       any    `json:"result"`
 }
@@ -0,0 +1,43 @@
+package types
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// eth_subscribe topics
+const (
+	SubscriptionNewUserOperations   = "newUserOperations"
+	SubscriptionUserOperationStatus = "userOperationStatus"
+	SubscriptionNewBundles          = "newBundles"
+)
+
+// UserOp statuses reported by userOperationStatus subscriptions
+const (
+	UserOperationStatusPending   = "pending"
+	UserOperationStatusSubmitted = "submitted"
+	UserOperationStatusIncluded  = "included"
+	UserOperationStatusFailed    = "failed"
+	UserOperationStatusDropped   = "dropped"
+)
+
+// NewUserOperation is the result of a newUserOperations notification
+type NewUserOperation struct {
+	EntryPoint    common.Address `json:"entryPoint"`
+	UserOpHash    common.Hash    `json:"userOpHash"`
+	UserOperation *UserOperation `json:"userOperation"`
+}
+
+// UserOperationStatus is the result of a userOperationStatus notification
+type UserOperationStatus struct {
+	UserOpHash      common.Hash    `json:"userOpHash"`
+	EntryPoint      common.Address `json:"entryPoint"`
+	Status          string         `json:"status"`
+	TransactionHash *common.Hash   `json:"transactionHash,omitempty"`
+}
+
+// NewBundle is the result of a newBundles notification
+type NewBundle struct {
+	EntryPoint      common.Address `json:"entryPoint"`
+	TransactionHash common.Hash    `json:"transactionHash"`
+	UserOpHashes    []common.Hash  `json:"userOpHashes"`
+}
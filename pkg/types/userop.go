@@ -41,41 +41,132 @@ type PackedUserOperation struct {
 	Signature          []byte         `json:"signature"`
 }
 
+// UserOperationV06 is the unpacked userOp struct used by EntryPoint v0.6
+type UserOperationV06 struct {
+	Sender               common.Address `json:"sender"`
+	Nonce                *big.Int       `json:"nonce"`
+	InitCode             []byte         `json:"initCode"`
+	CallData             []byte         `json:"callData"`
+	CallGasLimit         *big.Int       `json:"callGasLimit"`
+	VerificationGasLimit *big.Int       `json:"verificationGasLimit"`
+	PreVerificationGas   *big.Int       `json:"preVerificationGas"`
+	MaxFeePerGas         *big.Int       `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *big.Int       `json:"maxPriorityFeePerGas"`
+	PaymasterAndData     []byte         `json:"paymasterAndData"`
+	Signature            []byte         `json:"signature"`
+}
+
 var PACKED_USEROP_TYPEHASH common.Hash = crypto.Keccak256Hash(
 	[]byte("PackedUserOperation(address sender,uint256 nonce,bytes initCode,bytes callData,bytes32 accountGasLimits,uint256 preVerificationGas,bytes32 gasFees,bytes paymasterAndData)"),
 )
 
+var EIP712_DOMAIN_TYPEHASH common.Hash = crypto.Keccak256Hash(
+	[]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"),
+)
+
+// Hash returns the userOpHash as computed by EntryPoint.getUserOpHash() for the entry point version
 func (userOp *UserOperation) Hash(entryPoint common.Address, chainID *big.Int) common.Hash {
+	switch entryPoint {
+	case EntryPointV06Address:
+		return userOp.hashV06(entryPoint, chainID)
+	case EntryPointV08Address:
+		return userOp.hashV08(entryPoint, chainID)
+	default:
+		return userOp.hashV07(entryPoint, chainID)
+	}
+}
+
+func (userOp *UserOperation) hashV06(entryPoint common.Address, chainID *big.Int) common.Hash {
+	// Pack user operation
+	packed := userOp.PackV06()
+
+	// keccak256(abi.encode(sender, nonce, keccak(initCode), keccak(callData), callGasLimit,
+	// verificationGasLimit, preVerificationGas, maxFeePerGas, maxPriorityFeePerGas, keccak(paymasterAndData)))
+	userOpHash := crypto.Keccak256Hash(
+		common.LeftPadBytes(packed.Sender[:], 32),
+		common.LeftPadBytes(bigIntBytes(packed.Nonce), 32),
+		crypto.Keccak256Hash(packed.InitCode).Bytes(),
+		crypto.Keccak256Hash(packed.CallData).Bytes(),
+		common.LeftPadBytes(bigIntBytes(packed.CallGasLimit), 32),
+		common.LeftPadBytes(bigIntBytes(packed.VerificationGasLimit), 32),
+		common.LeftPadBytes(bigIntBytes(packed.PreVerificationGas), 32),
+		common.LeftPadBytes(bigIntBytes(packed.MaxFeePerGas), 32),
+		common.LeftPadBytes(bigIntBytes(packed.MaxPriorityFeePerGas), 32),
+		crypto.Keccak256Hash(packed.PaymasterAndData).Bytes(),
+	)
+
+	// keccak256(abi.encode(userOpHash, entryPoint, chainId))
+	return crypto.Keccak256Hash(
+		userOpHash[:],
+		common.LeftPadBytes(entryPoint[:], 32),
+		common.LeftPadBytes(chainID.Bytes(), 32),
+	)
+}
+
+func (userOp *UserOperation) hashV07(entryPoint common.Address, chainID *big.Int) common.Hash {
 	// Pack user operation
 	packed := userOp.Pack()
 
-	// Create the hash: keccak256(abi.encode(packedUserOp, entryPoint, chainId))
+	// keccak256(abi.encode(sender, nonce, keccak(initCode), keccak(callData), accountGasLimits,
+	// preVerificationGas, gasFees, keccak(paymasterAndData)))
 	packedUserOpHash := crypto.Keccak256Hash(
-		PACKED_USEROP_TYPEHASH[:],
 		common.LeftPadBytes(packed.Sender[:], 32),
-		common.LeftPadBytes(packed.Nonce.Bytes(), 32),
+		common.LeftPadBytes(bigIntBytes(packed.Nonce), 32),
 		crypto.Keccak256Hash(packed.InitCode).Bytes(),
 		crypto.Keccak256Hash(packed.CallData).Bytes(),
 		packed.AccountGasLimits[:],
-		common.LeftPadBytes(packed.PreVerificationGas.Bytes(), 32),
+		common.LeftPadBytes(bigIntBytes(packed.PreVerificationGas), 32),
 		packed.GasFees[:],
 		crypto.Keccak256Hash(packed.PaymasterAndData).Bytes(),
 	)
 
-	finalHash := crypto.Keccak256Hash(
-		common.LeftPadBytes(packedUserOpHash[:], 32),
+	// keccak256(abi.encode(packedUserOpHash, entryPoint, chainId))
+	return crypto.Keccak256Hash(
+		packedUserOpHash[:],
 		common.LeftPadBytes(entryPoint[:], 32),
 		common.LeftPadBytes(chainID.Bytes(), 32),
 	)
+}
+
+func (userOp *UserOperation) hashV08(entryPoint common.Address, chainID *big.Int) common.Hash {
+	// Pack user operation
+	packed := userOp.Pack()
+
+	// EIP-712 struct hash of the packed user operation
+	structHash := crypto.Keccak256Hash(
+		PACKED_USEROP_TYPEHASH[:],
+		common.LeftPadBytes(packed.Sender[:], 32),
+		common.LeftPadBytes(bigIntBytes(packed.Nonce), 32),
+		crypto.Keccak256Hash(packed.InitCode).Bytes(),
+		crypto.Keccak256Hash(packed.CallData).Bytes(),
+		packed.AccountGasLimits[:],
+		common.LeftPadBytes(bigIntBytes(packed.PreVerificationGas), 32),
+		packed.GasFees[:],
+		crypto.Keccak256Hash(packed.PaymasterAndData).Bytes(),
+	)
 
-	return finalHash
+	// EIP-712 domain: name "ERC4337", version "1"
+	domainSeparator := crypto.Keccak256Hash(
+		EIP712_DOMAIN_TYPEHASH[:],
+		crypto.Keccak256([]byte("ERC4337")),
+		crypto.Keccak256([]byte("1")),
+		common.LeftPadBytes(chainID.Bytes(), 32),
+		common.LeftPadBytes(entryPoint[:], 32),
+	)
+
+	// keccak256("\x19\x01" || domainSeparator || structHash)
+	return crypto.Keccak256Hash(
+		[]byte{0x19, 0x01},
+		domainSeparator[:],
+		structHash[:],
+	)
 }
 
 func (userOp *UserOperation) Pack() *PackedUserOperation {
 	return &PackedUserOperation{
 		Sender:             userOp.Sender,
 		Nonce:              userOp.Nonce,
-		InitCode:           append(userOp.Factory[:], userOp.FactoryData...),
+		InitCode:           packInitCode(userOp.Factory, userOp.FactoryData),
 		CallData:           userOp.CallData,
 		AccountGasLimits:   packAccountGasLimits(userOp.VerificationGasLimit, userOp.CallGasLimit),
 		PreVerificationGas: userOp.PreVerificationGas,
@@ -90,6 +181,46 @@ func (userOp *UserOperation) Pack() *PackedUserOperation {
 	}
 }
 
+// TotalGasLimit returns the maximum gas the userOp can use (all gas limits combined)
+func (userOp *UserOperation) TotalGasLimit() *big.Int {
+	total := new(big.Int)
+	for _, gasLimit := range []*big.Int{
+		userOp.PreVerificationGas,
+		userOp.VerificationGasLimit,
+		userOp.CallGasLimit,
+		userOp.PaymasterVerificationGasLimit,
+		userOp.PaymasterPostOpGasLimit,
+	} {
+		if gasLimit != nil {
+			total.Add(total, gasLimit)
+		}
+	}
+	return total
+}
+
+func (userOp *UserOperation) PackV06() *UserOperationV06 {
+	// v0.6 paymasterAndData has no paymaster gas limits
+	paymasterAndData := []byte{}
+	if userOp.Paymaster != (common.Address{}) {
+		paymasterAndData = append(paymasterAndData, userOp.Paymaster.Bytes()...)
+		paymasterAndData = append(paymasterAndData, userOp.PaymasterData...)
+	}
+
+	return &UserOperationV06{
+		Sender:               userOp.Sender,
+		Nonce:                userOp.Nonce,
+		InitCode:             packInitCode(userOp.Factory, userOp.FactoryData),
+		CallData:             userOp.CallData,
+		CallGasLimit:         userOp.CallGasLimit,
+		VerificationGasLimit: userOp.VerificationGasLimit,
+		PreVerificationGas:   userOp.PreVerificationGas,
+		MaxFeePerGas:         userOp.MaxFeePerGas,
+		MaxPriorityFeePerGas: userOp.MaxPriorityFeePerGas,
+		PaymasterAndData:     paymasterAndData,
+		Signature:            userOp.Signature,
+	}
+}
+
 func (userOp *UserOperation) UnmarshalJSON(data []byte) error {
 	// Intermediate struct with string fields
 	type IntermediateUserOperation struct {
@@ -147,6 +278,22 @@ func (userOp *UserOperation) UnmarshalJSON(data []byte) error {
 		return fmt.Errorf("error unmarshalling maxPriorityFeePerGas")
 	}
 
+	// Paymaster gas limits are only present when a paymaster is set
+	userOp.PaymasterVerificationGasLimit = nil
+	if imd.PaymasterVerificationGasLimit != "" {
+		userOp.PaymasterVerificationGasLimit, ok = new(big.Int).SetString(strings.TrimPrefix(imd.PaymasterVerificationGasLimit, "0x"), 16)
+		if !ok {
+			return fmt.Errorf("error unmarshalling paymasterVerificationGasLimit")
+		}
+	}
+	userOp.PaymasterPostOpGasLimit = nil
+	if imd.PaymasterPostOpGasLimit != "" {
+		userOp.PaymasterPostOpGasLimit, ok = new(big.Int).SetString(strings.TrimPrefix(imd.PaymasterPostOpGasLimit, "0x"), 16)
+		if !ok {
+			return fmt.Errorf("error unmarshalling paymasterPostOpGasLimit")
+		}
+	}
+
 	// Parse byte arrays
 	var err error
 	userOp.FactoryData, err = hexutil.Decode(imd.FactoryData)
@@ -169,6 +316,68 @@ func (userOp *UserOperation) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+func (userOp *UserOperation) MarshalJSON() ([]byte, error) {
+	// Encode quantities and byte arrays as hex strings (inverse of UnmarshalJSON)
+	type HexUserOperation struct {
+		Sender                        common.Address `json:"sender"`
+		Nonce                         *hexutil.Big   `json:"nonce"`
+		Factory                       common.Address `json:"factory"`
+		FactoryData                   hexutil.Bytes  `json:"factoryData"`
+		CallData                      hexutil.Bytes  `json:"callData"`
+		CallGasLimit                  *hexutil.Big   `json:"callGasLimit"`
+		VerificationGasLimit          *hexutil.Big   `json:"verificationGasLimit"`
+		PreVerificationGas            *hexutil.Big   `json:"preVerificationGas"`
+		MaxFeePerGas                  *hexutil.Big   `json:"maxFeePerGas"`
+		MaxPriorityFeePerGas          *hexutil.Big   `json:"maxPriorityFeePerGas"`
+		Paymaster                     common.Address `json:"paymaster"`
+		PaymasterVerificationGasLimit *hexutil.Big   `json:"paymasterVerificationGasLimit,omitempty"`
+		PaymasterPostOpGasLimit       *hexutil.Big   `json:"paymasterPostOpGasLimit,omitempty"`
+		PaymasterData                 hexutil.Bytes  `json:"paymasterData"`
+		Signature                     hexutil.Bytes  `json:"signature"`
+	}
+
+	return json.Marshal(&HexUserOperation{
+		Sender:                        userOp.Sender,
+		Nonce:                         (*hexutil.Big)(userOp.Nonce),
+		Factory:                       userOp.Factory,
+		FactoryData:                   nonNilBytes(userOp.FactoryData),
+		CallData:                      nonNilBytes(userOp.CallData),
+		CallGasLimit:                  (*hexutil.Big)(userOp.CallGasLimit),
+		VerificationGasLimit:          (*hexutil.Big)(userOp.VerificationGasLimit),
+		PreVerificationGas:            (*hexutil.Big)(userOp.PreVerificationGas),
+		MaxFeePerGas:                  (*hexutil.Big)(userOp.MaxFeePerGas),
+		MaxPriorityFeePerGas:          (*hexutil.Big)(userOp.MaxPriorityFeePerGas),
+		Paymaster:                     userOp.Paymaster,
+		PaymasterVerificationGasLimit: (*hexutil.Big)(userOp.PaymasterVerificationGasLimit),
+		PaymasterPostOpGasLimit:       (*hexutil.Big)(userOp.PaymasterPostOpGasLimit),
+		PaymasterData:                 nonNilBytes(userOp.PaymasterData),
+		Signature:                     nonNilBytes(userOp.Signature),
+	})
+}
+
+// nonNilBytes makes empty byte arrays encode as "0x" rather than null
+func nonNilBytes(data []byte) hexutil.Bytes {
+	if data == nil {
+		return hexutil.Bytes{}
+	}
+	return data
+}
+
+func packInitCode(factory common.Address, factoryData []byte) []byte {
+	// Return empty byte array if no factory address
+	if (factory == common.Address{}) {
+		return []byte{}
+	}
+
+	// Bytes 0-19: Factory address (20 bytes)
+	// Bytes 20+: FactoryData (variable)
+	initCode := make([]byte, 0, 20+len(factoryData))
+	initCode = append(initCode, factory.Bytes()...)
+	initCode = append(initCode, factoryData...)
+
+	return initCode
+}
+
 func packAccountGasLimits(verificationGasLimit *big.Int, callGasLimit *big.Int) [32]byte {
 	var accountGasLimits [32]byte
 
@@ -212,18 +421,26 @@ func packPaymasterAndData(
 	// Bytes 20-35: PaymasterVerificationGasLimit (16 bytes)
 	// Bytes 36-51: PaymasterPostOpGasLimit (16 bytes)
 	// Bytes 52+: PaymasterData (variable)
-	var paymasterAndData []byte
+	paymasterAndData := make([]byte, 52, 52+len(paymasterData))
 
 	// Convert to bytes for concatenation
 	paymasterBytes := paymaster.Bytes()
-	paymasterVerificationGasLimitBytes := paymasterVerificationGasLimit.Bytes()
-	paymasterPostOpGasLimitBytes := paymasterPostOpGasLimit.Bytes()
+	paymasterVerificationGasLimitBytes := bigIntBytes(paymasterVerificationGasLimit)
+	paymasterPostOpGasLimitBytes := bigIntBytes(paymasterPostOpGasLimit)
 
 	// Copy into byte array (right-aligned)
 	copy(paymasterAndData[20-len(paymasterBytes):20], paymasterBytes)
-	copy(paymasterAndData[35-len(paymasterVerificationGasLimitBytes):35], paymasterVerificationGasLimitBytes)
-	copy(paymasterAndData[51-len(paymasterPostOpGasLimitBytes):51], paymasterPostOpGasLimitBytes)
+	copy(paymasterAndData[36-len(paymasterVerificationGasLimitBytes):36], paymasterVerificationGasLimitBytes)
+	copy(paymasterAndData[52-len(paymasterPostOpGasLimitBytes):52], paymasterPostOpGasLimitBytes)
 	paymasterAndData = append(paymasterAndData, paymasterData...)
 
 	return paymasterAndData
 }
+
+// bigIntBytes returns the big-endian bytes of value, treating nil as zero
+func bigIntBytes(value *big.Int) []byte {
+	if value == nil {
+		return []byte{}
+	}
+	return value.Bytes()
+}
@@ -0,0 +1,103 @@
+package types
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// testUserOp has a factory and a paymaster so every packed field is non-empty
+func testUserOp() *UserOperation {
+	return &UserOperation{
+		Sender:                        common.HexToAddress("0x1111111111111111111111111111111111111111"),
+		Nonce:                         big.NewInt(7),
+		Factory:                       common.HexToAddress("0x2222222222222222222222222222222222222222"),
+		FactoryData:                   hexutil.MustDecode("0xdeadbeef"),
+		CallData:                      hexutil.MustDecode("0xb61d27f6"),
+		CallGasLimit:                  big.NewInt(100000),
+		VerificationGasLimit:          big.NewInt(200000),
+		PreVerificationGas:            big.NewInt(50000),
+		MaxFeePerGas:                  big.NewInt(2000000000),
+		MaxPriorityFeePerGas:          big.NewInt(1000000000),
+		Paymaster:                     common.HexToAddress("0x3333333333333333333333333333333333333333"),
+		PaymasterVerificationGasLimit: big.NewInt(50000),
+		PaymasterPostOpGasLimit:       big.NewInt(30000),
+		PaymasterData:                 hexutil.MustDecode("0xcafe"),
+		Signature:                     hexutil.MustDecode("0x01"),
+	}
+}
+
+// testUserOpWithoutEntities has no factory or paymaster, so initCode and
+// paymasterAndData are empty
+func testUserOpWithoutEntities() *UserOperation {
+	userOp := testUserOp()
+	userOp.Factory = common.Address{}
+	userOp.FactoryData = nil
+	userOp.Paymaster = common.Address{}
+	userOp.PaymasterVerificationGasLimit = nil
+	userOp.PaymasterPostOpGasLimit = nil
+	userOp.PaymasterData = nil
+	return userOp
+}
+
+// The expected hashes are getUserOpHash on chain 1, computed as EntryPoint's
+// UserOperationLib encodes userOps: with go-ethereum's ABI encoder (v0.6, v0.7)
+// and EIP-712 typed data hasher (v0.8) from hand-packed fields rather than with
+// this package's packing
+func TestHash(t *testing.T) {
+	tests := []struct {
+		name       string
+		userOp     *UserOperation
+		entryPoint common.Address
+		want       string
+	}{
+		{"v0.6", testUserOp(), EntryPointV06Address, "0xbd52f8487a10eb769d13dbda26ab30a2ac80d6eade26c3d8fcbdf477ec660e42"},
+		{"v0.7", testUserOp(), EntryPointV07Address, "0xfd323ca68f48b13bf9cd56bd58f7cf6799d011fe914c6ef1e62c64f467f374b4"},
+		{"v0.8", testUserOp(), EntryPointV08Address, "0xeae6bb319a37a560094a42c324d2cc992bf5ac29d0339630ae44baf2aeb2ea8f"},
+		{"v0.6 without entities", testUserOpWithoutEntities(), EntryPointV06Address, "0xf4dae868a979250184b88122aa45e1e64c6d0b51cb89fbd54450c4cb98e2c401"},
+		{"v0.7 without entities", testUserOpWithoutEntities(), EntryPointV07Address, "0x75526d023827ba2c585024500c1735245e1659e1df9b8bebd77628c7223035ca"},
+		{"v0.8 without entities", testUserOpWithoutEntities(), EntryPointV08Address, "0xf657c98febf49d42919abbbe573d4dfd593cb0e469ce7bf65ab22cf873761c1c"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hash := test.userOp.Hash(test.entryPoint, big.NewInt(1))
+			if hash != common.HexToHash(test.want) {
+				t.Fatalf("hash = %s, want %s", hash.Hex(), test.want)
+			}
+		})
+	}
+}
+
+func TestHashIgnoresSignature(t *testing.T) {
+	userOp := testUserOp()
+	for _, entryPoint := range []common.Address{EntryPointV06Address, EntryPointV07Address, EntryPointV08Address} {
+		hash := userOp.Hash(entryPoint, big.NewInt(1))
+		signed := *userOp
+		signed.Signature = hexutil.MustDecode("0x02")
+		if signedHash := signed.Hash(entryPoint, big.NewInt(1)); signedHash != hash {
+			t.Fatalf("%s hash changed with the signature: %s != %s", entryPoint.Hex(), signedHash.Hex(), hash.Hex())
+		}
+	}
+}
+
+func TestPack(t *testing.T) {
+	// Without a factory initCode is empty, not a zero address
+	if initCode := testUserOpWithoutEntities().Pack().InitCode; len(initCode) != 0 {
+		t.Fatalf("initCode without factory = %x, want empty", initCode)
+	}
+
+	// paymasterAndData is the paymaster, then 16-byte verification and postOp gas limits, then paymasterData
+	packed := testUserOp().Pack()
+	want := "0x3333333333333333333333333333333333333333" +
+		"0000000000000000000000000000c350" +
+		"00000000000000000000000000007530" +
+		"cafe"
+	if got := hexutil.Encode(packed.PaymasterAndData); got != want {
+		t.Fatalf("paymasterAndData = %s, want %s", got, want)
+	}
+	if got := hexutil.Encode(packed.InitCode); got != "0x2222222222222222222222222222222222222222deadbeef" {
+		t.Fatalf("initCode = %s, want factory then factoryData", got)
+	}
+}
@@ -0,0 +1,271 @@
+package userop
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+// Node reads nonces, account code and fees from the chain, such as an *ethclient.Client
+type Node interface {
+	ethereum.ContractCaller
+	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*ethtypes.Header, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	ChainID(ctx context.Context) (*big.Int, error)
+}
+
+// GasEstimator estimates userOp gas limits, such as a *client.GundlerClient
+// connected to a bundler that serves eth_estimateUserOperationGas
+type GasEstimator interface {
+	EstimateUserOperationGas(ctx context.Context, userOp *types.UserOperation, entryPoint common.Address) (*types.UserOperationGasEstimate, error)
+}
+
+type BuilderConfig struct {
+	EntryPoint  common.Address
+	Node        Node
+	Estimator   GasEstimator   // fills unset gas limits, required unless userOps set them
+	Signer      Signer         // signs built userOps, they are left unsigned if nil
+	NonceKey    *big.Int       // nonce sequence key (default: 0)
+	Factory     common.Address // deploys senders without code, see SimpleAccount.FactoryData
+	FactoryData []byte
+}
+
+// Builder completes userOps for one entry point: nonce, initCode, fees, gas
+// limits and signature
+type Builder struct {
+	entryPoint  *types.EntryPoint
+	node        Node
+	estimator   GasEstimator
+	signer      Signer
+	nonceKey    *big.Int
+	factory     common.Address
+	factoryData []byte
+	chainID     *big.Int
+	chainMutex  sync.Mutex
+}
+
+func NewBuilder(config BuilderConfig) (*Builder, error) {
+	entryPoint, err := types.GetEntryPoint(config.EntryPoint)
+	if err != nil {
+		return nil, err
+	}
+	if config.Node == nil {
+		return nil, fmt.Errorf("builder requires a node")
+	}
+	nonceKey := config.NonceKey
+	if nonceKey == nil {
+		nonceKey = new(big.Int)
+	}
+
+	return &Builder{
+		entryPoint:  entryPoint,
+		node:        config.Node,
+		estimator:   config.Estimator,
+		signer:      config.Signer,
+		nonceKey:    nonceKey,
+		factory:     config.Factory,
+		factoryData: config.FactoryData,
+	}, nil
+}
+
+// Build returns a copy of the userOp, which needs at least a sender and
+// callData, with its unset nonce, initCode, fees and gas limits filled, signed
+// by the builder's signer
+func (builder *Builder) Build(ctx context.Context, userOp *types.UserOperation) (*types.UserOperation, error) {
+	built := *userOp
+
+	// Step 1: Read nonce for the nonce key
+	if built.Nonce == nil {
+		nonce, err := builder.getNonce(ctx, built.Sender)
+		if err != nil {
+			return nil, err
+		}
+		built.Nonce = nonce
+	}
+
+	// Step 2: Deploy the sender with the factory if it has no code yet
+	if built.Factory == (common.Address{}) && builder.factory != (common.Address{}) {
+		code, err := builder.node.CodeAt(ctx, built.Sender, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get code of sender %s: %w", built.Sender.Hex(), err)
+		}
+		if len(code) == 0 {
+			built.Factory = builder.factory
+			built.FactoryData = builder.factoryData
+		}
+	}
+
+	// Step 3: Suggest fees
+	if built.MaxFeePerGas == nil || built.MaxPriorityFeePerGas == nil {
+		maxFeePerGas, maxPriorityFeePerGas, err := builder.suggestFees(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if built.MaxFeePerGas == nil {
+			built.MaxFeePerGas = maxFeePerGas
+		}
+		if built.MaxPriorityFeePerGas == nil {
+			built.MaxPriorityFeePerGas = maxPriorityFeePerGas
+		}
+	}
+
+	// Step 4: Estimate gas limits
+	if builder.needsEstimate(&built) {
+		if err := builder.estimateGas(ctx, &built); err != nil {
+			return nil, err
+		}
+	}
+
+	// Step 5: Sign
+	if builder.signer != nil {
+		chainID, err := builder.getChainID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		signature, err := builder.signer.SignUserOp(ctx, &built, builder.entryPoint.Address, chainID)
+		if err != nil {
+			return nil, err
+		}
+		built.Signature = signature
+	}
+
+	return &built, nil
+}
+
+func (builder *Builder) getNonce(ctx context.Context, sender common.Address) (*big.Int, error) {
+	data, err := builder.entryPoint.PackGetNonce(sender, builder.nonceKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack getNonce call: %w", err)
+	}
+
+	output, err := builder.node.CallContract(ctx, ethereum.CallMsg{
+		To:   &builder.entryPoint.Address,
+		Data: data,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nonce of sender %s: %w", sender.Hex(), err)
+	}
+
+	nonce, err := builder.entryPoint.UnpackGetNonce(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack getNonce result: %w", err)
+	}
+	return nonce, nil
+}
+
+// suggestFees returns a max fee of twice the latest base fee plus the suggested
+// priority fee, so the userOp stays includable while the base fee rises
+func (builder *Builder) suggestFees(ctx context.Context) (*big.Int, *big.Int, error) {
+	maxPriorityFeePerGas, err := builder.node.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to suggest priority fee: %w", err)
+	}
+	header, err := builder.node.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get latest block header: %w", err)
+	}
+
+	maxFeePerGas := new(big.Int).Set(maxPriorityFeePerGas)
+	if header.BaseFee != nil {
+		maxFeePerGas.Add(maxFeePerGas, new(big.Int).Mul(header.BaseFee, big.NewInt(2)))
+	}
+	return maxFeePerGas, maxPriorityFeePerGas, nil
+}
+
+func (builder *Builder) needsEstimate(userOp *types.UserOperation) bool {
+	if userOp.CallGasLimit == nil || userOp.VerificationGasLimit == nil || userOp.PreVerificationGas == nil {
+		return true
+	}
+	return builder.hasPaymasterGasLimits(userOp) &&
+		(userOp.PaymasterVerificationGasLimit == nil || userOp.PaymasterPostOpGasLimit == nil)
+}
+
+// hasPaymasterGasLimits reports whether the userOp carries paymaster gas limits (v0.7+ with a paymaster)
+func (builder *Builder) hasPaymasterGasLimits(userOp *types.UserOperation) bool {
+	return userOp.Paymaster != (common.Address{}) && builder.entryPoint.Version != types.EntryPointVersionV06
+}
+
+// estimateGas fills the userOp's unset gas limits from the estimator
+func (builder *Builder) estimateGas(ctx context.Context, userOp *types.UserOperation) error {
+	if builder.estimator == nil {
+		return fmt.Errorf("userOp has unset gas limits and the builder has no gas estimator")
+	}
+
+	// Estimate a copy with zero gas limits and a dummy signature
+	estimateOp := *userOp
+	for _, gasLimit := range []**big.Int{
+		&estimateOp.CallGasLimit,
+		&estimateOp.VerificationGasLimit,
+		&estimateOp.PreVerificationGas,
+	} {
+		if *gasLimit == nil {
+			*gasLimit = new(big.Int)
+		}
+	}
+	if builder.hasPaymasterGasLimits(&estimateOp) {
+		if estimateOp.PaymasterVerificationGasLimit == nil {
+			estimateOp.PaymasterVerificationGasLimit = new(big.Int)
+		}
+		if estimateOp.PaymasterPostOpGasLimit == nil {
+			estimateOp.PaymasterPostOpGasLimit = new(big.Int)
+		}
+	}
+	if builder.signer != nil {
+		estimateOp.Signature = builder.signer.DummySignature()
+	}
+
+	estimate, err := builder.estimator.EstimateUserOperationGas(ctx, &estimateOp, builder.entryPoint.Address)
+	if err != nil {
+		return fmt.Errorf("failed to estimate userOp gas: %w", err)
+	}
+
+	// Fill unset gas limits only
+	fill := func(gasLimit **big.Int, estimated *big.Int, name string) error {
+		if *gasLimit != nil {
+			return nil
+		}
+		if estimated == nil {
+			return fmt.Errorf("gas estimate has no %s", name)
+		}
+		*gasLimit = new(big.Int).Set(estimated)
+		return nil
+	}
+	if err := fill(&userOp.CallGasLimit, estimate.CallGasLimit.ToInt(), "callGasLimit"); err != nil {
+		return err
+	}
+	if err := fill(&userOp.VerificationGasLimit, estimate.VerificationGasLimit.ToInt(), "verificationGasLimit"); err != nil {
+		return err
+	}
+	if err := fill(&userOp.PreVerificationGas, estimate.PreVerificationGas.ToInt(), "preVerificationGas"); err != nil {
+		return err
+	}
+	if !builder.hasPaymasterGasLimits(userOp) {
+		return nil
+	}
+	if err := fill(&userOp.PaymasterVerificationGasLimit, estimate.PaymasterVerificationGasLimit.ToInt(), "paymasterVerificationGasLimit"); err != nil {
+		return err
+	}
+	return fill(&userOp.PaymasterPostOpGasLimit, estimate.PaymasterPostOpGasLimit.ToInt(), "paymasterPostOpGasLimit")
+}
+
+// getChainID reads the chain ID from the node once
+func (builder *Builder) getChainID(ctx context.Context) (*big.Int, error) {
+	builder.chainMutex.Lock()
+	defer builder.chainMutex.Unlock()
+
+	if builder.chainID == nil {
+		chainID, err := builder.node.ChainID(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get chain ID: %w", err)
+		}
+		builder.chainID = chainID
+	}
+	return builder.chainID, nil
+}
@@ -0,0 +1,173 @@
+package userop
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+// fakeNode serves a fixed nonce, code, base fee and tip
+type fakeNode struct {
+	nonce   *big.Int
+	code    []byte
+	baseFee *big.Int
+	tip     *big.Int
+	chainID *big.Int
+	calls   []ethereum.CallMsg
+}
+
+func (node *fakeNode) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	node.calls = append(node.calls, call)
+	return common.LeftPadBytes(node.nonce.Bytes(), 32), nil
+}
+
+func (node *fakeNode) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	return node.code, nil
+}
+
+func (node *fakeNode) HeaderByNumber(ctx context.Context, number *big.Int) (*ethtypes.Header, error) {
+	return &ethtypes.Header{BaseFee: node.baseFee}, nil
+}
+
+func (node *fakeNode) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return node.tip, nil
+}
+
+func (node *fakeNode) ChainID(ctx context.Context) (*big.Int, error) {
+	return node.chainID, nil
+}
+
+// fakeEstimator returns a fixed estimate and keeps the userOp it estimated
+type fakeEstimator struct {
+	estimate  *types.UserOperationGasEstimate
+	estimated *types.UserOperation
+}
+
+func (estimator *fakeEstimator) EstimateUserOperationGas(ctx context.Context, userOp *types.UserOperation, entryPoint common.Address) (*types.UserOperationGasEstimate, error) {
+	estimator.estimated = userOp
+	return estimator.estimate, nil
+}
+
+func TestBuild(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := NewECDSASigner(privateKey)
+	node := &fakeNode{
+		nonce:   big.NewInt(5),
+		baseFee: big.NewInt(100),
+		tip:     big.NewInt(3),
+		chainID: big.NewInt(1),
+	}
+	estimator := &fakeEstimator{estimate: &types.UserOperationGasEstimate{
+		PreVerificationGas:   (*hexutil.Big)(big.NewInt(50000)),
+		VerificationGasLimit: (*hexutil.Big)(big.NewInt(200000)),
+		CallGasLimit:         (*hexutil.Big)(big.NewInt(100000)),
+	}}
+	factory := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	builder, err := NewBuilder(BuilderConfig{
+		EntryPoint:  types.EntryPointV07Address,
+		Node:        node,
+		Estimator:   estimator,
+		Signer:      signer,
+		Factory:     factory,
+		FactoryData: hexutil.MustDecode("0xdeadbeef"),
+	})
+	if err != nil {
+		t.Fatalf("failed to create builder: %v", err)
+	}
+
+	// Build a userOp with its call gas limit already set
+	userOp := &types.UserOperation{
+		Sender:       common.HexToAddress("0x1111111111111111111111111111111111111111"),
+		CallData:     hexutil.MustDecode("0xb61d27f6"),
+		CallGasLimit: big.NewInt(70000),
+	}
+	built, err := builder.Build(context.Background(), userOp)
+	if err != nil {
+		t.Fatalf("failed to build: %v", err)
+	}
+
+	// The nonce is read from the entry point for the default key
+	if len(node.calls) != 1 || *node.calls[0].To != types.EntryPointV07Address {
+		t.Fatalf("node calls = %+v, want one getNonce call to the entry point", node.calls)
+	}
+	if built.Nonce.Cmp(big.NewInt(5)) != 0 {
+		t.Fatalf("nonce = %v, want 5", built.Nonce)
+	}
+
+	// The sender has no code, so it is deployed with the factory
+	if built.Factory != factory || hexutil.Encode(built.FactoryData) != "0xdeadbeef" {
+		t.Fatalf("factory = %s %x, want %s deadbeef", built.Factory.Hex(), built.FactoryData, factory.Hex())
+	}
+
+	// Max fee is twice the base fee plus the tip
+	if built.MaxPriorityFeePerGas.Cmp(big.NewInt(3)) != 0 || built.MaxFeePerGas.Cmp(big.NewInt(203)) != 0 {
+		t.Fatalf("fees = %v/%v, want 203/3", built.MaxFeePerGas, built.MaxPriorityFeePerGas)
+	}
+
+	// Only unset gas limits are filled, and the estimate is made with a dummy signature
+	if built.CallGasLimit.Cmp(big.NewInt(70000)) != 0 {
+		t.Fatalf("callGasLimit = %v, want the set 70000", built.CallGasLimit)
+	}
+	if built.VerificationGasLimit.Cmp(big.NewInt(200000)) != 0 || built.PreVerificationGas.Cmp(big.NewInt(50000)) != 0 {
+		t.Fatalf("gas limits = %v/%v, want 200000/50000", built.VerificationGasLimit, built.PreVerificationGas)
+	}
+	if hexutil.Encode(estimator.estimated.Signature) != hexutil.Encode(signer.DummySignature()) {
+		t.Fatalf("estimated signature = %x, want the dummy signature", estimator.estimated.Signature)
+	}
+
+	// The built userOp is signed by the owner and the input is left unchanged
+	digest := accounts.TextHash(built.Hash(types.EntryPointV07Address, big.NewInt(1)).Bytes())
+	if recovered := recoverSigner(t, digest, built.Signature); recovered != signer.Owner() {
+		t.Fatalf("signed by %s, want owner %s", recovered.Hex(), signer.Owner().Hex())
+	}
+	if userOp.Nonce != nil || userOp.Signature != nil || userOp.MaxFeePerGas != nil {
+		t.Fatalf("input userOp modified: %+v", userOp)
+	}
+}
+
+func TestBuildDeployedSender(t *testing.T) {
+	node := &fakeNode{
+		nonce:   big.NewInt(0),
+		code:    []byte{0x60},
+		baseFee: big.NewInt(100),
+		tip:     big.NewInt(3),
+		chainID: big.NewInt(1),
+	}
+	builder, err := NewBuilder(BuilderConfig{
+		EntryPoint: types.EntryPointV08Address,
+		Node:       node,
+		Factory:    common.HexToAddress("0x2222222222222222222222222222222222222222"),
+	})
+	if err != nil {
+		t.Fatalf("failed to create builder: %v", err)
+	}
+
+	// Gas limits are set, so no estimator is needed, and no signer leaves it unsigned
+	built, err := builder.Build(context.Background(), &types.UserOperation{
+		Sender:               common.HexToAddress("0x1111111111111111111111111111111111111111"),
+		CallData:             hexutil.MustDecode("0xb61d27f6"),
+		CallGasLimit:         big.NewInt(100000),
+		VerificationGasLimit: big.NewInt(200000),
+		PreVerificationGas:   big.NewInt(50000),
+	})
+	if err != nil {
+		t.Fatalf("failed to build: %v", err)
+	}
+	if built.Factory != (common.Address{}) {
+		t.Fatalf("factory = %s, want none for a deployed sender", built.Factory.Hex())
+	}
+	if built.Signature != nil {
+		t.Fatalf("signature = %x, want unsigned", built.Signature)
+	}
+}
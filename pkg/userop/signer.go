@@ -0,0 +1,69 @@
+package userop
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+// Signer signs userOps for a smart account's signature check
+type Signer interface {
+	SignUserOp(ctx context.Context, userOp *types.UserOperation, entryPoint common.Address, chainID *big.Int) ([]byte, error)
+
+	// DummySignature has the length and format of a real signature without
+	// being valid, so gas is estimated as for a signed userOp
+	DummySignature() []byte
+}
+
+// A well-formed ECDSA signature (low s, v = 28) that recovers to an unknown address
+var ecdsaDummySignature = hexutil.MustDecode("0xfffffffffffffffffffffffffffffff0000000000000000000000000000000007aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa1c")
+
+// ECDSASigner signs for SimpleAccount-style accounts owned by an ECDSA key.
+// v0.6 and v0.7 accounts recover the signer from the eth_sign (EIP-191) hash of
+// the userOpHash, v0.8 accounts from the userOpHash itself, which is already an
+// EIP-712 digest.
+type ECDSASigner struct {
+	privateKey *ecdsa.PrivateKey
+	owner      common.Address
+}
+
+func NewECDSASigner(privateKey *ecdsa.PrivateKey) *ECDSASigner {
+	return &ECDSASigner{
+		privateKey: privateKey,
+		owner:      crypto.PubkeyToAddress(privateKey.PublicKey),
+	}
+}
+
+// Owner returns the address of the signing key, the account owner
+func (signer *ECDSASigner) Owner() common.Address {
+	return signer.owner
+}
+
+func (signer *ECDSASigner) SignUserOp(ctx context.Context, userOp *types.UserOperation, entryPoint common.Address, chainID *big.Int) ([]byte, error) {
+	// Hash what the account verifies for the entry point version
+	userOpHash := userOp.Hash(entryPoint, chainID)
+	digest := userOpHash.Bytes()
+	if entryPoint != types.EntryPointV08Address {
+		digest = accounts.TextHash(digest)
+	}
+
+	signature, err := crypto.Sign(digest, signer.privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign userOp %s: %w", userOpHash.Hex(), err)
+	}
+
+	// Accounts recover with v = 27 or 28
+	signature[crypto.RecoveryIDOffset] += 27
+	return signature, nil
+}
+
+func (signer *ECDSASigner) DummySignature() []byte {
+	return common.CopyBytes(ecdsaDummySignature)
+}
@@ -0,0 +1,104 @@
+package userop
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/vorpalengineering/gundler/pkg/types"
+)
+
+// recoverSigner returns the address that signed digest, as an account's ecrecover would
+func recoverSigner(t *testing.T, digest []byte, signature []byte) common.Address {
+	t.Helper()
+	if len(signature) != crypto.SignatureLength {
+		t.Fatalf("signature length = %d, want %d", len(signature), crypto.SignatureLength)
+	}
+	v := signature[crypto.RecoveryIDOffset]
+	if v != 27 && v != 28 {
+		t.Fatalf("signature v = %d, want 27 or 28", v)
+	}
+	sig := common.CopyBytes(signature)
+	sig[crypto.RecoveryIDOffset] -= 27
+	publicKey, err := crypto.SigToPub(digest, sig)
+	if err != nil {
+		t.Fatalf("failed to recover signer: %v", err)
+	}
+	return crypto.PubkeyToAddress(*publicKey)
+}
+
+func TestECDSASignerRecovery(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := NewECDSASigner(privateKey)
+	userOp := &types.UserOperation{
+		Sender:               common.HexToAddress("0x1111111111111111111111111111111111111111"),
+		Nonce:                big.NewInt(1),
+		CallData:             hexutil.MustDecode("0xb61d27f6"),
+		CallGasLimit:         big.NewInt(100000),
+		VerificationGasLimit: big.NewInt(200000),
+		PreVerificationGas:   big.NewInt(50000),
+		MaxFeePerGas:         big.NewInt(2000000000),
+		MaxPriorityFeePerGas: big.NewInt(1000000000),
+	}
+	chainID := big.NewInt(1)
+
+	// v0.6 and v0.7 accounts recover from the eth_sign hash, v0.8 accounts from the raw EIP-712 hash
+	tests := []struct {
+		name       string
+		entryPoint common.Address
+		ethSign    bool
+	}{
+		{"v0.6", types.EntryPointV06Address, true},
+		{"v0.7", types.EntryPointV07Address, true},
+		{"v0.8", types.EntryPointV08Address, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			signature, err := signer.SignUserOp(context.Background(), userOp, test.entryPoint, chainID)
+			if err != nil {
+				t.Fatalf("failed to sign: %v", err)
+			}
+
+			userOpHash := userOp.Hash(test.entryPoint, chainID).Bytes()
+			signed, other := userOpHash, accounts.TextHash(userOpHash)
+			if test.ethSign {
+				signed, other = other, signed
+			}
+			if recovered := recoverSigner(t, signed, signature); recovered != signer.Owner() {
+				t.Fatalf("recovered %s, want owner %s", recovered.Hex(), signer.Owner().Hex())
+			}
+			if recovered := recoverSigner(t, other, signature); recovered == signer.Owner() {
+				t.Fatalf("signature also recovers the owner from the wrong digest")
+			}
+		})
+	}
+}
+
+func TestECDSADummySignature(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := NewECDSASigner(privateKey)
+
+	dummy := signer.DummySignature()
+	if len(dummy) != crypto.SignatureLength {
+		t.Fatalf("dummy signature length = %d, want %d", len(dummy), crypto.SignatureLength)
+	}
+	if recovered := recoverSigner(t, crypto.Keccak256([]byte("userOp")), dummy); recovered == signer.Owner() {
+		t.Fatalf("dummy signature recovers the owner")
+	}
+
+	// Callers may modify the returned signature
+	dummy[0] = 0
+	if signer.DummySignature()[0] == 0 {
+		t.Fatalf("dummy signature shared between calls")
+	}
+}
@@ -0,0 +1,91 @@
+package userop
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Minimal SimpleAccount and SimpleAccountFactory ABI, shared by the v0.6, v0.7 and v0.8 samples
+const simpleAccountABIJSON = `[
+	{"type":"function","name":"execute","stateMutability":"nonpayable","inputs":[
+		{"name":"dest","type":"address"},
+		{"name":"value","type":"uint256"},
+		{"name":"func","type":"bytes"}
+	],"outputs":[]},
+	{"type":"function","name":"createAccount","stateMutability":"nonpayable","inputs":[
+		{"name":"owner","type":"address"},
+		{"name":"salt","type":"uint256"}
+	],"outputs":[
+		{"name":"ret","type":"address"}
+	]},
+	{"type":"function","name":"getAddress","stateMutability":"view","inputs":[
+		{"name":"owner","type":"address"},
+		{"name":"salt","type":"uint256"}
+	],"outputs":[
+		{"name":"","type":"address"}
+	]}
+]`
+
+var SimpleAccountABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(simpleAccountABIJSON))
+	if err != nil {
+		panic(fmt.Sprintf("invalid SimpleAccount ABI: %v", err))
+	}
+	return parsed
+}()
+
+// SimpleAccount is a SimpleAccount deployed (or to be deployed) by a
+// SimpleAccountFactory for an owner and salt
+type SimpleAccount struct {
+	Factory common.Address
+	Owner   common.Address
+	Salt    *big.Int
+}
+
+// Address returns the account's counterfactual address from the factory
+func (account *SimpleAccount) Address(ctx context.Context, caller ethereum.ContractCaller) (common.Address, error) {
+	data, err := SimpleAccountABI.Pack("getAddress", account.Owner, account.salt())
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to pack getAddress call: %w", err)
+	}
+
+	output, err := caller.CallContract(ctx, ethereum.CallMsg{
+		To:   &account.Factory,
+		Data: data,
+	}, nil)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to call getAddress on factory %s: %w", account.Factory.Hex(), err)
+	}
+
+	values, err := SimpleAccountABI.Unpack("getAddress", output)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to unpack getAddress result: %w", err)
+	}
+	return values[0].(common.Address), nil
+}
+
+// FactoryData encodes the factory's createAccount() call that deploys the account
+func (account *SimpleAccount) FactoryData() ([]byte, error) {
+	return SimpleAccountABI.Pack("createAccount", account.Owner, account.salt())
+}
+
+// Execute encodes the account's execute() call, the userOp callData for one call
+func (account *SimpleAccount) Execute(dest common.Address, value *big.Int, data []byte) ([]byte, error) {
+	if value == nil {
+		value = new(big.Int)
+	}
+	return SimpleAccountABI.Pack("execute", dest, value, data)
+}
+
+func (account *SimpleAccount) salt() *big.Int {
+	if account.Salt == nil {
+		return new(big.Int)
+	}
+	return account.Salt
+}